@@ -1,6 +1,8 @@
 package auth
 
 import (
+	"crypto/rand"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"os"
@@ -21,14 +23,43 @@ const (
 	minJWTSecretLength = 32
 	appEnvVar          = "APP_ENV"
 
+	issuerEnvVar   = "JWT_ISSUER"
+	audienceEnvVar = "JWT_AUDIENCE"
+	leewayEnvVar   = "JWT_LEEWAY"
+	defaultLeeway  = 30 * time.Second
+
 	envLocal = "LOCAL"
 	envDev   = "DEV"
 	envProd  = "PROD"
 )
 
+// Reasons a token is rejected. Callers should log them but answer every
+// rejection the same way, so clients learn nothing about why a token failed.
+var (
+	ErrTokenMalformed   = errors.New("invalid token: malformed")
+	ErrTokenSignature   = errors.New("invalid token: bad signature or unknown key")
+	ErrTokenExpired     = errors.New("invalid token: expired")
+	ErrTokenNotYetValid = errors.New("invalid token: not valid yet")
+	ErrTokenIssuer      = errors.New("invalid token: wrong issuer")
+	ErrTokenAudience    = errors.New("invalid token: wrong audience")
+	ErrTokenType        = errors.New("invalid token: wrong token type")
+	ErrTokenClaims      = errors.New("invalid token: missing or invalid claims")
+)
+
 type tokenClaims struct {
 	jwt.RegisteredClaims
 	TokenType string `json:"token_type"`
+	FamilyID  string `json:"family_id,omitempty"`
+}
+
+// RefreshTokenClaims describe a refresh token. Every token has its own ID,
+// and tokens rotated from the same sign-in share a family ID.
+type RefreshTokenClaims struct {
+	UserID    int
+	TokenID   string
+	FamilyID  string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
 }
 
 // CreateToken generates a new bearer token for the given user ID.
@@ -41,23 +72,76 @@ func CreateAccessToken(userID int) (string, error) {
 	return createToken(userID, accessTokenTTL, accessTokenType)
 }
 
-// CreateRefreshToken generates a new refresh token for the given user ID.
+// CreateRefreshToken generates a new refresh token for the given user ID,
+// starting a new token family.
 func CreateRefreshToken(userID int) (string, error) {
-	return createToken(userID, refreshTokenTTL, refreshTokenType)
+	token, _, err := IssueRefreshToken(userID, "")
+	return token, err
+}
+
+// IssueRefreshToken generates a new refresh token for the given user ID in
+// the given token family, or in a new family when familyID is empty. The
+// returned claims are what callers need to store the token.
+func IssueRefreshToken(userID int, familyID string) (string, RefreshTokenClaims, error) {
+	tokenID, err := newTokenID()
+	if err != nil {
+		return "", RefreshTokenClaims{}, err
+	}
+	if familyID == "" {
+		familyID = tokenID
+	}
+
+	claims := newTokenClaims(tokenID, userID, refreshTokenTTL, refreshTokenType)
+	claims.FamilyID = familyID
+
+	token, err := signClaims(claims)
+	if err != nil {
+		return "", RefreshTokenClaims{}, err
+	}
+	return token, refreshTokenClaims(userID, &claims), nil
 }
 
 func createToken(userID int, ttl time.Duration, tokenType string) (string, error) {
+	tokenID, err := newTokenID()
+	if err != nil {
+		return "", err
+	}
+
+	return signClaims(newTokenClaims(tokenID, userID, ttl, tokenType))
+}
+
+// newTokenClaims returns the claims every token carries, with the configured
+// issuer and audience when they are set.
+func newTokenClaims(tokenID string, userID int, ttl time.Duration, tokenType string) tokenClaims {
+	now := time.Now()
 	claims := tokenClaims{
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        tokenID,
+			Issuer:    tokenIssuer(),
 			Subject:   strconv.Itoa(userID),
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			NotBefore: jwt.NewNumericDate(now),
+			IssuedAt:  jwt.NewNumericDate(now),
 		},
 		TokenType: tokenType,
 	}
+	if audience := tokenAudience(); audience != "" {
+		claims.Audience = jwt.ClaimStrings{audience}
+	}
+	return claims
+}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(signingKey())
+func signClaims(claims tokenClaims) (string, error) {
+	keys, err := currentKeySet()
+	if err != nil {
+		return "", fmt.Errorf("load jwt signing key: %w", err)
+	}
+
+	token := jwt.NewWithClaims(keys.current.method, claims)
+	if keys.current.id != "" {
+		token.Header["kid"] = keys.current.id
+	}
+	tokenString, err := token.SignedString(keys.current.signKey)
 	if err != nil {
 		return "", fmt.Errorf("sign jwt token: %w", err)
 	}
@@ -66,46 +150,160 @@ func createToken(userID int, ttl time.Duration, tokenType string) (string, error
 }
 
 // ValidateToken checks that a token is valid and not expired.
-// It returns the associated user ID on success.
+// It returns the associated user ID on success. Errors wrap one of the
+// ErrToken reasons.
 func ValidateToken(token string) (int, error) {
-	return validateTokenType(token, accessTokenType)
+	userID, _, err := validateTokenType(token, accessTokenType)
+	return userID, err
 }
 
 // ValidateRefreshToken checks that a refresh token is valid and not expired.
 // It returns the associated user ID on success.
 func ValidateRefreshToken(token string) (int, error) {
-	return validateTokenType(token, refreshTokenType)
+	userID, _, err := validateTokenType(token, refreshTokenType)
+	return userID, err
 }
 
-func validateTokenType(token string, expectedType string) (int, error) {
+// ParseRefreshToken validates a refresh token like ValidateRefreshToken and
+// returns its claims, so callers can look the token up in their store.
+// Tokens without an ID or family, issued before tokens were stored, are
+// rejected.
+func ParseRefreshToken(token string) (RefreshTokenClaims, error) {
+	userID, claims, err := validateTokenType(token, refreshTokenType)
+	if err != nil {
+		return RefreshTokenClaims{}, err
+	}
+	if claims.FamilyID == "" {
+		return RefreshTokenClaims{}, fmt.Errorf("%w: no family", ErrTokenClaims)
+	}
+	return refreshTokenClaims(userID, claims), nil
+}
+
+func refreshTokenClaims(userID int, claims *tokenClaims) RefreshTokenClaims {
+	return RefreshTokenClaims{
+		UserID:    userID,
+		TokenID:   claims.ID,
+		FamilyID:  claims.FamilyID,
+		IssuedAt:  claims.IssuedAt.Time,
+		ExpiresAt: claims.ExpiresAt.Time,
+	}
+}
+
+// newTokenID returns a random token ID for the jti claim.
+func newTokenID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("generate token id: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(id), nil
+}
+
+func validateTokenType(token string, expectedType string) (int, *tokenClaims, error) {
+	keys, err := currentKeySet()
+	if err != nil {
+		return 0, nil, fmt.Errorf("%w: %v", ErrTokenSignature, err)
+	}
+
 	claims := &tokenClaims{}
-	parsedToken, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (any, error) {
-		if t.Method != jwt.SigningMethodHS256 {
-			return nil, errors.New("unexpected signing method")
-		}
-		return signingKey(), nil
+	_, err = newTokenParser().ParseWithClaims(token, claims, func(t *jwt.Token) (any, error) {
+		return keys.verificationKey(t, time.Now())
 	})
 	if err != nil {
-		return 0, errors.New("invalid token")
+		return 0, nil, fmt.Errorf("%w: %v", tokenErrorReason(err), err)
 	}
 
-	if !parsedToken.Valid {
-		return 0, errors.New("invalid token")
+	if claims.TokenType != expectedType {
+		return 0, nil, fmt.Errorf("%w: got %q, want %q", ErrTokenType, claims.TokenType, expectedType)
 	}
 
-	if claims.TokenType != expectedType {
-		return 0, errors.New("invalid token")
+	if claims.ID == "" || claims.IssuedAt == nil {
+		return 0, nil, fmt.Errorf("%w: no jti or iat", ErrTokenClaims)
 	}
 
 	userID, err := strconv.Atoi(claims.Subject)
 	if err != nil {
-		return 0, errors.New("invalid token")
+		return 0, nil, fmt.Errorf("%w: subject %q", ErrTokenClaims, claims.Subject)
+	}
+
+	return userID, claims, nil
+}
+
+// newTokenParser returns a parser that requires exp, checks exp, nbf and iat
+// with the configured leeway, and checks the issuer and audience when they
+// are configured.
+func newTokenParser() *jwt.Parser {
+	leeway, err := tokenLeeway()
+	if err != nil {
+		leeway = defaultLeeway
+	}
+
+	options := []jwt.ParserOption{
+		jwt.WithLeeway(leeway),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	}
+	if issuer := tokenIssuer(); issuer != "" {
+		options = append(options, jwt.WithIssuer(issuer))
+	}
+	if audience := tokenAudience(); audience != "" {
+		options = append(options, jwt.WithAudience(audience))
 	}
+	return jwt.NewParser(options...)
+}
 
-	return userID, nil
+// tokenErrorReason maps a parser error to the reason it is logged with.
+func tokenErrorReason(err error) error {
+	switch {
+	case errors.Is(err, jwt.ErrTokenMalformed):
+		return ErrTokenMalformed
+	case errors.Is(err, jwt.ErrTokenUnverifiable), errors.Is(err, jwt.ErrTokenSignatureInvalid):
+		return ErrTokenSignature
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return ErrTokenExpired
+	case errors.Is(err, jwt.ErrTokenNotValidYet), errors.Is(err, jwt.ErrTokenUsedBeforeIssued):
+		return ErrTokenNotYetValid
+	case errors.Is(err, jwt.ErrTokenInvalidIssuer):
+		return ErrTokenIssuer
+	case errors.Is(err, jwt.ErrTokenInvalidAudience):
+		return ErrTokenAudience
+	default:
+		return ErrTokenClaims
+	}
 }
 
-func signingKey() []byte {
+// tokenIssuer returns the iss claim tokens are issued and checked with, or an
+// empty string when the claim is not used.
+func tokenIssuer() string {
+	return strings.TrimSpace(os.Getenv(issuerEnvVar))
+}
+
+// tokenAudience returns the aud claim tokens are issued and checked with, or
+// an empty string when the claim is not used.
+func tokenAudience() string {
+	return strings.TrimSpace(os.Getenv(audienceEnvVar))
+}
+
+// tokenLeeway returns how much clock skew is tolerated when checking exp, nbf
+// and iat.
+func tokenLeeway() (time.Duration, error) {
+	value := strings.TrimSpace(os.Getenv(leewayEnvVar))
+	if value == "" {
+		return defaultLeeway, nil
+	}
+
+	leeway, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", leewayEnvVar, err)
+	}
+	if leeway < 0 {
+		return 0, fmt.Errorf("%s must not be negative", leewayEnvVar)
+	}
+	return leeway, nil
+}
+
+// signingSecret returns the HMAC secret tokens are signed with when no
+// signing key file is configured.
+func signingSecret() []byte {
 	if value := os.Getenv("JWT_SECRET"); value != "" {
 		return []byte(value)
 	}
@@ -118,12 +316,23 @@ func signingKey() []byte {
 }
 
 // ValidateJWTConfig checks whether JWT signing configuration is safe to use.
+// When JWT_SIGNING_KEY_FILE is set, the key files are loaded and JWT_SECRET is
+// not used.
 func ValidateJWTConfig() error {
 	env := currentEnvironment()
 	if env != envLocal && env != envDev && env != envProd {
 		return errors.New("APP_ENV must be one of LOCAL, DEV, PROD")
 	}
 
+	if _, err := tokenLeeway(); err != nil {
+		return err
+	}
+
+	if strings.TrimSpace(os.Getenv(signingKeyFileEnvVar)) != "" {
+		_, err := currentKeySet()
+		return err
+	}
+
 	jwtSecret := os.Getenv("JWT_SECRET")
 	if jwtSecret == "" {
 		if env == envLocal {
@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"errors"
 	"strconv"
 	"sync"
 	"testing"
@@ -72,6 +73,17 @@ func TestValidateJWTConfigRejectsInvalidEnvironment(t *testing.T) {
 	}
 }
 
+func TestValidateJWTConfigRejectsInvalidLeeway(t *testing.T) {
+	t.Setenv("JWT_SECRET", "12345678901234567890123456789012")
+
+	for _, leeway := range []string{"soon", "-1s"} {
+		t.Setenv(leewayEnvVar, leeway)
+		if err := ValidateJWTConfig(); err == nil {
+			t.Fatalf("ValidateJWTConfig expected error for JWT_LEEWAY=%q", leeway)
+		}
+	}
+}
+
 func TestValidateTokenInvalidToken(t *testing.T) {
 	t.Setenv("JWT_SECRET", "test-secret")
 
@@ -91,13 +103,13 @@ func TestValidateTokenExpiredToken(t *testing.T) {
 		TokenType: accessTokenType,
 	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(signingKey())
+	tokenString, err := token.SignedString(signingSecret())
 	if err != nil {
 		t.Fatalf("sign token: %v", err)
 	}
 
-	if _, err := ValidateToken(tokenString); err == nil {
-		t.Fatal("ValidateToken expected error for expired token")
+	if _, err := ValidateToken(tokenString); !errors.Is(err, ErrTokenExpired) {
+		t.Fatalf("ValidateToken expected ErrTokenExpired, got %v", err)
 	}
 }
 
@@ -109,8 +121,101 @@ func TestValidateTokenRejectsRefreshToken(t *testing.T) {
 		t.Fatalf("CreateRefreshToken returned error: %v", err)
 	}
 
-	if _, err := ValidateToken(refreshToken); err == nil {
-		t.Fatal("ValidateToken expected error for refresh token")
+	if _, err := ValidateToken(refreshToken); !errors.Is(err, ErrTokenType) {
+		t.Fatalf("ValidateToken expected ErrTokenType for refresh token, got %v", err)
+	}
+}
+
+func signTestClaims(t *testing.T, claims jwt.RegisteredClaims) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, tokenClaims{RegisteredClaims: claims, TokenType: accessTokenType})
+	tokenString, err := token.SignedString(signingSecret())
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+	return tokenString
+}
+
+func TestTokensCarryIssuerAudienceAndID(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+	t.Setenv(issuerEnvVar, "https://santa.example.com")
+	t.Setenv(audienceEnvVar, "secret-santa-api")
+
+	token, err := CreateAccessToken(42)
+	if err != nil {
+		t.Fatalf("CreateAccessToken returned error: %v", err)
+	}
+
+	claims := &tokenClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(token, claims); err != nil {
+		t.Fatalf("parse token: %v", err)
+	}
+	if claims.Issuer != "https://santa.example.com" || len(claims.Audience) != 1 || claims.Audience[0] != "secret-santa-api" {
+		t.Fatalf("unexpected iss %q and aud %v", claims.Issuer, claims.Audience)
+	}
+	if claims.ID == "" || claims.NotBefore == nil || claims.IssuedAt == nil {
+		t.Fatalf("expected jti, nbf and iat, got %+v", claims.RegisteredClaims)
+	}
+	if userID, err := ValidateToken(token); err != nil || userID != 42 {
+		t.Fatalf("ValidateToken returned %d, %v", userID, err)
+	}
+
+	t.Setenv(audienceEnvVar, "another-api")
+	if _, err := ValidateToken(token); !errors.Is(err, ErrTokenAudience) {
+		t.Fatalf("expected ErrTokenAudience, got %v", err)
+	}
+
+	t.Setenv(audienceEnvVar, "secret-santa-api")
+	t.Setenv(issuerEnvVar, "https://elsewhere.example.com")
+	if _, err := ValidateToken(token); !errors.Is(err, ErrTokenIssuer) {
+		t.Fatalf("expected ErrTokenIssuer, got %v", err)
+	}
+}
+
+func TestValidateTokenAllowsClockSkewWithinTheLeeway(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+
+	now := time.Now()
+	expired := signTestClaims(t, jwt.RegisteredClaims{
+		ID:        "expired",
+		Subject:   "42",
+		IssuedAt:  jwt.NewNumericDate(now.Add(-time.Hour)),
+		ExpiresAt: jwt.NewNumericDate(now.Add(-10 * time.Second)),
+	})
+	early := signTestClaims(t, jwt.RegisteredClaims{
+		ID:        "early",
+		Subject:   "42",
+		IssuedAt:  jwt.NewNumericDate(now.Add(10 * time.Second)),
+		NotBefore: jwt.NewNumericDate(now.Add(10 * time.Second)),
+		ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+	})
+
+	for _, token := range []string{expired, early} {
+		if _, err := ValidateToken(token); err != nil {
+			t.Fatalf("expected the default leeway to allow 10s of skew, got %v", err)
+		}
+	}
+
+	t.Setenv(leewayEnvVar, "0s")
+	if _, err := ValidateToken(expired); !errors.Is(err, ErrTokenExpired) {
+		t.Fatalf("expected ErrTokenExpired, got %v", err)
+	}
+	if _, err := ValidateToken(early); !errors.Is(err, ErrTokenNotYetValid) {
+		t.Fatalf("expected ErrTokenNotYetValid, got %v", err)
+	}
+}
+
+func TestValidateTokenRequiresAnID(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+
+	token := signTestClaims(t, jwt.RegisteredClaims{
+		Subject:   "42",
+		IssuedAt:  jwt.NewNumericDate(time.Now()),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	})
+	if _, err := ValidateToken(token); !errors.Is(err, ErrTokenClaims) {
+		t.Fatalf("expected ErrTokenClaims, got %v", err)
 	}
 }
 
@@ -132,6 +237,31 @@ func TestCreateAndValidateRefreshToken(t *testing.T) {
 	}
 }
 
+func TestIssueRefreshTokenKeepsTheFamily(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+
+	first, firstClaims, err := IssueRefreshToken(42, "")
+	if err != nil {
+		t.Fatalf("IssueRefreshToken returned error: %v", err)
+	}
+	_, nextClaims, err := IssueRefreshToken(42, firstClaims.FamilyID)
+	if err != nil {
+		t.Fatalf("IssueRefreshToken returned error: %v", err)
+	}
+
+	if nextClaims.TokenID == firstClaims.TokenID || nextClaims.FamilyID != firstClaims.FamilyID {
+		t.Fatalf("expected a new token in the same family, got %+v and %+v", firstClaims, nextClaims)
+	}
+
+	parsed, err := ParseRefreshToken(first)
+	if err != nil {
+		t.Fatalf("ParseRefreshToken returned error: %v", err)
+	}
+	if parsed.UserID != 42 || parsed.TokenID != firstClaims.TokenID || parsed.FamilyID != firstClaims.FamilyID {
+		t.Fatalf("ParseRefreshToken returned %+v, want %+v", parsed, firstClaims)
+	}
+}
+
 func TestCreateAndValidateTokenConcurrent(t *testing.T) {
 	t.Setenv("JWT_SECRET", "test-secret")
 
@@ -0,0 +1,279 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	signingKeyFileEnvVar    = "JWT_SIGNING_KEY_FILE"
+	previousKeyFilesEnvVar  = "JWT_PREVIOUS_KEY_FILES"
+	previousKeysUntilEnvVar = "JWT_PREVIOUS_KEYS_UNTIL"
+
+	minRSAKeyBits = 2048
+)
+
+// jwtKey is a key tokens are signed or verified with. Keys loaded from public
+// key files can only verify. HMAC secrets have no ID.
+type jwtKey struct {
+	id        string
+	method    jwt.SigningMethod
+	signKey   any
+	verifyKey any
+}
+
+// keySet holds the key new tokens are signed with and the previous keys that
+// are still accepted until previousUntil, so tokens signed before a rotation
+// keep working while they expire.
+type keySet struct {
+	current       jwtKey
+	previous      []jwtKey
+	previousUntil time.Time
+}
+
+// JWK is a public key in JSON Web Key form (RFC 7517).
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+}
+
+// JWKSet is a JSON Web Key Set.
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+var (
+	keySetMu     sync.Mutex
+	keySetConfig string
+	cachedKeySet *keySet
+)
+
+// currentKeySet returns the configured keys: the PEM files when
+// JWT_SIGNING_KEY_FILE is set, JWT_SECRET otherwise. Keys loaded from files
+// are cached until the configuration changes.
+func currentKeySet() (*keySet, error) {
+	keyFile := strings.TrimSpace(os.Getenv(signingKeyFileEnvVar))
+	if keyFile == "" {
+		secret := signingSecret()
+		return &keySet{current: jwtKey{method: jwt.SigningMethodHS256, signKey: secret, verifyKey: secret}}, nil
+	}
+
+	previousFiles := os.Getenv(previousKeyFilesEnvVar)
+	previousUntil := os.Getenv(previousKeysUntilEnvVar)
+	config := strings.Join([]string{keyFile, previousFiles, previousUntil}, "\n")
+
+	keySetMu.Lock()
+	defer keySetMu.Unlock()
+
+	if cachedKeySet != nil && keySetConfig == config {
+		return cachedKeySet, nil
+	}
+
+	keys, err := loadKeySet(keyFile, previousFiles, previousUntil)
+	if err != nil {
+		return nil, err
+	}
+	cachedKeySet, keySetConfig = keys, config
+	return keys, nil
+}
+
+func loadKeySet(keyFile string, previousFiles string, previousUntil string) (*keySet, error) {
+	current, err := loadKeyFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", signingKeyFileEnvVar, err)
+	}
+	if current.signKey == nil {
+		return nil, fmt.Errorf("%s: %s is not a private key", signingKeyFileEnvVar, keyFile)
+	}
+
+	keys := &keySet{current: current}
+	for _, path := range strings.Split(previousFiles, ",") {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+
+		previous, err := loadKeyFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", previousKeyFilesEnvVar, err)
+		}
+		keys.previous = append(keys.previous, previous)
+	}
+
+	if len(keys.previous) > 0 {
+		if previousUntil == "" {
+			return nil, fmt.Errorf("%s must be set when %s is", previousKeysUntilEnvVar, previousKeyFilesEnvVar)
+		}
+		keys.previousUntil, err = time.Parse(time.RFC3339, previousUntil)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", previousKeysUntilEnvVar, err)
+		}
+	}
+
+	return keys, nil
+}
+
+// loadKeyFile reads an RSA or Ed25519 key from a PEM file. Private keys may be
+// PKCS #8 or PKCS #1, public keys PKIX or PKCS #1.
+func loadKeyFile(path string) (jwtKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return jwtKey{}, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return jwtKey{}, fmt.Errorf("%s does not contain a PEM block", path)
+	}
+
+	var parsed any
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		parsed, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return jwtKey{}, fmt.Errorf("%s: unsupported PEM block %q", path, block.Type)
+	}
+	if err != nil {
+		return jwtKey{}, fmt.Errorf("%s: %w", path, err)
+	}
+
+	key, err := newJWTKey(parsed)
+	if err != nil {
+		return jwtKey{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return key, nil
+}
+
+func newJWTKey(parsed any) (jwtKey, error) {
+	var key jwtKey
+	switch typed := parsed.(type) {
+	case *rsa.PrivateKey:
+		key = jwtKey{method: jwt.SigningMethodRS256, signKey: typed, verifyKey: &typed.PublicKey}
+	case *rsa.PublicKey:
+		key = jwtKey{method: jwt.SigningMethodRS256, verifyKey: typed}
+	case ed25519.PrivateKey:
+		key = jwtKey{method: jwt.SigningMethodEdDSA, signKey: typed, verifyKey: typed.Public().(ed25519.PublicKey)}
+	case ed25519.PublicKey:
+		key = jwtKey{method: jwt.SigningMethodEdDSA, verifyKey: typed}
+	default:
+		return jwtKey{}, fmt.Errorf("unsupported key type %T, use RSA or Ed25519", parsed)
+	}
+
+	if publicKey, ok := key.verifyKey.(*rsa.PublicKey); ok && publicKey.N.BitLen() < minRSAKeyBits {
+		return jwtKey{}, fmt.Errorf("RSA keys must be at least %d bits", minRSAKeyBits)
+	}
+
+	key.id = key.thumbprint()
+	return key, nil
+}
+
+// verificationKey returns the key that may have signed the token, matching
+// its kid header and algorithm.
+func (k *keySet) verificationKey(token *jwt.Token, now time.Time) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	candidates := []jwtKey{k.current}
+	if now.Before(k.previousUntil) {
+		candidates = append(candidates, k.previous...)
+	}
+
+	for _, key := range candidates {
+		if key.id == kid && token.Method.Alg() == key.method.Alg() {
+			return key.verifyKey, nil
+		}
+	}
+	return nil, errors.New("unknown signing key")
+}
+
+// jwk returns the key's public half as a JWK, or false for HMAC secrets, which
+// must never be published.
+func (k jwtKey) jwk() (JWK, bool) {
+	switch publicKey := k.verifyKey.(type) {
+	case *rsa.PublicKey:
+		return JWK{
+			KeyType:   "RSA",
+			KeyID:     k.id,
+			Use:       "sig",
+			Algorithm: k.method.Alg(),
+			N:         base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes()),
+			E:         base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes()),
+		}, true
+	case ed25519.PublicKey:
+		return JWK{
+			KeyType:   "OKP",
+			KeyID:     k.id,
+			Use:       "sig",
+			Algorithm: k.method.Alg(),
+			Curve:     "Ed25519",
+			X:         base64.RawURLEncoding.EncodeToString(publicKey),
+		}, true
+	default:
+		return JWK{}, false
+	}
+}
+
+// thumbprint is the key's RFC 7638 JWK thumbprint, used as its kid.
+func (k jwtKey) thumbprint() string {
+	jwk, ok := k.jwk()
+	if !ok {
+		return ""
+	}
+
+	var canonical string
+	switch jwk.KeyType {
+	case "RSA":
+		canonical = fmt.Sprintf(`{"e":%q,"kty":"RSA","n":%q}`, jwk.E, jwk.N)
+	case "OKP":
+		canonical = fmt.Sprintf(`{"crv":%q,"kty":"OKP","x":%q}`, jwk.Curve, jwk.X)
+	}
+	sum := sha256.Sum256([]byte(canonical))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// PublicKeys returns the public keys tokens issued by this API can be verified
+// with: the current key and any previous key still accepted. It is empty
+// while tokens are signed with JWT_SECRET.
+func PublicKeys() (JWKSet, error) {
+	keys, err := currentKeySet()
+	if err != nil {
+		return JWKSet{}, err
+	}
+
+	candidates := []jwtKey{keys.current}
+	if time.Now().Before(keys.previousUntil) {
+		candidates = append(candidates, keys.previous...)
+	}
+
+	set := JWKSet{Keys: []JWK{}}
+	for _, key := range candidates {
+		if jwk, ok := key.jwk(); ok {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+	return set, nil
+}
@@ -0,0 +1,170 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func writeTestKey(t *testing.T, name string, blockType string, der []byte) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatalf("write key %s: %v", name, err)
+	}
+	return path
+}
+
+func writeTestEd25519Key(t *testing.T, name string) (string, ed25519.PublicKey) {
+	t.Helper()
+
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate ed25519 key: %v", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		t.Fatalf("marshal ed25519 key: %v", err)
+	}
+	return writeTestKey(t, name, "PRIVATE KEY", der), publicKey
+}
+
+func tokenKeyID(t *testing.T, token string) string {
+	t.Helper()
+
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, &tokenClaims{})
+	if err != nil {
+		t.Fatalf("parse token: %v", err)
+	}
+	kid, _ := parsed.Header["kid"].(string)
+	return kid
+}
+
+func TestRSASigningKeyIsPublished(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate rsa key: %v", err)
+	}
+	t.Setenv(signingKeyFileEnvVar, writeTestKey(t, "rsa.pem", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(privateKey)))
+
+	if err := ValidateJWTConfig(); err != nil {
+		t.Fatalf("ValidateJWTConfig returned error: %v", err)
+	}
+
+	token, err := CreateAccessToken(42)
+	if err != nil {
+		t.Fatalf("CreateAccessToken returned error: %v", err)
+	}
+	if userID, err := ValidateToken(token); err != nil || userID != 42 {
+		t.Fatalf("ValidateToken returned %d, %v", userID, err)
+	}
+
+	keys, err := PublicKeys()
+	if err != nil {
+		t.Fatalf("PublicKeys returned error: %v", err)
+	}
+	if len(keys.Keys) != 1 {
+		t.Fatalf("expected 1 key, got %+v", keys)
+	}
+	key := keys.Keys[0]
+	if key.KeyType != "RSA" || key.Algorithm != "RS256" || key.E != "AQAB" || key.KeyID != tokenKeyID(t, token) {
+		t.Fatalf("unexpected key %+v", key)
+	}
+}
+
+func TestPreviousKeysAreAcceptedDuringTheGracePeriod(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+	hmacToken, err := CreateAccessToken(42)
+	if err != nil {
+		t.Fatalf("CreateAccessToken returned error: %v", err)
+	}
+
+	oldKey, _ := writeTestEd25519Key(t, "old.pem")
+	newKey, _ := writeTestEd25519Key(t, "new.pem")
+
+	t.Setenv(signingKeyFileEnvVar, oldKey)
+	oldToken, err := CreateAccessToken(42)
+	if err != nil {
+		t.Fatalf("CreateAccessToken returned error: %v", err)
+	}
+	if _, err := ValidateToken(hmacToken); err == nil {
+		t.Fatal("expected tokens signed with JWT_SECRET to be rejected once a key file is set")
+	}
+
+	t.Setenv(signingKeyFileEnvVar, newKey)
+	t.Setenv(previousKeyFilesEnvVar, oldKey)
+	t.Setenv(previousKeysUntilEnvVar, time.Now().Add(time.Hour).Format(time.RFC3339))
+
+	newToken, err := CreateAccessToken(42)
+	if err != nil {
+		t.Fatalf("CreateAccessToken returned error: %v", err)
+	}
+	if tokenKeyID(t, newToken) == tokenKeyID(t, oldToken) {
+		t.Fatal("expected the new key to have its own kid")
+	}
+	for _, token := range []string{oldToken, newToken} {
+		if _, err := ValidateToken(token); err != nil {
+			t.Fatalf("ValidateToken returned error during the grace period: %v", err)
+		}
+	}
+	if keys, err := PublicKeys(); err != nil || len(keys.Keys) != 2 || keys.Keys[0].Algorithm != "EdDSA" {
+		t.Fatalf("expected both keys to be published, got %+v, %v", keys, err)
+	}
+
+	t.Setenv(previousKeysUntilEnvVar, time.Now().Add(-time.Minute).Format(time.RFC3339))
+	if _, err := ValidateToken(oldToken); err == nil {
+		t.Fatal("expected the previous key to be rejected after the grace period")
+	}
+	if keys, err := PublicKeys(); err != nil || len(keys.Keys) != 1 {
+		t.Fatalf("expected only the new key to be published, got %+v, %v", keys, err)
+	}
+}
+
+func TestValidateJWTConfigRejectsUnusableKeys(t *testing.T) {
+	signingKey, publicKey := writeTestEd25519Key(t, "signing.pem")
+	publicDER, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		t.Fatalf("marshal public key: %v", err)
+	}
+	publicKeyFile := writeTestKey(t, "public.pem", "PUBLIC KEY", publicDER)
+
+	smallKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("generate rsa key: %v", err)
+	}
+	smallKeyFile := writeTestKey(t, "small.pem", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(smallKey))
+
+	tests := []struct {
+		name          string
+		signingKey    string
+		previousKeys  string
+		previousUntil string
+	}{
+		{name: "missing file", signingKey: filepath.Join(t.TempDir(), "missing.pem")},
+		{name: "public signing key", signingKey: publicKeyFile},
+		{name: "small rsa key", signingKey: smallKeyFile},
+		{name: "previous keys without grace period", signingKey: signingKey, previousKeys: publicKeyFile},
+		{name: "invalid grace period", signingKey: signingKey, previousKeys: publicKeyFile, previousUntil: "tomorrow"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(signingKeyFileEnvVar, tt.signingKey)
+			t.Setenv(previousKeyFilesEnvVar, tt.previousKeys)
+			t.Setenv(previousKeysUntilEnvVar, tt.previousUntil)
+
+			if err := ValidateJWTConfig(); err == nil {
+				t.Fatal("ValidateJWTConfig expected error")
+			}
+		})
+	}
+}
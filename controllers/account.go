@@ -0,0 +1,222 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/akctba/secret-santa-go-api/database"
+	"github.com/akctba/secret-santa-go-api/models"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// maxGenderLength is the longest gender, in characters. Gender is free text.
+const maxGenderLength = 50
+
+// updateProfileRequest only changes the fields present. Email addresses cannot
+// be changed here.
+type updateProfileRequest struct {
+	UserName    *string      `json:"user_name"`
+	Gender      *string      `json:"gender"`
+	DateOfBirth optionalTime `json:"date_of_birth"`
+}
+
+type changePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+// loadAuthenticatedUser returns the authenticated user. It writes the error
+// response and returns false when there is none.
+func loadAuthenticatedUser(w http.ResponseWriter, r *http.Request, db *sql.DB) (models.User, bool) {
+	userID, ok := authenticatedUserIDFromRequest(r)
+	if !ok {
+		http.Error(w, "Invalid token", http.StatusUnauthorized)
+		return models.User{}, false
+	}
+
+	user, err := database.GetUserByID(db, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return user, false
+		}
+
+		http.Error(w, "Failed to get user", http.StatusInternalServerError)
+		return user, false
+	}
+
+	return user, true
+}
+
+// GetMe handles GET /user/me. Returns the authenticated user's profile.
+func GetMe(w http.ResponseWriter, r *http.Request) {
+	db, err := getDB()
+	if err != nil {
+		log.Printf("failed to open db in GetMe: %v", err)
+		http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
+		return
+	}
+	defer database.CloseDb(db)
+
+	user, ok := loadAuthenticatedUser(w, r, db)
+	if !ok {
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(toUserResponse(user))
+}
+
+// UpdateMe handles PATCH /user/me. Lets users change their name, gender and date of birth.
+func UpdateMe(w http.ResponseWriter, r *http.Request) {
+	var request updateProfileRequest
+	if err := decodeRequestJSON(r, &request); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	db, err := getDB()
+	if err != nil {
+		log.Printf("failed to open db in UpdateMe: %v", err)
+		http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
+		return
+	}
+	defer database.CloseDb(db)
+
+	user, ok := loadAuthenticatedUser(w, r, db)
+	if !ok {
+		return
+	}
+
+	if request.UserName != nil {
+		user.UserName = strings.TrimSpace(*request.UserName)
+		if user.UserName == "" {
+			http.Error(w, "user_name is required", http.StatusBadRequest)
+			return
+		}
+	}
+	if request.Gender != nil {
+		user.Gender = strings.TrimSpace(*request.Gender)
+		if utf8.RuneCountInString(user.Gender) > maxGenderLength {
+			http.Error(w, "gender must be at most 50 characters", http.StatusBadRequest)
+			return
+		}
+	}
+	if request.DateOfBirth.Set {
+		user.DateOfBirth = time.Time{}
+		if request.DateOfBirth.Value != nil {
+			user.DateOfBirth = *request.DateOfBirth.Value
+		}
+		if user.DateOfBirth.After(time.Now()) {
+			http.Error(w, "date_of_birth must not be in the future", http.StatusBadRequest)
+			return
+		}
+	}
+
+	err = database.UpdateUser(db, user)
+	if err != nil {
+		http.Error(w, "Failed to update user", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(toUserResponse(user))
+}
+
+// ChangePassword handles POST /user/me/password. Replaces the password once the current one
+// has been confirmed and, like ResetPassword, signs out every session by revoking the user's
+// refresh tokens. Access tokens already issued stay valid until they expire.
+func ChangePassword(w http.ResponseWriter, r *http.Request) {
+	var request changePasswordRequest
+	if err := decodeRequestJSON(r, &request); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if request.CurrentPassword == "" || request.NewPassword == "" {
+		http.Error(w, "current_password and new_password are required", http.StatusBadRequest)
+		return
+	}
+
+	db, err := getDB()
+	if err != nil {
+		log.Printf("failed to open db in ChangePassword: %v", err)
+		http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
+		return
+	}
+	defer database.CloseDb(db)
+
+	user, ok := loadAuthenticatedUser(w, r, db)
+	if !ok {
+		return
+	}
+
+	// 403 rather than 401: the session is fine, only the confirmation failed.
+	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(request.CurrentPassword))
+	if err != nil {
+		http.Error(w, "Current password is incorrect", http.StatusForbidden)
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(request.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		http.Error(w, "Failed to hash password", http.StatusInternalServerError)
+		return
+	}
+
+	err = database.ChangeUserPassword(db, user.UserID, string(hashedPassword), time.Now().UTC())
+	if err != nil {
+		http.Error(w, "Failed to update password", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DeleteMe handles DELETE /user/me. Deletes the account along with its wishlists and messages,
+// and takes the user out of every group. Owners must transfer or delete their groups first.
+func DeleteMe(w http.ResponseWriter, r *http.Request) {
+	db, err := getDB()
+	if err != nil {
+		log.Printf("failed to open db in DeleteMe: %v", err)
+		http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
+		return
+	}
+	defer database.CloseDb(db)
+
+	user, ok := loadAuthenticatedUser(w, r, db)
+	if !ok {
+		return
+	}
+
+	relinked, err := database.DeleteUserAccount(db, user.UserID)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrUserOwnsGroups):
+			http.Error(w, "Transfer or delete the groups you own before deleting your account", http.StatusConflict)
+		case errors.Is(err, database.ErrDrawCannotRelink):
+			http.Error(w, "A draw you take part in cannot be kept without you, ask its organizers to reset it first",
+				http.StatusConflict)
+		default:
+			http.Error(w, "Failed to delete user", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	for _, participant := range relinked {
+		group, err := database.GetGroupByID(db, participant.GroupID)
+		if err != nil {
+			log.Printf("failed to load group %s for reassigned email: %v", participant.GroupID, err)
+			continue
+		}
+		notifyReassigned(db, group, participant)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,127 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/akctba/secret-santa-go-api/database"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func serveAccountRequest(t *testing.T, handler http.HandlerFunc, method string, userID int, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(method, "/user/me", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req = withAuthenticatedUser(req, userID)
+
+	rr := httptest.NewRecorder()
+	handler(rr, req)
+	return rr
+}
+
+func TestUpdateMeChangesProfile(t *testing.T) {
+	db := setupMigratedTestDB(t)
+	seedGroupWithParticipants(t, db, 1, 1, 1)
+
+	rr := serveAccountRequest(t, UpdateMe, http.MethodPatch, 1, `{"user_name":" Alice ","gender":"female","date_of_birth":"1990-05-01T00:00:00Z"}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d, body: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	payload := decodeJSONBody(t, serveAccountRequest(t, GetMe, http.MethodGet, 1, "").Body.String())
+	if payload["user_name"] != "Alice" || payload["gender"] != "female" || payload["date_of_birth"] != "1990-05-01T00:00:00Z" {
+		t.Fatalf("expected the profile to be saved, got %v", payload)
+	}
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{name: "empty name", body: `{"user_name":""}`},
+		{name: "future birth date", body: `{"date_of_birth":"2999-01-01T00:00:00Z"}`},
+		{name: "email", body: `{"email":"other@example.com"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := serveAccountRequest(t, UpdateMe, http.MethodPatch, 1, tt.body)
+			if rr.Code != http.StatusBadRequest {
+				t.Fatalf("expected status %d, got %d, body: %s", http.StatusBadRequest, rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestChangePasswordRequiresCurrentPassword(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+	db := setupMigratedTestDB(t)
+	seedGroupWithParticipants(t, db, 1, 1, 1)
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte("old-secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("hash password: %v", err)
+	}
+	if _, err := db.Exec(`UPDATE Users SET password = ? WHERE user_id = 1`, string(hashedPassword)); err != nil {
+		t.Fatalf("set password: %v", err)
+	}
+
+	rr := serveAccountRequest(t, ChangePassword, http.MethodPost, 1, `{"current_password":"wrong","new_password":"new-secret"}`)
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("expected status %d, got %d, body: %s", http.StatusForbidden, rr.Code, rr.Body.String())
+	}
+
+	refreshToken, err := issueRefreshToken(db, 1)
+	if err != nil {
+		t.Fatalf("issueRefreshToken returned error: %v", err)
+	}
+
+	rr = serveAccountRequest(t, ChangePassword, http.MethodPost, 1, `{"current_password":"old-secret","new_password":"new-secret"}`)
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d, got %d, body: %s", http.StatusNoContent, rr.Code, rr.Body.String())
+	}
+	if _, code := refreshTestToken(t, refreshToken); code != http.StatusUnauthorized {
+		t.Fatalf("expected refresh tokens issued before the change to be revoked, got status %d", code)
+	}
+
+	user, err := database.GetUserByID(db, 1)
+	if err != nil {
+		t.Fatalf("load user: %v", err)
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte("new-secret")) != nil {
+		t.Fatal("expected the new password to be saved")
+	}
+}
+
+func TestDeleteMeLeavesGroupsAndKeepsDraws(t *testing.T) {
+	db := setupMigratedTestDB(t)
+	seedNamedGroup(t, db)
+	recorder := withRecordingMailer(t)
+
+	if rr := runTestDraw(t, ""); rr.Code != http.StatusCreated {
+		t.Fatalf("run draw: status %d, body: %s", rr.Code, rr.Body.String())
+	}
+	recorder.messages = nil
+
+	if rr := serveAccountRequest(t, DeleteMe, http.MethodDelete, 1, ""); rr.Code != http.StatusConflict {
+		t.Fatalf("expected status %d for the group owner, got %d, body: %s", http.StatusConflict, rr.Code, rr.Body.String())
+	}
+
+	friend := testFriendOf(t, db, 2)
+	giver := 4 - friend
+	if rr := serveAccountRequest(t, DeleteMe, http.MethodDelete, 2, ""); rr.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d, got %d, body: %s", http.StatusNoContent, rr.Code, rr.Body.String())
+	}
+
+	if got := testFriendOf(t, db, giver); got != friend {
+		t.Fatalf("expected user %d to draw user %d, got %d", giver, friend, got)
+	}
+	if len(recorder.messages) != 1 {
+		t.Fatalf("expected the giver to be emailed, got %+v", recorder.messages)
+	}
+	if rr := serveAccountRequest(t, GetMe, http.MethodGet, 2, ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected status %d once deleted, got %d", http.StatusNotFound, rr.Code)
+	}
+}
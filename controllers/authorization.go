@@ -0,0 +1,79 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/akctba/secret-santa-go-api/database"
+	"github.com/akctba/secret-santa-go-api/models"
+)
+
+// groupAccess is the level of access a handler requires on a group.
+type groupAccess int
+
+const (
+	// groupAccessMember allows everyone taking part in the group.
+	groupAccessMember groupAccess = iota + 1
+	// groupAccessOrganizer allows the owner and the organizers the owner appointed.
+	groupAccessOrganizer
+	// groupAccessOwner allows only the group's owner.
+	groupAccessOwner
+)
+
+// roleAccess maps a participant role to the access it grants.
+var roleAccess = map[string]groupAccess{
+	models.RoleMember:    groupAccessMember,
+	models.RoleOrganizer: groupAccessOrganizer,
+	models.RoleOwner:     groupAccessOwner,
+}
+
+// authorizeGroup loads the group and checks that the authenticated user has the
+// required access to it. Unless it reports true, it has already written a 401,
+// 403, 404 or 500 response.
+func authorizeGroup(w http.ResponseWriter, r *http.Request, db *sql.DB, groupID string, required groupAccess) (models.Group, bool) {
+	userID, ok := authenticatedUserIDFromRequest(r)
+	if !ok {
+		http.Error(w, "Invalid token", http.StatusUnauthorized)
+		return models.Group{}, false
+	}
+
+	group, err := database.GetGroupByID(db, groupID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Group not found", http.StatusNotFound)
+			return group, false
+		}
+
+		http.Error(w, "Failed to get group", http.StatusInternalServerError)
+		return group, false
+	}
+
+	// The creator owns the group even without taking part in the draw.
+	if group.CreatorUserID == userID {
+		return group, true
+	}
+
+	role, err := database.GetParticipantRole(db, userID, group.GroupID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "User is not a member of this group", http.StatusForbidden)
+			return group, false
+		}
+
+		http.Error(w, "Failed to get participant", http.StatusInternalServerError)
+		return group, false
+	}
+
+	if roleAccess[role] < required {
+		switch required {
+		case groupAccessOwner:
+			http.Error(w, "Only the group owner can do this", http.StatusForbidden)
+		default:
+			http.Error(w, "Only group organizers can manage this group", http.StatusForbidden)
+		}
+		return group, false
+	}
+
+	return group, true
+}
@@ -0,0 +1,72 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/akctba/secret-santa-go-api/models"
+)
+
+// currencyCodes are the active ISO 4217 currency codes.
+var currencyCodes = func() map[string]bool {
+	codes := map[string]bool{}
+	for _, code := range strings.Fields(`
+		AED AFN ALL AMD ANG AOA ARS AUD AWG AZN BAM BBD BDT BGN BHD BIF BMD BND BOB BRL BSD BTN BWP BYN BZD
+		CAD CDF CHF CLP CNY COP CRC CUP CVE CZK DJF DKK DOP DZD EGP ERN ETB EUR FJD FKP GBP GEL GHS GIP GMD
+		GNF GTQ GYD HKD HNL HTG HUF IDR ILS INR IQD IRR ISK JMD JOD JPY KES KGS KHR KMF KPW KRW KWD KYD KZT
+		LAK LBP LKR LRD LSL LYD MAD MDL MGA MKD MMK MNT MOP MRU MUR MVR MWK MXN MYR MZN NAD NGN NIO NOK NPR
+		NZD OMR PAB PEN PGK PHP PKR PLN PYG QAR RON RSD RUB RWF SAR SBD SCR SDG SEK SGD SHP SLE SOS SRD SSP
+		STN SVC SYP SZL THB TJS TMT TND TOP TRY TTD TWD TZS UAH UGX USD UYU UZS VES VND VUV WST XAF XCD XCG
+		XOF XPF YER ZAR ZMW ZWG`) {
+		codes[code] = true
+	}
+	return codes
+}()
+
+// validateGroupBudget normalizes the group's currency and checks its budget. The
+// returned error is safe to show to the client.
+func validateGroupBudget(group *models.Group) error {
+	group.Currency = strings.ToUpper(strings.TrimSpace(group.Currency))
+
+	if group.BudgetMin < 0 || group.BudgetMax < 0 {
+		return errors.New("budget must not be negative")
+	}
+	if group.BudgetMax != 0 && group.BudgetMax < group.BudgetMin {
+		return errors.New("budget_max must not be lower than budget_min")
+	}
+	if group.Currency != "" && !currencyCodes[group.Currency] {
+		return errors.New("currency must be an ISO 4217 code")
+	}
+	if group.Currency == "" && (group.BudgetMin != 0 || group.BudgetMax != 0) {
+		return errors.New("currency is required with a budget")
+	}
+
+	return nil
+}
+
+// checkWithinBudget rejects prices outside the group's budget. Unset prices and
+// budget limits are not checked.
+func checkWithinBudget(group models.Group, prices ...float64) error {
+	for _, price := range prices {
+		if price == 0 {
+			continue
+		}
+		if price < group.BudgetMin || (group.BudgetMax != 0 && price > group.BudgetMax) {
+			return fmt.Errorf("price must be within the group's budget of %s", formatBudget(group))
+		}
+	}
+	return nil
+}
+
+func formatBudget(group models.Group) string {
+	amount := func(value float64) string {
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	}
+
+	if group.BudgetMax == 0 {
+		return fmt.Sprintf("at least %s %s", amount(group.BudgetMin), group.Currency)
+	}
+	return fmt.Sprintf("%s-%s %s", amount(group.BudgetMin), amount(group.BudgetMax), group.Currency)
+}
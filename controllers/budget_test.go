@@ -0,0 +1,109 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func createTestGroup(t *testing.T, userID int, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodPost, "/group", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req = withAuthenticatedUser(req, userID)
+
+	rr := httptest.NewRecorder()
+	CreateGroup(rr, req)
+	return rr
+}
+
+func TestCreateGroupValidatesBudget(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{name: "negative", body: `{"name":"xmas","budget_min":-5,"currency":"EUR"}`, want: "budget must not be negative"},
+		{name: "inverted range", body: `{"name":"xmas","budget_min":50,"budget_max":20,"currency":"EUR"}`, want: "budget_max must not be lower than budget_min"},
+		{name: "unknown currency", body: `{"name":"xmas","budget_max":20,"currency":"EURO"}`, want: "currency must be an ISO 4217 code"},
+		{name: "missing currency", body: `{"name":"xmas","budget_max":20}`, want: "currency is required with a budget"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := createTestGroup(t, 1, tt.body)
+			if rr.Code != http.StatusBadRequest {
+				t.Fatalf("expected status %d, got %d, body: %s", http.StatusBadRequest, rr.Code, rr.Body.String())
+			}
+			if !strings.Contains(rr.Body.String(), tt.want) {
+				t.Fatalf("expected error %q, got: %s", tt.want, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestGetGroupReturnsBudget(t *testing.T) {
+	setupMigratedTestDB(t)
+
+	rr := createTestGroup(t, 1, `{"name":"xmas","budget_min":10,"budget_max":25,"currency":"eur"}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d, body: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+
+	groupID := decodeJSONBody(t, rr.Body.String())["group_id"].(string)
+	req := httptest.NewRequest(http.MethodGet, "/group/"+groupID, nil)
+	req = mux.SetURLVars(req, map[string]string{"id": groupID})
+	req = withAuthenticatedUser(req, 1)
+	rr = httptest.NewRecorder()
+	GetGroup(rr, req)
+
+	payload := decodeJSONBody(t, rr.Body.String())
+	if payload["budget_min"] != float64(10) || payload["budget_max"] != float64(25) || payload["currency"] != "EUR" {
+		t.Fatalf("expected the budget in the group, got: %s", rr.Body.String())
+	}
+}
+
+func TestWishlistItemsStayWithinGroupBudget(t *testing.T) {
+	db := setupMigratedTestDB(t)
+	seedGroupWithParticipants(t, db, 1, 1, 1, 2)
+	if _, err := db.Exec(`UPDATE Groups SET budget_min = 10, budget_max = 25, currency = 'EUR' WHERE group_id = 1`); err != nil {
+		t.Fatalf("set group budget: %v", err)
+	}
+
+	for _, body := range []string{`{"group_id":"1","title":"Office party"}`, `{"title":"Anything"}`} {
+		if rr := serveWishlistRequest(t, CreateWishlist, http.MethodPost, "/wishlist", nil, 2, body); rr.Code != http.StatusCreated {
+			t.Fatalf("create wishlist: status %d, body: %s", rr.Code, rr.Body.String())
+		}
+	}
+	groupWishlist := map[string]string{"wishlistId": "1"}
+	generalWishlist := map[string]string{"wishlistId": "2"}
+
+	tests := []struct {
+		name     string
+		vars     map[string]string
+		body     string
+		wantCode int
+	}{
+		{name: "within budget", vars: groupWishlist, body: `{"title":"Book","price_min":12,"price_max":25}`, wantCode: http.StatusCreated},
+		{name: "no price", vars: groupWishlist, body: `{"title":"Surprise me"}`, wantCode: http.StatusCreated},
+		{name: "too expensive", vars: groupWishlist, body: `{"title":"Console","price_min":20,"price_max":300}`, wantCode: http.StatusBadRequest},
+		{name: "too cheap", vars: groupWishlist, body: `{"title":"Sticker","price_min":2}`, wantCode: http.StatusBadRequest},
+		{name: "general wishlist", vars: generalWishlist, body: `{"title":"Console","price_min":300}`, wantCode: http.StatusCreated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := serveWishlistRequest(t, CreateWishlistItem, http.MethodPost, "/wishlist/item", tt.vars, 2, tt.body)
+			if rr.Code != tt.wantCode {
+				t.Fatalf("expected status %d, got %d, body: %s", tt.wantCode, rr.Code, rr.Body.String())
+			}
+			if tt.wantCode == http.StatusBadRequest && !strings.Contains(rr.Body.String(), "within the group's budget of 10-25 EUR") {
+				t.Fatalf("expected budget error, got: %s", rr.Body.String())
+			}
+		})
+	}
+}
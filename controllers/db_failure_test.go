@@ -90,6 +90,7 @@ func TestHandlersReturnInternalServerErrorWhenDBUnavailable(t *testing.T) {
 			if tc.vars != nil {
 				req = mux.SetURLVars(req, tc.vars)
 			}
+			req = withAuthenticatedUser(req, 1)
 
 			rr := httptest.NewRecorder()
 			tc.handler(rr, req)
@@ -0,0 +1,234 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/akctba/secret-santa-go-api/database"
+	"github.com/akctba/secret-santa-go-api/draw"
+	"github.com/akctba/secret-santa-go-api/models"
+	"github.com/akctba/secret-santa-go-api/notify"
+	"github.com/gorilla/mux"
+)
+
+// idempotencyKeyHeader lets clients safely retry POST /group/{id}/draw.
+const idempotencyKeyHeader = "Idempotency-Key"
+
+// newDrawSolver is swapped for a seeded solver in tests.
+var newDrawSolver = draw.NewSolver
+
+var errNoParticipants = errors.New("no participants to draw")
+
+// computeDraw loads the group's participants and exclusions and solves a new
+// assignment. It returns the participants with FriendUserID set and the name
+// of the algorithm used, without persisting anything.
+func computeDraw(db *sql.DB, groupID string) ([]models.Participant, string, error) {
+	participants, err := database.GetParticipantsToDraw(db, groupID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if len(participants) == 0 {
+		return nil, "", errNoParticipants
+	}
+
+	if err := checkParticipantsVerified(db, groupID); err != nil {
+		return nil, "", err
+	}
+
+	exclusions, err := database.GetExclusionsByGroupID(db, groupID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	userIDs := make([]int, len(participants))
+	for i, participant := range participants {
+		userIDs[i] = participant.UserID
+	}
+
+	solver := newDrawSolver()
+
+	// With more than two participants nobody should end up exchanging gifts
+	// only with each other, matching the single-circle draw this replaced.
+	assignment, err := solver.Solve(userIDs, draw.Constraints{
+		Forbidden:    draw.ExclusionPairs(exclusions),
+		NoReciprocal: len(userIDs) > 2,
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	for i := range participants {
+		participants[i].FriendUserID = assignment[participants[i].UserID]
+	}
+
+	return participants, solver.Name(), nil
+}
+
+// RunScheduledDraw draws a group whose date_draw has passed on behalf of the
+// scheduler, the same way RunDraw does for an organizer.
+func RunScheduledDraw(db *sql.DB, group models.Group) error {
+	participants, algorithm, err := computeDraw(db, group.GroupID)
+	if err != nil {
+		return err
+	}
+
+	record := models.Draw{
+		GroupID:   group.GroupID,
+		RunAt:     time.Now().UTC(),
+		Algorithm: algorithm,
+	}
+
+	if err := database.InsertDraw(db, &record, participants); err != nil {
+		return err
+	}
+
+	if err := notifyGroup(db, group, notify.KindDrawn); err != nil {
+		log.Printf("failed to send draw emails for group %s: %v", group.GroupID, err)
+	}
+
+	return nil
+}
+
+// writeDrawError maps errors from computing or saving a draw to a response.
+func writeDrawError(w http.ResponseWriter, err error) {
+	var unsatisfiable *draw.UnsatisfiableError
+	var unverified *unverifiedParticipantsError
+	switch {
+	case errors.Is(err, errNoParticipants):
+		http.Error(w, "No participants to draw", http.StatusBadRequest)
+	case errors.As(err, &unverified):
+		http.Error(w, "Participants must verify their email address before the draw: "+
+			strings.Join(unverified.Participants, ", "), http.StatusUnprocessableEntity)
+	case errors.As(err, &unsatisfiable):
+		http.Error(w, "Draw cannot satisfy the group's exclusions: "+strings.Join(unsatisfiable.Reasons, "; "),
+			http.StatusUnprocessableEntity)
+	case errors.Is(err, database.ErrDrawExists):
+		http.Error(w, "Draw has already been run for this group", http.StatusConflict)
+	case errors.Is(err, database.ErrDrawNotFound):
+		http.Error(w, "Group has not been drawn yet", http.StatusConflict)
+	case errors.Is(err, database.ErrDrawParticipantsChanged):
+		http.Error(w, "Participants changed during the draw, please retry", http.StatusConflict)
+	default:
+		log.Printf("draw failed: %v", err)
+		http.Error(w, "Failed to run draw", http.StatusInternalServerError)
+	}
+}
+
+// ResetDraw handles POST /group/{id}/draw/reset. Lets an organizer clear every assignment
+// so the group can be drawn again; the reset draw stays in the group's history.
+func ResetDraw(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	groupID := vars["id"]
+
+	userID, ok := authenticatedUserIDFromRequest(r)
+	if !ok {
+		http.Error(w, "Invalid token", http.StatusUnauthorized)
+		return
+	}
+
+	db, err := getDB()
+	if err != nil {
+		log.Printf("failed to open db in ResetDraw: %v", err)
+		http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
+		return
+	}
+	defer database.CloseDb(db)
+
+	if _, ok := authorizeGroup(w, r, db, groupID, groupAccessOrganizer); !ok {
+		return
+	}
+
+	err = database.ResetDraw(db, groupID, userID)
+	if err != nil {
+		writeDrawError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Redraw handles POST /group/{id}/redraw. Lets an organizer replace the group's draw with
+// a new one in a single transaction; the replaced draw stays in the group's history.
+func Redraw(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	groupID := vars["id"]
+
+	userID, ok := authenticatedUserIDFromRequest(r)
+	if !ok {
+		http.Error(w, "Invalid token", http.StatusUnauthorized)
+		return
+	}
+
+	db, err := getDB()
+	if err != nil {
+		log.Printf("failed to open db in Redraw: %v", err)
+		http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
+		return
+	}
+	defer database.CloseDb(db)
+
+	group, ok := authorizeGroup(w, r, db, groupID, groupAccessOrganizer)
+	if !ok {
+		return
+	}
+
+	participants, algorithm, err := computeDraw(db, groupID)
+	if err != nil {
+		writeDrawError(w, err)
+		return
+	}
+
+	record := models.Draw{
+		GroupID:   groupID,
+		RunAt:     time.Now().UTC(),
+		RunBy:     userID,
+		Algorithm: algorithm,
+	}
+
+	err = database.Redraw(db, &record, participants)
+	if err != nil {
+		writeDrawError(w, err)
+		return
+	}
+
+	if err := notifyGroup(db, group, notify.KindDrawn); err != nil {
+		log.Printf("failed to send draw emails for group %s: %v", group.GroupID, err)
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(record)
+}
+
+// GetDraws handles GET /group/{id}/draw. Returns every draw of the group to its organizers,
+// oldest first, so they can see when a draw was reset or replaced.
+func GetDraws(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	groupID := vars["id"]
+
+	db, err := getDB()
+	if err != nil {
+		log.Printf("failed to open db in GetDraws: %v", err)
+		http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
+		return
+	}
+	defer database.CloseDb(db)
+
+	if _, ok := authorizeGroup(w, r, db, groupID, groupAccessOrganizer); !ok {
+		return
+	}
+
+	draws, err := database.GetDrawsByGroupID(db, groupID)
+	if err != nil {
+		http.Error(w, "Failed to get draws", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(draws)
+}
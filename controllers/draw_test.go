@@ -0,0 +1,205 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/akctba/secret-santa-go-api/database"
+	"github.com/gorilla/mux"
+)
+
+func TestRunDrawRecordsDrawAndRejectsSecondDraw(t *testing.T) {
+	db := setupMigratedTestDB(t)
+	seedGroupWithParticipants(t, db, 1, 1, 1, 2, 3)
+
+	rr := runTestDraw(t, "")
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d, body: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+
+	payload := decodeJSONBody(t, rr.Body.String())
+	if payload["run_by"] != float64(1) || payload["algorithm"] != "backtracking" || payload["group_id"] != "1" {
+		t.Fatalf("unexpected draw payload: %s", rr.Body.String())
+	}
+
+	var draws int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM Draws WHERE group_id = 1`).Scan(&draws); err != nil {
+		t.Fatalf("count draws: %v", err)
+	}
+	if draws != 1 {
+		t.Fatalf("expected 1 draw record, got %d", draws)
+	}
+
+	rr = runTestDraw(t, "")
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("expected status %d, got %d, body: %s", http.StatusConflict, rr.Code, rr.Body.String())
+	}
+	if !strings.Contains(rr.Body.String(), "Draw has already been run for this group") {
+		t.Fatalf("expected already-drawn error, got: %s", rr.Body.String())
+	}
+}
+
+func TestRunDrawReplaysDrawForMatchingIdempotencyKey(t *testing.T) {
+	db := setupMigratedTestDB(t)
+	seedGroupWithParticipants(t, db, 1, 1, 1, 2, 3)
+
+	first := runTestDraw(t, "draw-2026")
+	if first.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d, body: %s", http.StatusCreated, first.Code, first.Body.String())
+	}
+
+	replay := runTestDraw(t, "draw-2026")
+	if replay.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d, body: %s", http.StatusOK, replay.Code, replay.Body.String())
+	}
+
+	if decodeJSONBody(t, first.Body.String())["draw_id"] != decodeJSONBody(t, replay.Body.String())["draw_id"] {
+		t.Fatalf("expected replay to return the original draw, got %s and %s", first.Body.String(), replay.Body.String())
+	}
+
+	other := runTestDraw(t, "another-key")
+	if other.Code != http.StatusConflict {
+		t.Fatalf("expected status %d for a different key, got %d, body: %s", http.StatusConflict, other.Code, other.Body.String())
+	}
+}
+
+func TestRunDrawRejectsGroupDrawnBeforeDrawRecords(t *testing.T) {
+	db := setupMigratedTestDB(t)
+	seedGroupWithParticipants(t, db, 1, 1, 1, 2, 3)
+
+	if _, err := db.Exec(`UPDATE Participants SET friend_user_id = 2 WHERE group_id = 1 AND user_id = 1`); err != nil {
+		t.Fatalf("seed legacy assignment: %v", err)
+	}
+
+	rr := runTestDraw(t, "")
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("expected status %d, got %d, body: %s", http.StatusConflict, rr.Code, rr.Body.String())
+	}
+}
+
+func postGroupDrawAction(t *testing.T, handler http.HandlerFunc, path string, userID int) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodPost, path, nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	req = withAuthenticatedUser(req, userID)
+
+	rr := httptest.NewRecorder()
+	handler(rr, req)
+	return rr
+}
+
+func TestResetDrawClearsAssignmentsAndKeepsHistory(t *testing.T) {
+	db := setupMigratedTestDB(t)
+	seedGroupWithParticipants(t, db, 1, 1, 1, 2, 3)
+
+	if rr := runTestDraw(t, ""); rr.Code != http.StatusCreated {
+		t.Fatalf("run draw: status %d, body: %s", rr.Code, rr.Body.String())
+	}
+
+	rr := postGroupDrawAction(t, ResetDraw, "/group/1/draw/reset", 1)
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d, got %d, body: %s", http.StatusNoContent, rr.Code, rr.Body.String())
+	}
+
+	participant, err := database.GetUserParticipant(db, 1, 1)
+	if err != nil {
+		t.Fatalf("GetUserParticipant returned error: %v", err)
+	}
+	if participant.FriendUserID != 0 {
+		t.Fatalf("expected assignment to be cleared, got friend %d", participant.FriendUserID)
+	}
+
+	rr = postGroupDrawAction(t, ResetDraw, "/group/1/draw/reset", 1)
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("expected status %d resetting an undrawn group, got %d", http.StatusConflict, rr.Code)
+	}
+
+	if rr := runTestDraw(t, ""); rr.Code != http.StatusCreated {
+		t.Fatalf("expected the group to be drawable after reset, got status %d, body: %s", rr.Code, rr.Body.String())
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/group/1/draw", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	req = withAuthenticatedUser(req, 1)
+	rr = httptest.NewRecorder()
+	GetDraws(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d, body: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	var history []map[string]any
+	if err := json.Unmarshal(rr.Body.Bytes(), &history); err != nil {
+		t.Fatalf("decode draw history: %v", err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("expected 2 draws in history, got %d: %s", len(history), rr.Body.String())
+	}
+	if history[0]["reset_at"] == nil || history[0]["reset_by"] != float64(1) {
+		t.Fatalf("expected first draw to be marked as reset, got %v", history[0])
+	}
+	if _, ok := history[1]["reset_at"]; ok {
+		t.Fatalf("expected latest draw to be active, got %v", history[1])
+	}
+}
+
+func TestRedrawReplacesDraw(t *testing.T) {
+	db := setupMigratedTestDB(t)
+	seedGroupWithParticipants(t, db, 1, 1, 1, 2, 3)
+
+	rr := postGroupDrawAction(t, Redraw, "/group/1/redraw", 1)
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("expected status %d redrawing an undrawn group, got %d, body: %s", http.StatusConflict, rr.Code, rr.Body.String())
+	}
+
+	first := runTestDraw(t, "")
+	if first.Code != http.StatusCreated {
+		t.Fatalf("run draw: status %d, body: %s", first.Code, first.Body.String())
+	}
+
+	rr = postGroupDrawAction(t, Redraw, "/group/1/redraw", 1)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d, body: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+
+	active, err := database.GetDrawByGroupID(db, "1")
+	if err != nil {
+		t.Fatalf("GetDrawByGroupID returned error: %v", err)
+	}
+	if float64(active.DrawID) != decodeJSONBody(t, rr.Body.String())["draw_id"] {
+		t.Fatalf("expected redraw to be the active draw, got %+v", active)
+	}
+	if float64(active.DrawID) == decodeJSONBody(t, first.Body.String())["draw_id"] {
+		t.Fatal("expected redraw to create a new draw record")
+	}
+
+	for _, userID := range []int{1, 2, 3} {
+		participant, err := database.GetUserParticipant(db, userID, 1)
+		if err != nil {
+			t.Fatalf("GetUserParticipant(%d) returned error: %v", userID, err)
+		}
+		if participant.FriendUserID == 0 {
+			t.Fatalf("expected user %d to have an assignment after redraw", userID)
+		}
+	}
+}
+
+func TestDrawManagementIsRestrictedToCreator(t *testing.T) {
+	db := setupMigratedTestDB(t)
+	seedGroupWithParticipants(t, db, 1, 1, 1, 2, 3)
+
+	if rr := runTestDraw(t, ""); rr.Code != http.StatusCreated {
+		t.Fatalf("run draw: status %d, body: %s", rr.Code, rr.Body.String())
+	}
+
+	for name, handler := range map[string]http.HandlerFunc{"ResetDraw": ResetDraw, "Redraw": Redraw, "GetDraws": GetDraws} {
+		rr := postGroupDrawAction(t, handler, "/group/1/draw", 2)
+		if rr.Code != http.StatusForbidden {
+			t.Fatalf("%s: expected status %d, got %d, body: %s", name, http.StatusForbidden, rr.Code, rr.Body.String())
+		}
+	}
+}
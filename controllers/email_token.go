@@ -0,0 +1,44 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/url"
+)
+
+// newEmailToken generates the single-use tokens sent by email for password
+// resets, email verification and login links. Tests swap it for a predictable
+// generator.
+var newEmailToken = func() (string, error) {
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(token), nil
+}
+
+// hashToken is what gets stored and looked up instead of a single-use token
+// such as an emailed token or a sign-in state.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// emailTokenLink returns the page with the token added as the token query
+// parameter, or "" when no page is configured.
+func emailTokenLink(pageURL string, token string) string {
+	if pageURL == "" {
+		return ""
+	}
+
+	link, err := url.Parse(pageURL)
+	if err != nil {
+		return ""
+	}
+	query := link.Query()
+	query.Set("token", token)
+	link.RawQuery = query.Encode()
+	return link.String()
+}
@@ -0,0 +1,198 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/akctba/secret-santa-go-api/database"
+	"github.com/akctba/secret-santa-go-api/models"
+	"github.com/akctba/secret-santa-go-api/notify"
+)
+
+// emailVerificationTokenLifetime is how long a verification email stays usable.
+const emailVerificationTokenLifetime = 24 * time.Hour
+
+// emailVerificationURL is the page of the web app that confirms email
+// addresses. The token is added to it as the token query parameter.
+var emailVerificationURL string
+
+// requireEmailVerification keeps users who have not verified their email
+// address out of groups and draws.
+var requireEmailVerification bool
+
+type confirmEmailRequest struct {
+	Token string `json:"token"`
+}
+
+// unverifiedParticipantsError is returned when a group cannot be drawn
+// because some of its participants have not verified their email address.
+type unverifiedParticipantsError struct {
+	Participants []string
+}
+
+func (e *unverifiedParticipantsError) Error() string {
+	return "participants have not verified their email address: " + strings.Join(e.Participants, ", ")
+}
+
+// SetEmailVerificationURL sets the page linked from verification emails.
+// Without one, the email contains the token for the user to paste instead.
+func SetEmailVerificationURL(rawURL string) error {
+	if rawURL != "" && !isWebLink(rawURL) {
+		return fmt.Errorf("%q is not an http or https link", rawURL)
+	}
+	emailVerificationURL = rawURL
+	return nil
+}
+
+// SetRequireEmailVerification turns the verification policy on or off. When
+// on, unverified users cannot be added to groups, accept invites or be drawn.
+// Email invitations wait for a verified address either way.
+func SetRequireEmailVerification(required bool) {
+	requireEmailVerification = required
+}
+
+// sendEmailVerification emails the user a single-use link to confirm their address.
+func sendEmailVerification(db *sql.DB, user models.User) error {
+	token, err := newEmailToken()
+	if err != nil {
+		return err
+	}
+
+	expiresAt := time.Now().UTC().Add(emailVerificationTokenLifetime)
+	err = database.InsertEmailVerificationToken(db, user.UserID, hashToken(token), expiresAt)
+	if err != nil {
+		return err
+	}
+
+	sendNotification(notify.KindVerifyEmail, user.UserEmail, notify.Data{
+		UserName:  user.UserName,
+		Token:     token,
+		TokenURL:  emailTokenLink(emailVerificationURL, token),
+		ExpiresAt: expiresAt,
+	})
+	return nil
+}
+
+// acceptEmailInvitations adds the user to every group their address was
+// invited to. Invitations that fail to convert stay pending, where organizers
+// can still see them.
+func acceptEmailInvitations(db *sql.DB, user models.User) {
+	invitations, err := database.AcceptEmailInvitations(db, user.UserID, user.UserEmail)
+	if err != nil {
+		log.Printf("failed to accept email invitations for user %d: %v", user.UserID, err)
+	}
+	for _, invitation := range invitations {
+		group, err := database.GetGroupByID(db, invitation.GroupID)
+		if err != nil {
+			log.Printf("failed to load group %s for added email: %v", invitation.GroupID, err)
+			continue
+		}
+		notifyAdded(db, group, user.UserID)
+	}
+}
+
+// checkParticipantsVerified returns an unverifiedParticipantsError naming the
+// participants who still have to verify their email address, if the policy
+// requires it.
+func checkParticipantsVerified(db *sql.DB, groupID string) error {
+	if !requireEmailVerification {
+		return nil
+	}
+
+	participants, err := database.GetParticipantsByGroupID(db, groupID)
+	if err != nil {
+		return err
+	}
+
+	var unverified []string
+	for _, participant := range participants {
+		if !participant.EmailVerified {
+			unverified = append(unverified, fmt.Sprintf("%s (user %d)", participant.UserName, participant.UserID))
+		}
+	}
+	if len(unverified) > 0 {
+		return &unverifiedParticipantsError{Participants: unverified}
+	}
+	return nil
+}
+
+// SendEmailVerification handles POST /user/me/verification. Emails the authenticated user a
+// new link to verify their email address.
+func SendEmailVerification(w http.ResponseWriter, r *http.Request) {
+	db, err := getDB()
+	if err != nil {
+		log.Printf("failed to open db in SendEmailVerification: %v", err)
+		http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
+		return
+	}
+	defer database.CloseDb(db)
+
+	user, ok := loadAuthenticatedUser(w, r, db)
+	if !ok {
+		return
+	}
+
+	if user.EmailVerifiedAt != nil {
+		http.Error(w, "Email address is already verified", http.StatusConflict)
+		return
+	}
+
+	if err := sendEmailVerification(db, user); err != nil {
+		log.Printf("failed to send email verification to user %d: %v", user.UserID, err)
+		http.Error(w, "Failed to send verification email", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// ConfirmEmail handles POST /user/verification/confirm. Marks the email address as verified
+// with a token from a verification email. The first verification signs out the sessions
+// started before it.
+func ConfirmEmail(w http.ResponseWriter, r *http.Request) {
+	var request confirmEmailRequest
+	if err := decodeRequestJSON(r, &request); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if request.Token == "" {
+		http.Error(w, "token is required", http.StatusBadRequest)
+		return
+	}
+
+	db, err := getDB()
+	if err != nil {
+		log.Printf("failed to open db in ConfirmEmail: %v", err)
+		http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
+		return
+	}
+	defer database.CloseDb(db)
+
+	userID, err := database.VerifyEmail(db, hashToken(request.Token), time.Now().UTC())
+	if err != nil {
+		if errors.Is(err, database.ErrVerificationTokenInvalid) {
+			http.Error(w, "Invalid or expired verification token", http.StatusBadRequest)
+			return
+		}
+
+		http.Error(w, "Failed to verify email", http.StatusInternalServerError)
+		return
+	}
+
+	// Only someone who reads the mailbox gets this far, so the groups the
+	// address was invited to are joined now.
+	user, err := database.GetUserByID(db, userID)
+	if err != nil {
+		log.Printf("failed to load user %d after email verification: %v", userID, err)
+	} else {
+		acceptEmailInvitations(db, user)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
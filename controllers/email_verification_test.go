@@ -0,0 +1,171 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/akctba/secret-santa-go-api/database"
+	"github.com/gorilla/mux"
+)
+
+func withEmailVerificationRequired(t *testing.T) {
+	t.Helper()
+
+	original := requireEmailVerification
+	SetRequireEmailVerification(true)
+	t.Cleanup(func() {
+		requireEmailVerification = original
+	})
+}
+
+func createTestUser(t *testing.T, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodPost, "/user", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	rr := httptest.NewRecorder()
+	CreateUser(rr, req)
+	return rr
+}
+
+func TestCreateUserSendsVerificationEmail(t *testing.T) {
+	setupMigratedTestDB(t)
+	recorder := withRecordingMailer(t)
+	withEmailToken(t, "verify-token")
+	if err := SetEmailVerificationURL("https://app.example.com/verify"); err != nil {
+		t.Fatalf("SetEmailVerificationURL returned error: %v", err)
+	}
+
+	if rr := createTestUser(t, `{"user_name":"Dan","email":"not an email","password":"secret123"}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d for an invalid email, got %d", http.StatusBadRequest, rr.Code)
+	}
+
+	rr := createTestUser(t, `{"user_name":"Dan","email":"dan@example.com","password":"secret123"}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("create user: status %d, body: %s", rr.Code, rr.Body.String())
+	}
+	userID := int(decodeJSONBody(t, rr.Body.String())["user_id"].(float64))
+
+	if len(recorder.messages) != 1 || !strings.Contains(recorder.messages[0].Text, "https://app.example.com/verify?token=verify-token") {
+		t.Fatalf("expected a verification link, got %+v", recorder.messages)
+	}
+
+	body := `{"token":"verify-token"}`
+	if rr := postUserRequest(t, ConfirmEmail, body); rr.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d, got %d, body: %s", http.StatusNoContent, rr.Code, rr.Body.String())
+	}
+	if rr := postUserRequest(t, ConfirmEmail, body); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d for a used token, got %d", http.StatusBadRequest, rr.Code)
+	}
+
+	payload := decodeJSONBody(t, serveAccountRequest(t, GetMe, http.MethodGet, userID, "").Body.String())
+	if payload["email_verified"] != true {
+		t.Fatalf("expected the email to be verified, got %v", payload)
+	}
+	if rr := serveAccountRequest(t, SendEmailVerification, http.MethodPost, userID, ""); rr.Code != http.StatusConflict {
+		t.Fatalf("expected status %d once verified, got %d", http.StatusConflict, rr.Code)
+	}
+}
+
+func TestConfirmEmailSignsOutSessionsStartedBeforeVerification(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+	db := setupMigratedTestDB(t)
+	withEmailToken(t, "verify-token")
+
+	rr := createTestUser(t, `{"user_name":"Dan","email":"dan@example.com","password":"secret123"}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("create user: status %d, body: %s", rr.Code, rr.Body.String())
+	}
+	userID := int(decodeJSONBody(t, rr.Body.String())["user_id"].(float64))
+
+	before, err := issueRefreshToken(db, userID)
+	if err != nil {
+		t.Fatalf("issueRefreshToken returned error: %v", err)
+	}
+
+	if rr := postUserRequest(t, ConfirmEmail, `{"token":"verify-token"}`); rr.Code != http.StatusNoContent {
+		t.Fatalf("confirm email: status %d, body: %s", rr.Code, rr.Body.String())
+	}
+	if _, code := refreshTestToken(t, before); code != http.StatusUnauthorized {
+		t.Fatalf("expected status %d for a session started before verification, got %d", http.StatusUnauthorized, code)
+	}
+
+	after, err := issueRefreshToken(db, userID)
+	if err != nil {
+		t.Fatalf("issueRefreshToken returned error: %v", err)
+	}
+	if _, code := refreshTestToken(t, after); code != http.StatusOK {
+		t.Fatalf("expected status %d for a session started after verification, got %d", http.StatusOK, code)
+	}
+}
+
+func TestRequiredVerificationHoldsBackEmailInvitations(t *testing.T) {
+	db := setupMigratedTestDB(t)
+	seedGroupWithParticipants(t, db, 1, 1, 1)
+	withEmailVerificationRequired(t)
+	withEmailToken(t, "verify-token")
+
+	if rr := createTestEmailInvitation(t, `{"email":"carol@example.com"}`); rr.Code != http.StatusCreated {
+		t.Fatalf("create invitation: status %d, body: %s", rr.Code, rr.Body.String())
+	}
+
+	rr := createTestUser(t, `{"user_name":"Carol","email":"carol@example.com","password":"secret123"}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("create user: status %d, body: %s", rr.Code, rr.Body.String())
+	}
+	userID := int(decodeJSONBody(t, rr.Body.String())["user_id"].(float64))
+
+	if _, err := database.GetUserParticipant(db, userID, 1); err == nil {
+		t.Fatal("expected the invitation to wait for verification")
+	}
+
+	if rr := postUserRequest(t, ConfirmEmail, `{"token":"verify-token"}`); rr.Code != http.StatusNoContent {
+		t.Fatalf("confirm email: status %d, body: %s", rr.Code, rr.Body.String())
+	}
+	if _, err := database.GetUserParticipant(db, userID, 1); err != nil {
+		t.Fatalf("expected the verified invitee to be a participant: %v", err)
+	}
+}
+
+func TestRequiredVerificationKeepsUnverifiedUsersOutOfGroups(t *testing.T) {
+	db := setupMigratedTestDB(t)
+	seedGroupWithParticipants(t, db, 1, 1, 1, 2)
+	seedGroupWithParticipants(t, db, 2, 3, 3)
+	withEmailVerificationRequired(t)
+	withInviteCode(t, "JOINCODE")
+
+	rr := runTestDraw(t, "")
+	if rr.Code != http.StatusUnprocessableEntity || !strings.Contains(rr.Body.String(), "user (user 2)") {
+		t.Fatalf("expected the unverified participants to be listed, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/group/1/participant", strings.NewReader(`{"group_id":"1","user_id":3}`))
+	req.Header.Set("Content-Type", "application/json")
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	req = withAuthenticatedUser(req, 1)
+	rr = httptest.NewRecorder()
+	AddParticipant(rr, req)
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected status %d for an unverified user, got %d, body: %s", http.StatusUnprocessableEntity, rr.Code, rr.Body.String())
+	}
+
+	if rr := createTestInvite(t, 1, `{}`); rr.Code != http.StatusCreated {
+		t.Fatalf("create invite: status %d, body: %s", rr.Code, rr.Body.String())
+	}
+	if rr := acceptTestInvite(t, "JOINCODE", 3); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected status %d for an unverified user, got %d, body: %s", http.StatusForbidden, rr.Code, rr.Body.String())
+	}
+
+	if _, err := db.Exec(`UPDATE Users SET email_verified_at = CURRENT_TIMESTAMP`); err != nil {
+		t.Fatalf("verify users: %v", err)
+	}
+	if rr := acceptTestInvite(t, "JOINCODE", 3); rr.Code != http.StatusCreated {
+		t.Fatalf("expected a verified user to join, got %d, body: %s", rr.Code, rr.Body.String())
+	}
+	if rr := runTestDraw(t, ""); rr.Code != http.StatusCreated {
+		t.Fatalf("expected the draw to run once verified, got %d, body: %s", rr.Code, rr.Body.String())
+	}
+}
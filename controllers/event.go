@@ -0,0 +1,124 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/akctba/secret-santa-go-api/database"
+	"github.com/akctba/secret-santa-go-api/models"
+	"github.com/gorilla/mux"
+)
+
+// icsTimeFormat is the UTC date-time form used in iCalendar files.
+const icsTimeFormat = "20060102T150405Z"
+
+// icsTextEscaper escapes TEXT values as RFC 5545 requires.
+var icsTextEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+// validateGroupEvent tidies the group's event details and checks them. The
+// returned error is safe to show to the client.
+func validateGroupEvent(group *models.Group) error {
+	group.Location = strings.TrimSpace(group.Location)
+	group.MeetingURL = strings.TrimSpace(group.MeetingURL)
+	group.Description = strings.TrimSpace(group.Description)
+
+	if group.MeetingURL != "" && !isWebLink(group.MeetingURL) {
+		return errors.New("meeting_url must be an http or https link")
+	}
+	if group.EventDate != nil && !group.DateDraw.IsZero() && group.EventDate.Before(group.DateDraw) {
+		return errors.New("event_date must not be before date_draw")
+	}
+
+	return nil
+}
+
+// GetGroupEvent handles GET /group/{id}/event.ics. Returns the group's gift exchange as an
+// iCalendar file for its members to add to their calendars.
+func GetGroupEvent(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	groupID := vars["id"]
+
+	db, err := getDB()
+	if err != nil {
+		log.Printf("failed to open db in GetGroupEvent: %v", err)
+		http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
+		return
+	}
+	defer database.CloseDb(db)
+
+	group, ok := authorizeGroup(w, r, db, groupID, groupAccessMember)
+	if !ok {
+		return
+	}
+
+	if group.EventDate == nil {
+		http.Error(w, "Event date has not been set", http.StatusConflict)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="group-%s.ics"`, group.GroupID))
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(groupEventICS(group, time.Now())))
+}
+
+// groupEventICS renders the group's gift exchange as a single-event calendar.
+func groupEventICS(group models.Group, now time.Time) string {
+	lines := []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//secret-santa-go-api//EN",
+		"CALSCALE:GREGORIAN",
+		"METHOD:PUBLISH",
+		"BEGIN:VEVENT",
+		"UID:group-" + group.GroupID + "@secret-santa-go-api",
+		"DTSTAMP:" + now.UTC().Format(icsTimeFormat),
+		"DTSTART:" + group.EventDate.UTC().Format(icsTimeFormat),
+		"SUMMARY:" + icsTextEscaper.Replace(group.Name+" gift exchange"),
+	}
+
+	if group.Location != "" {
+		lines = append(lines, "LOCATION:"+icsTextEscaper.Replace(group.Location))
+	}
+	if group.MeetingURL != "" {
+		lines = append(lines, "URL:"+group.MeetingURL)
+	}
+
+	description := group.Description
+	if group.MeetingURL != "" {
+		description = strings.TrimSpace(description + "\n\nJoin online: " + group.MeetingURL)
+	}
+	if description != "" {
+		lines = append(lines, "DESCRIPTION:"+icsTextEscaper.Replace(description))
+	}
+
+	lines = append(lines, "END:VEVENT", "END:VCALENDAR")
+
+	var b strings.Builder
+	for _, line := range lines {
+		b.WriteString(foldICSLine(line))
+		b.WriteString("\r\n")
+	}
+	return b.String()
+}
+
+// foldICSLine splits lines longer than 75 octets, continuing them on lines that
+// start with a space, without breaking UTF-8 characters apart.
+func foldICSLine(line string) string {
+	var b strings.Builder
+	width := 0
+	for _, char := range line {
+		size := len(string(char))
+		if width+size > 75 {
+			b.WriteString("\r\n ")
+			width = 1
+		}
+		b.WriteRune(char)
+		width += size
+	}
+	return b.String()
+}
@@ -0,0 +1,154 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/akctba/secret-santa-go-api/models"
+	"github.com/gorilla/mux"
+)
+
+func patchTestGroup(t *testing.T, userID int, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodPatch, "/group/1", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	req = withAuthenticatedUser(req, userID)
+
+	rr := httptest.NewRecorder()
+	UpdateGroup(rr, req)
+	return rr
+}
+
+func getTestGroupEvent(t *testing.T, userID int) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodGet, "/group/1/event.ics", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	req = withAuthenticatedUser(req, userID)
+
+	rr := httptest.NewRecorder()
+	GetGroupEvent(rr, req)
+	return rr
+}
+
+func TestUpdateGroupChangesEventDetails(t *testing.T) {
+	db := setupMigratedTestDB(t)
+	seedGroupWithParticipants(t, db, 1, 1, 1, 2)
+	eventDate := time.Now().UTC().Add(48 * time.Hour).Format(time.RFC3339)
+
+	if rr := patchTestGroup(t, 2, `{"location":"Office"}`); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected status %d for a member, got %d, body: %s", http.StatusForbidden, rr.Code, rr.Body.String())
+	}
+
+	rr := patchTestGroup(t, 1, `{"event_date":"`+eventDate+`","location":" Office kitchen ","meeting_url":"https://meet.example.com/xmas"}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d, body: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	payload := decodeJSONBody(t, rr.Body.String())
+	if payload["location"] != "Office kitchen" || payload["meeting_url"] != "https://meet.example.com/xmas" || payload["event_date"] == nil {
+		t.Fatalf("unexpected group payload: %s", rr.Body.String())
+	}
+
+	// Fields missing from the request are left alone; null clears the event date.
+	rr = patchTestGroup(t, 1, `{"event_date":null,"description":"Bring snacks"}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d, body: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	payload = decodeJSONBody(t, rr.Body.String())
+	if payload["location"] != "Office kitchen" || payload["description"] != "Bring snacks" || payload["event_date"] != nil {
+		t.Fatalf("unexpected group payload after partial update: %s", rr.Body.String())
+	}
+}
+
+func TestUpdateGroupValidatesEventDetails(t *testing.T) {
+	db := setupMigratedTestDB(t)
+	seedGroupWithParticipants(t, db, 1, 1, 1)
+
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{name: "meeting url", body: `{"meeting_url":"meet.example.com"}`, want: "meeting_url must be an http or https link"},
+		{name: "event before draw", body: `{"event_date":"2000-01-01T00:00:00Z"}`, want: "event_date must not be before date_draw"},
+		{name: "unknown field", body: `{"venue":"Office"}`, want: "Invalid request body"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := patchTestGroup(t, 1, tt.body)
+			if rr.Code != http.StatusBadRequest {
+				t.Fatalf("expected status %d, got %d, body: %s", http.StatusBadRequest, rr.Code, rr.Body.String())
+			}
+			if !strings.Contains(rr.Body.String(), tt.want) {
+				t.Fatalf("expected error %q, got: %s", tt.want, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestGetGroupEventReturnsCalendar(t *testing.T) {
+	db := setupMigratedTestDB(t)
+	seedGroupWithParticipants(t, db, 1, 1, 1, 2)
+
+	if rr := getTestGroupEvent(t, 2); rr.Code != http.StatusConflict {
+		t.Fatalf("expected status %d without an event date, got %d, body: %s", http.StatusConflict, rr.Code, rr.Body.String())
+	}
+
+	if rr := patchTestGroup(t, 1, `{"event_date":"2099-12-20T18:30:00Z","location":"Bob's place, 2nd floor"}`); rr.Code != http.StatusOK {
+		t.Fatalf("update group: status %d, body: %s", rr.Code, rr.Body.String())
+	}
+
+	if rr := getTestGroupEvent(t, 3); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected status %d for a non-member, got %d", http.StatusForbidden, rr.Code)
+	}
+
+	rr := getTestGroupEvent(t, 2)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d, body: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if contentType := rr.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "text/calendar") {
+		t.Fatalf("expected a calendar content type, got %q", contentType)
+	}
+
+	body := rr.Body.String()
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\n",
+		"UID:group-1@secret-santa-go-api\r\n",
+		"DTSTART:20991220T183000Z\r\n",
+		"SUMMARY:Holiday Crew gift exchange\r\n",
+		"LOCATION:Bob's place\\, 2nd floor\r\n",
+		"END:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("expected calendar to contain %q, got:\n%s", want, body)
+		}
+	}
+}
+
+func TestGroupEventICSFoldsLongLines(t *testing.T) {
+	eventDate := time.Date(2099, 12, 20, 18, 30, 0, 0, time.UTC)
+	group := models.Group{
+		GroupID:     "1",
+		Name:        "Holiday Crew",
+		EventDate:   &eventDate,
+		Description: strings.Repeat("Bring a gift and a smile; ", 6),
+	}
+
+	ics := groupEventICS(group, eventDate)
+	for _, line := range strings.Split(strings.TrimSuffix(ics, "\r\n"), "\r\n") {
+		if len(line) > 75 {
+			t.Fatalf("expected lines of at most 75 octets, got %d: %q", len(line), line)
+		}
+	}
+
+	unfolded := strings.ReplaceAll(ics, "\r\n ", "")
+	if !strings.Contains(unfolded, "DESCRIPTION:"+strings.Repeat(`Bring a gift and a smile\; `, 6)+"\r\n") {
+		t.Fatalf("expected the escaped description to survive folding, got:\n%s", ics)
+	}
+}
@@ -0,0 +1,167 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/akctba/secret-santa-go-api/database"
+	"github.com/akctba/secret-santa-go-api/models"
+	"github.com/gorilla/mux"
+)
+
+type createExclusionRequest struct {
+	UserID         int  `json:"user_id"`
+	ExcludedUserID int  `json:"excluded_user_id"`
+	OneWay         bool `json:"one_way"`
+}
+
+// CreateExclusion handles POST /group/{id}/exclusion. Lets an organizer prevent user_id from drawing
+// excluded_user_id and, unless one_way is set, the reverse as well.
+func CreateExclusion(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	groupID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid group ID", http.StatusBadRequest)
+		return
+	}
+
+	var request createExclusionRequest
+	if err := decodeRequestJSON(r, &request); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if request.UserID <= 0 || request.ExcludedUserID <= 0 {
+		http.Error(w, "user_id and excluded_user_id are required", http.StatusBadRequest)
+		return
+	}
+	if request.UserID == request.ExcludedUserID {
+		http.Error(w, "user_id and excluded_user_id must be different users", http.StatusBadRequest)
+		return
+	}
+
+	db, err := getDB()
+	if err != nil {
+		log.Printf("failed to open db in CreateExclusion: %v", err)
+		http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
+		return
+	}
+	defer database.CloseDb(db)
+
+	group, ok := authorizeGroup(w, r, db, vars["id"], groupAccessOrganizer)
+	if !ok {
+		return
+	}
+
+	for _, userID := range []int{request.UserID, request.ExcludedUserID} {
+		if _, err := database.GetUserParticipant(db, userID, groupID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				http.Error(w, "user_id and excluded_user_id must be participants of the group", http.StatusBadRequest)
+				return
+			}
+
+			http.Error(w, "Failed to get participant", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	existing, err := database.GetExclusionsByGroupID(db, group.GroupID)
+	if err != nil {
+		http.Error(w, "Failed to get exclusions", http.StatusInternalServerError)
+		return
+	}
+	for _, exclusion := range existing {
+		if exclusion.UserID == request.UserID && exclusion.ExcludedUserID == request.ExcludedUserID {
+			http.Error(w, "Exclusion already exists", http.StatusConflict)
+			return
+		}
+	}
+
+	exclusion := models.Exclusion{
+		GroupID:        group.GroupID,
+		UserID:         request.UserID,
+		ExcludedUserID: request.ExcludedUserID,
+		OneWay:         request.OneWay,
+	}
+
+	err = database.InsertExclusion(db, &exclusion)
+	if err != nil {
+		http.Error(w, "Failed to create exclusion", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(exclusion)
+}
+
+// GetExclusions handles GET /group/{id}/exclusion. Returns the exclusions of the group to its organizers.
+func GetExclusions(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	groupID := vars["id"]
+
+	db, err := getDB()
+	if err != nil {
+		log.Printf("failed to open db in GetExclusions: %v", err)
+		http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
+		return
+	}
+	defer database.CloseDb(db)
+
+	if _, ok := authorizeGroup(w, r, db, groupID, groupAccessOrganizer); !ok {
+		return
+	}
+
+	exclusions, err := database.GetExclusionsByGroupID(db, groupID)
+	if err != nil {
+		http.Error(w, "Failed to get exclusions", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(exclusions)
+}
+
+// DeleteExclusion handles DELETE /group/{id}/exclusion/{exclusionId}. Lets an organizer remove an exclusion.
+func DeleteExclusion(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	groupID := vars["id"]
+	exclusionID, err := strconv.Atoi(vars["exclusionId"])
+	if err != nil {
+		http.Error(w, "Invalid exclusion ID", http.StatusBadRequest)
+		return
+	}
+
+	db, err := getDB()
+	if err != nil {
+		log.Printf("failed to open db in DeleteExclusion: %v", err)
+		http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
+		return
+	}
+	defer database.CloseDb(db)
+
+	if _, ok := authorizeGroup(w, r, db, groupID, groupAccessOrganizer); !ok {
+		return
+	}
+
+	if _, err := database.GetExclusionByID(db, groupID, exclusionID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Exclusion not found", http.StatusNotFound)
+			return
+		}
+
+		http.Error(w, "Failed to get exclusion", http.StatusInternalServerError)
+		return
+	}
+
+	err = database.DeleteExclusion(db, groupID, exclusionID)
+	if err != nil {
+		http.Error(w, "Failed to delete exclusion", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,224 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/akctba/secret-santa-go-api/database"
+	"github.com/akctba/secret-santa-go-api/draw"
+	"github.com/gorilla/mux"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// setupMigratedTestDB creates the full schema in a temporary working directory.
+// Handlers keep using the default getDB so every request opens its own connection.
+func setupMigratedTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	t.Chdir(t.TempDir())
+	database.CreateTables()
+
+	db, err := database.GetDb()
+	if err != nil {
+		t.Fatalf("open migrated test db: %v", err)
+	}
+
+	t.Cleanup(func() {
+		db.Close()
+	})
+
+	return db
+}
+
+func seedGroupWithParticipants(t *testing.T, db *sql.DB, groupID int, creatorUserID int, userIDs ...int) {
+	t.Helper()
+
+	_, err := db.Exec(`INSERT INTO Groups (group_id, name, date_created, date_draw, creator_user_id) VALUES (?, ?, ?, ?, ?)`,
+		groupID, "Holiday Crew", time.Now().UTC(), time.Now().UTC(), creatorUserID)
+	if err != nil {
+		t.Fatalf("insert group %d: %v", groupID, err)
+	}
+
+	for _, userID := range userIDs {
+		_, err := db.Exec(`INSERT OR IGNORE INTO Users (user_id, user_name, user_email, password) VALUES (?, ?, ?, ?)`,
+			userID, "user", fmt.Sprintf("user%d@example.com", userID), "secret")
+		if err != nil {
+			t.Fatalf("insert user %d: %v", userID, err)
+		}
+
+		_, err = db.Exec(`INSERT INTO Participants (group_id, user_id, joined_at) VALUES (?, ?, ?)`,
+			groupID, userID, time.Now().UTC().Format(time.RFC3339))
+		if err != nil {
+			t.Fatalf("insert participant %d: %v", userID, err)
+		}
+	}
+}
+
+func createTestExclusion(t *testing.T, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodPost, "/group/1/exclusion", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	req = withAuthenticatedUser(req, 1)
+
+	rr := httptest.NewRecorder()
+	CreateExclusion(rr, req)
+	return rr
+}
+
+func runTestDraw(t *testing.T, idempotencyKey string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodPost, "/group/1/draw", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	req = withAuthenticatedUser(req, 1)
+	if idempotencyKey != "" {
+		req.Header.Set(idempotencyKeyHeader, idempotencyKey)
+	}
+
+	rr := httptest.NewRecorder()
+	RunDraw(rr, req)
+	return rr
+}
+
+func TestExclusionCRUD(t *testing.T) {
+	db := setupMigratedTestDB(t)
+	seedGroupWithParticipants(t, db, 1, 1, 1, 2, 3)
+
+	rr := createTestExclusion(t, `{"user_id":1,"excluded_user_id":2}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d, body: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+
+	payload := decodeJSONBody(t, rr.Body.String())
+	if payload["exclusion_id"] != float64(1) || payload["one_way"] != false {
+		t.Fatalf("unexpected exclusion payload: %s", rr.Body.String())
+	}
+
+	rr = createTestExclusion(t, `{"user_id":1,"excluded_user_id":2}`)
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("expected duplicate status %d, got %d, body: %s", http.StatusConflict, rr.Code, rr.Body.String())
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/group/1/exclusion", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	req = withAuthenticatedUser(req, 1)
+	rr = httptest.NewRecorder()
+	GetExclusions(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d, body: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	var listed []map[string]any
+	if err := json.Unmarshal(rr.Body.Bytes(), &listed); err != nil {
+		t.Fatalf("decode exclusions: %v", err)
+	}
+	if len(listed) != 1 {
+		t.Fatalf("expected 1 exclusion, got %d", len(listed))
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, "/group/1/exclusion/1", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "1", "exclusionId": "1"})
+	req = withAuthenticatedUser(req, 1)
+	rr = httptest.NewRecorder()
+	DeleteExclusion(rr, req)
+
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d, got %d, body: %s", http.StatusNoContent, rr.Code, rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	DeleteExclusion(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected status %d for deleted exclusion, got %d", http.StatusNotFound, rr.Code)
+	}
+}
+
+func TestCreateExclusionRejectsNonParticipant(t *testing.T) {
+	db := setupMigratedTestDB(t)
+	seedGroupWithParticipants(t, db, 1, 1, 1, 2)
+
+	rr := createTestExclusion(t, `{"user_id":1,"excluded_user_id":9}`)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d, got %d, body: %s", http.StatusBadRequest, rr.Code, rr.Body.String())
+	}
+}
+
+func withSeededDrawSolver(t *testing.T, seed uint64) {
+	t.Helper()
+
+	originalNewDrawSolver := newDrawSolver
+	newDrawSolver = func() draw.Solver {
+		return draw.NewSeededSolver(seed)
+	}
+
+	t.Cleanup(func() {
+		newDrawSolver = originalNewDrawSolver
+	})
+}
+
+func TestRunDrawHonoursExclusions(t *testing.T) {
+	db := setupMigratedTestDB(t)
+	withSeededDrawSolver(t, 42)
+	seedGroupWithParticipants(t, db, 1, 1, 1, 2, 3, 4)
+
+	if rr := createTestExclusion(t, `{"user_id":1,"excluded_user_id":2}`); rr.Code != http.StatusCreated {
+		t.Fatalf("create exclusion: status %d, body: %s", rr.Code, rr.Body.String())
+	}
+
+	rr := runTestDraw(t, "")
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d, body: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+
+	for _, userID := range []int{1, 2, 3, 4} {
+		participant, err := database.GetUserParticipant(db, userID, 1)
+		if err != nil {
+			t.Fatalf("GetUserParticipant(%d) returned error: %v", userID, err)
+		}
+		if participant.FriendUserID == 0 || participant.FriendUserID == userID {
+			t.Fatalf("user %d has invalid friend %d", userID, participant.FriendUserID)
+		}
+		if (userID == 1 && participant.FriendUserID == 2) || (userID == 2 && participant.FriendUserID == 1) {
+			t.Fatalf("user %d drew excluded user %d", userID, participant.FriendUserID)
+		}
+	}
+}
+
+func TestRunDrawReturnsUnprocessableEntityForUnsatisfiableExclusions(t *testing.T) {
+	db := setupMigratedTestDB(t)
+	seedGroupWithParticipants(t, db, 1, 1, 1, 2, 3)
+
+	for _, body := range []string{
+		`{"user_id":3,"excluded_user_id":1}`,
+		`{"user_id":3,"excluded_user_id":2}`,
+	} {
+		if rr := createTestExclusion(t, body); rr.Code != http.StatusCreated {
+			t.Fatalf("create exclusion: status %d, body: %s", rr.Code, rr.Body.String())
+		}
+	}
+
+	rr := runTestDraw(t, "")
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected status %d, got %d, body: %s", http.StatusUnprocessableEntity, rr.Code, rr.Body.String())
+	}
+	if !strings.Contains(rr.Body.String(), "user 3 has no eligible recipient") {
+		t.Fatalf("expected explanation naming user 3, got: %s", rr.Body.String())
+	}
+
+	participant, err := database.GetUserParticipant(db, 1, 1)
+	if err != nil {
+		t.Fatalf("GetUserParticipant returned error: %v", err)
+	}
+	if participant.FriendUserID != 0 {
+		t.Fatalf("expected no assignment after failed draw, got friend %d", participant.FriendUserID)
+	}
+}
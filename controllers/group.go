@@ -1,44 +1,67 @@
 package controllers
 
 import (
-	"crypto/rand"
 	"database/sql"
-	"encoding/binary"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"log"
-	randv2 "math/rand/v2"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/akctba/secret-santa-go-api/database"
 	"github.com/akctba/secret-santa-go-api/models"
+	"github.com/akctba/secret-santa-go-api/notify"
 	"github.com/gorilla/mux"
 )
 
-// cryptoSource implements randv2.Source using crypto/rand for cryptographically secure randomness.
-type cryptoSource struct{}
-
 type createGroupRequest struct {
-	GroupID       *string   `json:"group_id"`
-	Name          string    `json:"name"`
-	DateCreated   time.Time `json:"date_created"`
-	DateDraw      time.Time `json:"date_draw"`
-	CreatorUserID int       `json:"creator_user_id"`
+	GroupID       *string    `json:"group_id"`
+	Name          string     `json:"name"`
+	DateCreated   time.Time  `json:"date_created"`
+	DateDraw      time.Time  `json:"date_draw"`
+	CreatorUserID *int       `json:"creator_user_id"`
+	BudgetMin     float64    `json:"budget_min"`
+	BudgetMax     float64    `json:"budget_max"`
+	Currency      string     `json:"currency"`
+	EventDate     *time.Time `json:"event_date"`
+	Location      string     `json:"location"`
+	MeetingURL    string     `json:"meeting_url"`
+	Description   string     `json:"description"`
 }
 
-func (cryptoSource) Uint64() uint64 {
-	var b [8]byte
-	if _, err := rand.Read(b[:]); err != nil {
-		panic(fmt.Errorf("crypto/rand: failed to generate random number: %w", err))
-	}
-	return binary.LittleEndian.Uint64(b[:])
+// updateGroupRequest only changes the fields that are present. Send an empty
+// string, or null for event_date, to clear one.
+type updateGroupRequest struct {
+	Name        *string      `json:"name"`
+	DateDraw    optionalTime `json:"date_draw"`
+	BudgetMin   *float64     `json:"budget_min"`
+	BudgetMax   *float64     `json:"budget_max"`
+	Currency    *string      `json:"currency"`
+	EventDate   optionalTime `json:"event_date"`
+	Location    *string      `json:"location"`
+	MeetingURL  *string      `json:"meeting_url"`
+	Description *string      `json:"description"`
+}
+
+type updateRoleRequest struct {
+	Role string `json:"role"`
+}
+
+type transferOwnershipRequest struct {
+	UserID int `json:"user_id"`
+}
+
+// secretFriendResponse is the friend's profile together with the wishlist they keep
+// for the group, or their general wishlist when they have none for it.
+type secretFriendResponse struct {
+	userResponse
+	Wishlist *models.Wishlist `json:"wishlist,omitempty"`
 }
 
-// CreateGroup handles POST /group. Persists a new group to the database.
+// CreateGroup handles POST /group. Persists a new group created by the authenticated user.
 func CreateGroup(w http.ResponseWriter, r *http.Request) {
 	var request createGroupRequest
 	if err := decodeRequestJSON(r, &request); err != nil {
@@ -52,10 +75,16 @@ func CreateGroup(w http.ResponseWriter, r *http.Request) {
 	}
 
 	group := models.Group{
-		Name:          request.Name,
-		DateCreated:   request.DateCreated,
-		DateDraw:      request.DateDraw,
-		CreatorUserID: request.CreatorUserID,
+		Name:        request.Name,
+		DateCreated: request.DateCreated,
+		DateDraw:    request.DateDraw,
+		BudgetMin:   request.BudgetMin,
+		BudgetMax:   request.BudgetMax,
+		Currency:    request.Currency,
+		EventDate:   request.EventDate,
+		Location:    request.Location,
+		MeetingURL:  request.MeetingURL,
+		Description: request.Description,
 	}
 
 	group.Name = strings.TrimSpace(group.Name)
@@ -64,6 +93,27 @@ func CreateGroup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := validateGroupBudget(&group); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validateGroupEvent(&group); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := authenticatedUserIDFromRequest(r)
+	if !ok {
+		http.Error(w, "Invalid token", http.StatusUnauthorized)
+		return
+	}
+
+	if request.CreatorUserID != nil && *request.CreatorUserID != userID {
+		http.Error(w, "creator_user_id must match the authenticated user", http.StatusForbidden)
+		return
+	}
+	group.CreatorUserID = userID
+
 	db, err := getDB()
 	if err != nil {
 		log.Printf("failed to open db in CreateGroup: %v", err)
@@ -82,7 +132,7 @@ func CreateGroup(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(group)
 }
 
-// GetGroup handles GET /group/{id}. Returns the group with the given ID.
+// GetGroup handles GET /group/{id}. Returns the group with the given ID to its members.
 func GetGroup(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	groupID := vars["id"]
@@ -95,17 +145,183 @@ func GetGroup(w http.ResponseWriter, r *http.Request) {
 	}
 	defer database.CloseDb(db)
 
-	group, err := database.GetGroupByID(db, groupID)
+	group, ok := authorizeGroup(w, r, db, groupID, groupAccessMember)
+	if !ok {
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(group)
+}
+
+// ListGroups handles GET /group. Returns the groups the authenticated user created or takes part in.
+func ListGroups(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authenticatedUserIDFromRequest(r)
+	if !ok {
+		http.Error(w, "Invalid token", http.StatusUnauthorized)
+		return
+	}
+
+	db, err := getDB()
+	if err != nil {
+		log.Printf("failed to open db in ListGroups: %v", err)
+		http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
+		return
+	}
+	defer database.CloseDb(db)
+
+	created, err := database.GetGroupsByUserID(db, userID)
+	if err != nil {
+		http.Error(w, "Failed to get groups", http.StatusInternalServerError)
+		return
+	}
+	joined, err := database.GetUserGroups(db, userID)
+	if err != nil {
+		http.Error(w, "Failed to get groups", http.StatusInternalServerError)
+		return
+	}
+
+	// The owner usually takes part in their own group too.
+	groups := []models.Group{}
+	seen := map[string]bool{}
+	for _, group := range append(created, joined...) {
+		if !seen[group.GroupID] {
+			seen[group.GroupID] = true
+			groups = append(groups, group)
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		left, _ := strconv.Atoi(groups[i].GroupID)
+		right, _ := strconv.Atoi(groups[j].GroupID)
+		return left < right
+	})
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(groups)
+}
+
+// UpdateGroup handles PATCH /group/{id}. Lets an organizer change the group's details.
+// Moving date_draw lets the scheduler try again if an automatic draw failed or was reset.
+// A new budget applies to wishlist items added or updated afterwards; items already on
+// the group's wishlists are not re-checked, since only their owners can change them.
+func UpdateGroup(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	groupID := vars["id"]
+
+	var request updateGroupRequest
+	if err := decodeRequestJSON(r, &request); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	db, err := getDB()
+	if err != nil {
+		log.Printf("failed to open db in UpdateGroup: %v", err)
+		http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
+		return
+	}
+	defer database.CloseDb(db)
+
+	group, ok := authorizeGroup(w, r, db, groupID, groupAccessOrganizer)
+	if !ok {
+		return
+	}
+
+	previousDateDraw := group.DateDraw
+	if request.Name != nil {
+		group.Name = strings.TrimSpace(*request.Name)
+		if group.Name == "" {
+			http.Error(w, "name is required", http.StatusBadRequest)
+			return
+		}
+	}
+	if request.DateDraw.Set {
+		group.DateDraw = time.Time{}
+		if request.DateDraw.Value != nil {
+			group.DateDraw = *request.DateDraw.Value
+		}
+	}
+	if request.BudgetMin != nil {
+		group.BudgetMin = *request.BudgetMin
+	}
+	if request.BudgetMax != nil {
+		group.BudgetMax = *request.BudgetMax
+	}
+	if request.Currency != nil {
+		group.Currency = *request.Currency
+	}
+	if request.EventDate.Set {
+		group.EventDate = request.EventDate.Value
+	}
+	if request.Location != nil {
+		group.Location = *request.Location
+	}
+	if request.MeetingURL != nil {
+		group.MeetingURL = *request.MeetingURL
+	}
+	if request.Description != nil {
+		group.Description = *request.Description
+	}
+
+	if err := validateGroupBudget(&group); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validateGroupEvent(&group); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = database.UpdateGroup(db, group)
 	if err != nil {
-		http.Error(w, "Failed to get group", http.StatusInternalServerError)
+		http.Error(w, "Failed to update group", http.StatusInternalServerError)
 		return
 	}
 
+	if !group.DateDraw.Equal(previousDateDraw) {
+		if err := database.ResetAutoDraw(db, group.GroupID); err != nil {
+			log.Printf("failed to reset scheduled draw for group %s: %v", group.GroupID, err)
+		}
+	}
+
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(group)
 }
 
-// AddParticipant handles POST /group/{id}/participant. Adds a user to the group.
+// DeleteGroup handles DELETE /group/{id}. Lets the owner delete the group with its participants,
+// draws, exclusions, invites, group wishlists and messages.
+func DeleteGroup(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	groupID := vars["id"]
+
+	db, err := getDB()
+	if err != nil {
+		log.Printf("failed to open db in DeleteGroup: %v", err)
+		http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
+		return
+	}
+	defer database.CloseDb(db)
+
+	group, ok := authorizeGroup(w, r, db, groupID, groupAccessOwner)
+	if !ok {
+		return
+	}
+
+	err = database.DeleteGroup(db, group.GroupID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Group not found", http.StatusNotFound)
+			return
+		}
+
+		http.Error(w, "Failed to delete group", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AddParticipant handles POST /group/{id}/participant. Lets an organizer add a user to the group.
 func AddParticipant(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	groupID := vars["id"]
@@ -130,9 +346,8 @@ func AddParticipant(w http.ResponseWriter, r *http.Request) {
 	}
 	defer database.CloseDb(db)
 
-	group, err := database.GetGroupByID(db, groupID)
-	if err != nil {
-		http.Error(w, "Failed to get group", http.StatusInternalServerError)
+	group, ok := authorizeGroup(w, r, db, groupID, groupAccessOrganizer)
+	if !ok {
 		return
 	}
 	if request.GroupID != group.GroupID {
@@ -140,63 +355,234 @@ func AddParticipant(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if requireEmailVerification {
+		user, err := database.GetUserByID(db, request.UserID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				http.Error(w, "User not found", http.StatusNotFound)
+				return
+			}
+
+			http.Error(w, "Failed to get user", http.StatusInternalServerError)
+			return
+		}
+		if user.EmailVerifiedAt == nil {
+			http.Error(w, "User has not verified their email address", http.StatusUnprocessableEntity)
+			return
+		}
+	}
+
 	err = database.InsertParticipant(db, request)
 	if err != nil {
 		http.Error(w, "Failed to add participant", http.StatusInternalServerError)
 		return
 	}
 
+	notifyAdded(db, group, request.UserID)
+
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(request)
 }
 
-// RunDraw handles POST /group/{id}/draw. Shuffles participants and assigns secret friends.
-func RunDraw(w http.ResponseWriter, r *http.Request) {
+// UpdateParticipantRole handles PUT /group/{id}/participant/{userId}/role. Lets the owner
+// promote a member to organizer or demote an organizer back to member.
+func UpdateParticipantRole(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	groupID := vars["id"]
+	userID, err := strconv.Atoi(vars["userId"])
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	var request updateRoleRequest
+	if err := decodeRequestJSON(r, &request); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	request.Role = strings.TrimSpace(request.Role)
+	if request.Role != models.RoleOrganizer && request.Role != models.RoleMember {
+		http.Error(w, "role must be organizer or member", http.StatusBadRequest)
+		return
+	}
 
 	db, err := getDB()
 	if err != nil {
-		log.Printf("failed to open db in RunDraw: %v", err)
+		log.Printf("failed to open db in UpdateParticipantRole: %v", err)
 		http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
 		return
 	}
 	defer database.CloseDb(db)
 
-	participants, err := database.GetParticipantsToDraw(db, groupID)
+	group, ok := authorizeGroup(w, r, db, groupID, groupAccessOwner)
+	if !ok {
+		return
+	}
+	if userID == group.CreatorUserID {
+		http.Error(w, "Transfer ownership before changing the owner's role", http.StatusConflict)
+		return
+	}
+
+	err = database.UpdateParticipantRole(db, userID, group.GroupID, request.Role)
 	if err != nil {
-		http.Error(w, "Failed to get participants", http.StatusInternalServerError)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Participant not found", http.StatusNotFound)
+			return
+		}
+
+		http.Error(w, "Failed to update role", http.StatusInternalServerError)
 		return
 	}
 
-	if len(participants) == 0 {
-		http.Error(w, "No participants to draw", http.StatusBadRequest)
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(models.ParticipantRole{GroupID: group.GroupID, UserID: userID, Role: request.Role})
+}
+
+// TransferOwnership handles POST /group/{id}/owner. Lets the owner hand the group over to
+// another participant; the previous owner stays on as an organizer, so an owner who does
+// not take part in the group has to join it first.
+func TransferOwnership(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	groupID := vars["id"]
+
+	var request transferOwnershipRequest
+	if err := decodeRequestJSON(r, &request); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	// A new Rand is created per request because math/rand/v2.Rand is not safe for concurrent use.
-	// cryptoSource itself is stateless so construction overhead is negligible.
-	randv2.New(cryptoSource{}).Shuffle(len(participants), func(i, j int) {
-		participants[i], participants[j] = participants[j], participants[i]
-	})
+	if request.UserID <= 0 {
+		http.Error(w, "user_id is required", http.StatusBadRequest)
+		return
+	}
 
-	// Assign secret friends in a circular manner so the last participant
-	// receives the first as their secret friend.
-	for i := range participants {
-		if i+1 == len(participants) {
-			participants[i].FriendUserID = participants[0].UserID
-		} else {
-			participants[i].FriendUserID = participants[i+1].UserID
-		}
+	db, err := getDB()
+	if err != nil {
+		log.Printf("failed to open db in TransferOwnership: %v", err)
+		http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
+		return
+	}
+	defer database.CloseDb(db)
 
-		err = database.UpdateParticipant(db, participants[i])
-		if err != nil {
-			http.Error(w, "Failed to update participant", http.StatusInternalServerError)
+	group, ok := authorizeGroup(w, r, db, groupID, groupAccessOwner)
+	if !ok {
+		return
+	}
+	if request.UserID == group.CreatorUserID {
+		http.Error(w, "User already owns this group", http.StatusConflict)
+		return
+	}
+
+	err = database.TransferGroupOwnership(db, group.GroupID, request.UserID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "New owner must be a participant of the group", http.StatusBadRequest)
 			return
 		}
+		if errors.Is(err, database.ErrOwnerNotParticipant) {
+			http.Error(w, "Join the group before transferring it, so you can stay on as an organizer", http.StatusConflict)
+			return
+		}
+
+		http.Error(w, "Failed to transfer ownership", http.StatusInternalServerError)
+		return
 	}
 
+	group.CreatorUserID = request.UserID
+
 	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(group)
+}
+
+// RunDraw handles POST /group/{id}/draw. Lets an organizer assign secret friends while honouring the group's exclusions.
+// A group is drawn once; repeating the request with the same Idempotency-Key returns the original draw.
+func RunDraw(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	groupID := vars["id"]
+	idempotencyKey := strings.TrimSpace(r.Header.Get(idempotencyKeyHeader))
+
+	userID, ok := authenticatedUserIDFromRequest(r)
+	if !ok {
+		http.Error(w, "Invalid token", http.StatusUnauthorized)
+		return
+	}
+
+	db, err := getDB()
+	if err != nil {
+		log.Printf("failed to open db in RunDraw: %v", err)
+		http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
+		return
+	}
+	defer database.CloseDb(db)
+
+	group, ok := authorizeGroup(w, r, db, groupID, groupAccessOrganizer)
+	if !ok {
+		return
+	}
+
+	if writeExistingDraw(w, db, groupID, idempotencyKey) {
+		return
+	}
+
+	participants, algorithm, err := computeDraw(db, groupID)
+	if err != nil {
+		writeDrawError(w, err)
+		return
+	}
+
+	record := models.Draw{
+		GroupID:        groupID,
+		RunAt:          time.Now().UTC(),
+		RunBy:          userID,
+		Algorithm:      algorithm,
+		IdempotencyKey: idempotencyKey,
+	}
+
+	err = database.InsertDraw(db, &record, participants)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrDrawExists):
+			// Another request won the race, or the group was drawn before draws were recorded.
+			if !writeExistingDraw(w, db, groupID, idempotencyKey) {
+				http.Error(w, "Draw has already been run for this group", http.StatusConflict)
+			}
+		default:
+			writeDrawError(w, err)
+		}
+		return
+	}
+
+	if err := notifyGroup(db, group, notify.KindDrawn); err != nil {
+		log.Printf("failed to send draw emails for group %s: %v", group.GroupID, err)
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(record)
+}
+
+// writeExistingDraw answers the request when the group already has a draw: with
+// the draw itself when the idempotency key matches, otherwise with 409. It
+// reports whether a response was written.
+func writeExistingDraw(w http.ResponseWriter, db *sql.DB, groupID string, idempotencyKey string) bool {
+	existing, err := database.GetDrawByGroupID(db, groupID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false
+		}
+
+		http.Error(w, "Failed to get draw", http.StatusInternalServerError)
+		return true
+	}
+
+	if idempotencyKey != "" && existing.IdempotencyKey == idempotencyKey {
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(existing)
+		return true
+	}
+
+	http.Error(w, "Draw has already been run for this group", http.StatusConflict)
+	return true
 }
 
 // GetSecretFriend handles GET /group/{id}/friend. Returns the authenticated user's assigned friend.
@@ -244,6 +630,26 @@ func GetSecretFriend(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	response := secretFriendResponse{userResponse: toUserResponse(friend)}
+
+	wishlist, err := database.GetWishlistForGroup(db, friend.UserID, strconv.Itoa(groupID))
+	if err == nil {
+		// Items on a general wishlist were not checked against this group's
+		// budget when they were added.
+		if wishlist.GroupID == "" {
+			group, err := database.GetGroupByID(db, strconv.Itoa(groupID))
+			if err != nil {
+				http.Error(w, "Failed to get group", http.StatusInternalServerError)
+				return
+			}
+			wishlist.Items = keepItemsWithinBudget(wishlist.Items, group)
+		}
+		response.Wishlist = &wishlist
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Failed to get wishlist", http.StatusInternalServerError)
+		return
+	}
+
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(toUserResponse(friend))
+	json.NewEncoder(w).Encode(response)
 }
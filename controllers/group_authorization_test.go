@@ -0,0 +1,133 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/akctba/secret-santa-go-api/auth"
+	"github.com/gorilla/mux"
+)
+
+func serveAsUser(t *testing.T, handler http.HandlerFunc, req *http.Request, userID int) *httptest.ResponseRecorder {
+	t.Helper()
+
+	token, err := auth.CreateToken(userID)
+	if err != nil {
+		t.Fatalf("create token: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	rr := httptest.NewRecorder()
+	BearerAuth(handler)(rr, req)
+	return rr
+}
+
+func TestCreateGroupSetsCreatorFromToken(t *testing.T) {
+	db := setupMigratedTestDB(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/group", strings.NewReader(`{"name":"Holiday Crew"}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	rr := serveAsUser(t, CreateGroup, req, 7)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d, body: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+
+	var creatorUserID int
+	if err := db.QueryRow(`SELECT creator_user_id FROM Groups WHERE name = 'Holiday Crew'`).Scan(&creatorUserID); err != nil {
+		t.Fatalf("load created group: %v", err)
+	}
+	if creatorUserID != 7 {
+		t.Fatalf("expected creator_user_id 7, got %d", creatorUserID)
+	}
+}
+
+func TestCreateGroupReturnsForbiddenForOtherCreator(t *testing.T) {
+	setupMigratedTestDB(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/group", strings.NewReader(`{"name":"Holiday Crew","creator_user_id":2}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	rr := serveAsUser(t, CreateGroup, req, 1)
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("expected status %d, got %d, body: %s", http.StatusForbidden, rr.Code, rr.Body.String())
+	}
+
+	if !strings.Contains(rr.Body.String(), "creator_user_id must match the authenticated user") {
+		t.Fatalf("expected creator mismatch error, got: %s", rr.Body.String())
+	}
+}
+
+func TestGetGroupAllowsMembersOnly(t *testing.T) {
+	db := setupMigratedTestDB(t)
+	seedGroupWithParticipants(t, db, 1, 1, 2, 3)
+
+	for _, userID := range []int{1, 2} {
+		req := httptest.NewRequest(http.MethodGet, "/group/1", nil)
+		req = mux.SetURLVars(req, map[string]string{"id": "1"})
+
+		rr := serveAsUser(t, GetGroup, req, userID)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("user %d: expected status %d, got %d, body: %s", userID, http.StatusOK, rr.Code, rr.Body.String())
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/group/1", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+
+	rr := serveAsUser(t, GetGroup, req, 4)
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("expected status %d, got %d, body: %s", http.StatusForbidden, rr.Code, rr.Body.String())
+	}
+
+	if !strings.Contains(rr.Body.String(), "User is not a member of this group") {
+		t.Fatalf("expected not-member error, got: %s", rr.Body.String())
+	}
+}
+
+func TestGetGroupReturnsNotFoundForMissingGroup(t *testing.T) {
+	setupMigratedTestDB(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/group/99", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "99"})
+
+	rr := serveAsUser(t, GetGroup, req, 1)
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected status %d, got %d, body: %s", http.StatusNotFound, rr.Code, rr.Body.String())
+	}
+}
+
+func TestGroupManagementReturnsForbiddenForParticipant(t *testing.T) {
+	db := setupMigratedTestDB(t)
+	seedGroupWithParticipants(t, db, 1, 1, 1, 2, 3)
+
+	req := httptest.NewRequest(http.MethodPost, "/group/1/participant", strings.NewReader(`{"group_id":"1","user_id":4}`))
+	req.Header.Set("Content-Type", "application/json")
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+
+	rr := serveAsUser(t, AddParticipant, req, 2)
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("AddParticipant: expected status %d, got %d, body: %s", http.StatusForbidden, rr.Code, rr.Body.String())
+	}
+	if !strings.Contains(rr.Body.String(), "Only group organizers can manage this group") {
+		t.Fatalf("AddParticipant: expected organizer error, got: %s", rr.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/group/1/draw", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+
+	rr = serveAsUser(t, RunDraw, req, 2)
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("RunDraw: expected status %d, got %d, body: %s", http.StatusForbidden, rr.Code, rr.Body.String())
+	}
+
+	var assigned int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM Participants WHERE group_id = 1 AND friend_user_id IS NOT NULL`).Scan(&assigned); err != nil {
+		t.Fatalf("count assignments: %v", err)
+	}
+	if assigned != 0 {
+		t.Fatalf("expected no assignments after a forbidden draw, got %d", assigned)
+	}
+}
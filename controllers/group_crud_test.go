@@ -0,0 +1,123 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/akctba/secret-santa-go-api/models"
+	"github.com/gorilla/mux"
+)
+
+func deleteTestGroup(t *testing.T, userID int) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodDelete, "/group/1", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	req = withAuthenticatedUser(req, userID)
+
+	rr := httptest.NewRecorder()
+	DeleteGroup(rr, req)
+	return rr
+}
+
+func TestListGroupsReturnsCreatedAndJoinedGroups(t *testing.T) {
+	db := setupMigratedTestDB(t)
+	seedGroupWithParticipants(t, db, 1, 1, 1, 2)
+	seedGroupWithParticipants(t, db, 2, 2, 2, 3)
+	seedGroupWithParticipants(t, db, 3, 3, 3, 1)
+
+	req := httptest.NewRequest(http.MethodGet, "/group", nil)
+	req = withAuthenticatedUser(req, 1)
+	rr := httptest.NewRecorder()
+	ListGroups(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d, body: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	var groups []models.Group
+	if err := json.Unmarshal(rr.Body.Bytes(), &groups); err != nil {
+		t.Fatalf("decode groups: %v, body: %s", err, rr.Body.String())
+	}
+	if len(groups) != 2 || groups[0].GroupID != "1" || groups[1].GroupID != "3" {
+		t.Fatalf("expected groups 1 and 3, got: %s", rr.Body.String())
+	}
+}
+
+func TestUpdateGroupChangesNameAndBudget(t *testing.T) {
+	db := setupMigratedTestDB(t)
+	seedGroupWithParticipants(t, db, 1, 1, 1, 2)
+	if _, err := db.Exec(`UPDATE Groups SET auto_draw_status = 'failed', auto_draw_attempts = 3 WHERE group_id = 1`); err != nil {
+		t.Fatalf("mark failed draw: %v", err)
+	}
+
+	rr := patchTestGroup(t, 1, `{"name":" Office party ","budget_max":30,"currency":"usd","date_draw":"2030-12-01T18:00:00Z"}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d, body: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	payload := decodeJSONBody(t, rr.Body.String())
+	if payload["name"] != "Office party" || payload["budget_max"] != float64(30) || payload["currency"] != "USD" {
+		t.Fatalf("unexpected group: %s", rr.Body.String())
+	}
+
+	var attempts int
+	if err := db.QueryRow(`SELECT auto_draw_attempts FROM Groups WHERE group_id = 1`).Scan(&attempts); err != nil {
+		t.Fatalf("load draw attempts: %v", err)
+	}
+	if attempts != 0 {
+		t.Fatalf("expected moving date_draw to reset the scheduled draw, got %d attempts", attempts)
+	}
+
+	tests := []struct {
+		name     string
+		userID   int
+		body     string
+		wantCode int
+	}{
+		{name: "empty name", userID: 1, body: `{"name":"  "}`, wantCode: http.StatusBadRequest},
+		{name: "invalid budget", userID: 1, body: `{"budget_min":50}`, wantCode: http.StatusBadRequest},
+		{name: "not an organizer", userID: 2, body: `{"name":"Mine now"}`, wantCode: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := patchTestGroup(t, tt.userID, tt.body)
+			if rr.Code != tt.wantCode {
+				t.Fatalf("expected status %d, got %d, body: %s", tt.wantCode, rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestDeleteGroupIsOwnerOnly(t *testing.T) {
+	db := setupMigratedTestDB(t)
+	seedGroupWithParticipants(t, db, 1, 1, 1, 2, 3)
+	if rr := runTestDraw(t, ""); rr.Code != http.StatusCreated {
+		t.Fatalf("run draw: status %d, body: %s", rr.Code, rr.Body.String())
+	}
+
+	if rr := deleteTestGroup(t, 2); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected status %d for a member, got %d, body: %s", http.StatusForbidden, rr.Code, rr.Body.String())
+	}
+
+	if rr := deleteTestGroup(t, 1); rr.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d, got %d, body: %s", http.StatusNoContent, rr.Code, rr.Body.String())
+	}
+
+	for _, table := range []string{"Participants", "Draws"} {
+		var count int
+		if err := db.QueryRow(`SELECT COUNT(*) FROM ` + table + ` WHERE group_id = 1`).Scan(&count); err != nil {
+			t.Fatalf("count %s: %v", table, err)
+		}
+		if count != 0 {
+			t.Fatalf("expected no %s left for the deleted group, got %d", table, count)
+		}
+	}
+
+	if rr := deleteTestGroup(t, 1); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected status %d once deleted, got %d, body: %s", http.StatusNotFound, rr.Code, rr.Body.String())
+	}
+}
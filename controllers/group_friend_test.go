@@ -2,6 +2,7 @@ package controllers
 
 import (
 	"database/sql"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -28,7 +29,8 @@ func setupGroupFriendTestDB(t *testing.T) *sql.DB {
 		user_email TEXT,
 		password TEXT,
 		gender TEXT,
-		date_of_birth TEXT
+		date_of_birth TEXT,
+		email_verified_at DATETIME
 	);`
 	if _, err := db.Exec(createUsersTable); err != nil {
 		db.Close()
@@ -48,6 +50,30 @@ func setupGroupFriendTestDB(t *testing.T) *sql.DB {
 		t.Fatalf("create Participants table: %v", err)
 	}
 
+	createWishlistTables := `
+	CREATE TABLE Wishlists (
+		wishlist_id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		group_id INTEGER,
+		title TEXT,
+		date_created DATETIME
+	);
+	CREATE TABLE WishlistItems (
+		item_id INTEGER PRIMARY KEY AUTOINCREMENT,
+		wishlist_id INTEGER NOT NULL,
+		title TEXT,
+		url TEXT,
+		price_min REAL NOT NULL DEFAULT 0,
+		price_max REAL NOT NULL DEFAULT 0,
+		priority INTEGER NOT NULL DEFAULT 3,
+		notes TEXT,
+		date_created DATETIME
+	);`
+	if _, err := db.Exec(createWishlistTables); err != nil {
+		db.Close()
+		t.Fatalf("create Wishlist tables: %v", err)
+	}
+
 	t.Cleanup(func() {
 		db.Close()
 	})
@@ -96,6 +122,109 @@ func TestGetSecretFriendReturnsAssignedFriend(t *testing.T) {
 	}
 }
 
+func TestGetSecretFriendIncludesFriendWishlistForGroup(t *testing.T) {
+	db := setupGroupFriendTestDB(t)
+	withTestDB(t, db)
+
+	_, err := db.Exec(`INSERT INTO Users (user_id, user_name, user_email, password) VALUES
+		(1, 'Alice', 'alice@example.com', 'secret'),
+		(2, 'Bob', 'bob@example.com', 'secret')`)
+	if err != nil {
+		t.Fatalf("insert users: %v", err)
+	}
+
+	_, err = db.Exec(`INSERT INTO Participants (group_id, user_id, joined_at, friend_user_id) VALUES (?, ?, ?, ?)`, 1, 1, time.Now().UTC().Format(time.RFC3339), 2)
+	if err != nil {
+		t.Fatalf("insert participant assignment: %v", err)
+	}
+
+	_, err = db.Exec(`INSERT INTO Wishlists (wishlist_id, user_id, group_id, title) VALUES
+		(1, 2, NULL, 'Anything'),
+		(2, 2, 1, 'Office party'),
+		(3, 2, 7, 'Family')`)
+	if err != nil {
+		t.Fatalf("insert wishlists: %v", err)
+	}
+	_, err = db.Exec(`INSERT INTO WishlistItems (wishlist_id, title, url, priority) VALUES
+		(2, 'Mug', '', 4),
+		(2, 'Scarf', 'https://example.com/scarf', 1)`)
+	if err != nil {
+		t.Fatalf("insert wishlist items: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/group/1/friend", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	req = withAuthenticatedUser(req, 1)
+
+	rr := httptest.NewRecorder()
+	GetSecretFriend(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d, body: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	var payload struct {
+		UserName string `json:"user_name"`
+		Wishlist struct {
+			Title string `json:"title"`
+			Items []struct {
+				Title string `json:"title"`
+			} `json:"items"`
+		} `json:"wishlist"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &payload); err != nil {
+		t.Fatalf("decode response: %v, body: %s", err, rr.Body.String())
+	}
+	if payload.UserName != "Bob" || payload.Wishlist.Title != "Office party" {
+		t.Fatalf("expected Bob's wishlist for this group, got: %s", rr.Body.String())
+	}
+	if len(payload.Wishlist.Items) != 2 || payload.Wishlist.Items[0].Title != "Scarf" {
+		t.Fatalf("expected items ordered by priority, got: %s", rr.Body.String())
+	}
+}
+
+func TestGetSecretFriendHidesGeneralWishlistItemsOutsideTheBudget(t *testing.T) {
+	db := setupMigratedTestDB(t)
+	seedGroupWithParticipants(t, db, 1, 1, 1, 2)
+
+	_, err := db.Exec(`UPDATE Groups SET budget_min = 10, budget_max = 50, currency = 'EUR' WHERE group_id = 1;
+		UPDATE Participants SET friend_user_id = 2 WHERE group_id = 1 AND user_id = 1;
+		INSERT INTO Wishlists (wishlist_id, user_id, group_id, title) VALUES (1, 2, NULL, 'Anything');
+		INSERT INTO WishlistItems (wishlist_id, title, price_min, price_max, priority) VALUES
+			(1, 'Book', 15, 20, 1),
+			(1, 'Console', 300, 0, 2),
+			(1, 'Sticker', 2, 0, 3),
+			(1, 'Surprise me', 0, 0, 4)`)
+	if err != nil {
+		t.Fatalf("seed general wishlist: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/group/1/friend", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	req = withAuthenticatedUser(req, 1)
+
+	rr := httptest.NewRecorder()
+	GetSecretFriend(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d, body: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	var payload struct {
+		Wishlist struct {
+			Items []struct {
+				Title string `json:"title"`
+			} `json:"items"`
+		} `json:"wishlist"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &payload); err != nil {
+		t.Fatalf("decode response: %v, body: %s", err, rr.Body.String())
+	}
+	items := payload.Wishlist.Items
+	if len(items) != 2 || items[0].Title != "Book" || items[1].Title != "Surprise me" {
+		t.Fatalf("expected only the items within the budget, got: %s", rr.Body.String())
+	}
+}
+
 func TestGetSecretFriendReturnsForbiddenForNonParticipant(t *testing.T) {
 	db := setupGroupFriendTestDB(t)
 	withTestDB(t, db)
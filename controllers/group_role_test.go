@@ -0,0 +1,131 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/akctba/secret-santa-go-api/database"
+	"github.com/akctba/secret-santa-go-api/models"
+	"github.com/gorilla/mux"
+)
+
+func updateTestRole(t *testing.T, actingUserID int, userID string, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodPut, "/group/1/participant/"+userID+"/role", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req = mux.SetURLVars(req, map[string]string{"id": "1", "userId": userID})
+	req = withAuthenticatedUser(req, actingUserID)
+
+	rr := httptest.NewRecorder()
+	UpdateParticipantRole(rr, req)
+	return rr
+}
+
+func TestUpdateParticipantRolePromotesOrganizer(t *testing.T) {
+	db := setupMigratedTestDB(t)
+	seedGroupWithParticipants(t, db, 1, 1, 1, 2, 3)
+
+	rr := updateTestRole(t, 2, "3", `{"role":"organizer"}`)
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("expected status %d for a member, got %d, body: %s", http.StatusForbidden, rr.Code, rr.Body.String())
+	}
+
+	rr = updateTestRole(t, 1, "2", `{"role":"organizer"}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d, body: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if decodeJSONBody(t, rr.Body.String())["role"] != models.RoleOrganizer {
+		t.Fatalf("unexpected role payload: %s", rr.Body.String())
+	}
+
+	// Organizers manage the group but cannot hand out roles themselves.
+	if rr := postGroupDrawAction(t, RunDraw, "/group/1/draw", 2); rr.Code != http.StatusCreated {
+		t.Fatalf("expected organizer to run the draw, got status %d, body: %s", rr.Code, rr.Body.String())
+	}
+	rr = updateTestRole(t, 2, "3", `{"role":"organizer"}`)
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("expected status %d for an organizer, got %d, body: %s", http.StatusForbidden, rr.Code, rr.Body.String())
+	}
+	if !strings.Contains(rr.Body.String(), "Only the group owner can do this") {
+		t.Fatalf("expected owner error, got: %s", rr.Body.String())
+	}
+
+	rr = updateTestRole(t, 1, "2", `{"role":"member"}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d demoting, got %d, body: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if role, _ := database.GetParticipantRole(db, 2, "1"); role != models.RoleMember {
+		t.Fatalf("expected user 2 to be a member again, got %q", role)
+	}
+}
+
+func TestUpdateParticipantRoleRejectsInvalidChanges(t *testing.T) {
+	db := setupMigratedTestDB(t)
+	seedGroupWithParticipants(t, db, 1, 1, 1, 2)
+
+	tests := []struct {
+		name     string
+		userID   string
+		body     string
+		wantCode int
+	}{
+		{name: "owner role", userID: "2", body: `{"role":"owner"}`, wantCode: http.StatusBadRequest},
+		{name: "unknown role", userID: "2", body: `{"role":"admin"}`, wantCode: http.StatusBadRequest},
+		{name: "owner demoted", userID: "1", body: `{"role":"member"}`, wantCode: http.StatusConflict},
+		{name: "not a participant", userID: "9", body: `{"role":"organizer"}`, wantCode: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := updateTestRole(t, 1, tt.userID, tt.body)
+			if rr.Code != tt.wantCode {
+				t.Fatalf("expected status %d, got %d, body: %s", tt.wantCode, rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestTransferOwnershipHandsGroupOver(t *testing.T) {
+	db := setupMigratedTestDB(t)
+	seedGroupWithParticipants(t, db, 1, 1, 2)
+
+	transfer := func(actingUserID int, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/group/1/owner", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req = mux.SetURLVars(req, map[string]string{"id": "1"})
+		req = withAuthenticatedUser(req, actingUserID)
+
+		rr := httptest.NewRecorder()
+		TransferOwnership(rr, req)
+		return rr
+	}
+
+	if rr := transfer(1, `{"user_id":9}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d for a non-participant, got %d, body: %s", http.StatusBadRequest, rr.Code, rr.Body.String())
+	}
+	if rr := transfer(1, `{"user_id":2}`); rr.Code != http.StatusConflict {
+		t.Fatalf("expected status %d while the owner does not take part, got %d, body: %s", http.StatusConflict, rr.Code, rr.Body.String())
+	}
+	if _, err := db.Exec(`INSERT INTO Participants (group_id, user_id, joined_at, role) VALUES (1, 1, ?, 'owner')`, time.Now().UTC()); err != nil {
+		t.Fatalf("join the group: %v", err)
+	}
+
+	rr := transfer(1, `{"user_id":2}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d, body: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if decodeJSONBody(t, rr.Body.String())["creator_user_id"] != float64(2) {
+		t.Fatalf("expected user 2 to own the group, got: %s", rr.Body.String())
+	}
+
+	if rr := transfer(1, `{"user_id":1}`); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected previous owner to be forbidden, got status %d, body: %s", rr.Code, rr.Body.String())
+	}
+	if rr := updateTestRole(t, 2, "1", `{"role":"organizer"}`); rr.Code != http.StatusOK {
+		t.Fatalf("expected new owner to manage roles, got status %d, body: %s", rr.Code, rr.Body.String())
+	}
+}
@@ -0,0 +1,112 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/akctba/secret-santa-go-api/database"
+	"github.com/akctba/secret-santa-go-api/models"
+	"github.com/gorilla/mux"
+)
+
+type createEmailInvitationRequest struct {
+	Email string `json:"email"`
+}
+
+// CreateEmailInvitation handles POST /group/{id}/invitation. Lets an organizer invite someone
+// who has no account yet; they join the group as soon as they register with that email.
+func CreateEmailInvitation(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	groupID := vars["id"]
+
+	var request createEmailInvitationRequest
+	if err := decodeRequestJSON(r, &request); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	email := normalizeEmail(request.Email)
+	if !isEmailAddress(email) {
+		http.Error(w, "A valid email is required", http.StatusBadRequest)
+		return
+	}
+
+	db, err := getDB()
+	if err != nil {
+		log.Printf("failed to open db in CreateEmailInvitation: %v", err)
+		http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
+		return
+	}
+	defer database.CloseDb(db)
+
+	group, ok := authorizeGroup(w, r, db, groupID, groupAccessOrganizer)
+	if !ok {
+		return
+	}
+
+	if _, err := database.GetUserByEmail(db, email); err == nil {
+		http.Error(w, "User is already registered, add them as a participant instead", http.StatusConflict)
+		return
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Failed to get user", http.StatusInternalServerError)
+		return
+	}
+
+	userID, _ := authenticatedUserIDFromRequest(r)
+	invitation := models.EmailInvitation{
+		GroupID:   group.GroupID,
+		Email:     email,
+		InvitedBy: userID,
+	}
+
+	err = database.InsertEmailInvitation(db, &invitation)
+	if err != nil {
+		if errors.Is(err, database.ErrInvitationExists) {
+			http.Error(w, "Email has already been invited to this group", http.StatusConflict)
+			return
+		}
+
+		http.Error(w, "Failed to create invitation", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(invitation)
+}
+
+// GetEmailInvitations handles GET /group/{id}/invitation. Returns the group's email invitations
+// to its organizers, optionally filtered with ?status=pending or ?status=accepted.
+func GetEmailInvitations(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	groupID := vars["id"]
+
+	status := r.URL.Query().Get("status")
+	if status != "" && status != models.InvitationPending && status != models.InvitationAccepted {
+		http.Error(w, "status must be pending or accepted", http.StatusBadRequest)
+		return
+	}
+
+	db, err := getDB()
+	if err != nil {
+		log.Printf("failed to open db in GetEmailInvitations: %v", err)
+		http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
+		return
+	}
+	defer database.CloseDb(db)
+
+	if _, ok := authorizeGroup(w, r, db, groupID, groupAccessOrganizer); !ok {
+		return
+	}
+
+	invitations, err := database.GetEmailInvitationsByGroupID(db, groupID, status)
+	if err != nil {
+		http.Error(w, "Failed to get invitations", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(invitations)
+}
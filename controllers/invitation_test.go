@@ -0,0 +1,103 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/akctba/secret-santa-go-api/database"
+	"github.com/gorilla/mux"
+)
+
+func createTestEmailInvitation(t *testing.T, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodPost, "/group/1/invitation", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	req = withAuthenticatedUser(req, 1)
+
+	rr := httptest.NewRecorder()
+	CreateEmailInvitation(rr, req)
+	return rr
+}
+
+func listTestEmailInvitations(t *testing.T, status string) []map[string]any {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodGet, "/group/1/invitation?status="+status, nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	req = withAuthenticatedUser(req, 1)
+
+	rr := httptest.NewRecorder()
+	GetEmailInvitations(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("list invitations: status %d, body: %s", rr.Code, rr.Body.String())
+	}
+
+	var invitations []map[string]any
+	if err := json.Unmarshal(rr.Body.Bytes(), &invitations); err != nil {
+		t.Fatalf("decode invitations: %v", err)
+	}
+	return invitations
+}
+
+func TestVerifiedInviteeAcceptsPendingEmailInvitations(t *testing.T) {
+	db := setupMigratedTestDB(t)
+	seedGroupWithParticipants(t, db, 1, 1, 1)
+	withEmailToken(t, "verify-token")
+
+	rr := createTestEmailInvitation(t, `{"email":" Carol@Example.com "}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d, body: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+	payload := decodeJSONBody(t, rr.Body.String())
+	if payload["email"] != "carol@example.com" || payload["status"] != "pending" {
+		t.Fatalf("unexpected invitation payload: %s", rr.Body.String())
+	}
+
+	if rr := createTestEmailInvitation(t, `{"email":"carol@example.com"}`); rr.Code != http.StatusConflict {
+		t.Fatalf("expected status %d for a repeated invitation, got %d", http.StatusConflict, rr.Code)
+	}
+	if rr := createTestEmailInvitation(t, `{"email":"User1@Example.com"}`); rr.Code != http.StatusConflict {
+		t.Fatalf("expected status %d for a registered email, got %d", http.StatusConflict, rr.Code)
+	}
+	if rr := createTestEmailInvitation(t, `{"email":"not an email"}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d for an invalid email, got %d", http.StatusBadRequest, rr.Code)
+	}
+
+	if pending := listTestEmailInvitations(t, "pending"); len(pending) != 1 {
+		t.Fatalf("expected 1 pending invitation, got %v", pending)
+	}
+
+	rr = createTestUser(t, `{"user_name":"Carol","email":"Carol@example.com","password":"secret123"}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("create user: status %d, body: %s", rr.Code, rr.Body.String())
+	}
+
+	// Registering the address does not prove it belongs to the invitee.
+	userID := int(decodeJSONBody(t, rr.Body.String())["user_id"].(float64))
+	if _, err := database.GetUserParticipant(db, userID, 1); err == nil {
+		t.Fatal("expected the invitation to wait for verification")
+	}
+	if pending := listTestEmailInvitations(t, "pending"); len(pending) != 1 {
+		t.Fatalf("expected the invitation to stay pending, got %v", pending)
+	}
+
+	if rr := postUserRequest(t, ConfirmEmail, `{"token":"verify-token"}`); rr.Code != http.StatusNoContent {
+		t.Fatalf("confirm email: status %d, body: %s", rr.Code, rr.Body.String())
+	}
+	if _, err := database.GetUserParticipant(db, userID, 1); err != nil {
+		t.Fatalf("expected registered invitee to be a participant: %v", err)
+	}
+
+	if pending := listTestEmailInvitations(t, "pending"); len(pending) != 0 {
+		t.Fatalf("expected no pending invitations, got %v", pending)
+	}
+	accepted := listTestEmailInvitations(t, "accepted")
+	if len(accepted) != 1 || accepted[0]["user_id"] != float64(userID) {
+		t.Fatalf("expected invitation accepted by user %d, got %v", userID, accepted)
+	}
+}
@@ -0,0 +1,219 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/akctba/secret-santa-go-api/database"
+	"github.com/akctba/secret-santa-go-api/models"
+	"github.com/gorilla/mux"
+)
+
+// defaultInviteLifetime applies when an invite is created without expires_at.
+const defaultInviteLifetime = 7 * 24 * time.Hour
+
+// inviteCodeEncoding keeps codes short enough to read out loud and safe in URLs.
+var inviteCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// newInviteCode is swapped for a predictable generator in tests.
+var newInviteCode = func() (string, error) {
+	code := make([]byte, 10)
+	if _, err := rand.Read(code); err != nil {
+		return "", err
+	}
+	return inviteCodeEncoding.EncodeToString(code), nil
+}
+
+type createInviteRequest struct {
+	ExpiresAt *time.Time `json:"expires_at"`
+	MaxUses   int        `json:"max_uses"`
+}
+
+// CreateInvite handles POST /group/{id}/invite. Lets an organizer create a join code for the group.
+// A max_uses of zero allows any number of people to join until the invite expires.
+func CreateInvite(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	groupID := vars["id"]
+
+	var request createInviteRequest
+	if err := decodeRequestJSON(r, &request); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now().UTC()
+	expiresAt := now.Add(defaultInviteLifetime)
+	if request.ExpiresAt != nil {
+		expiresAt = request.ExpiresAt.UTC()
+	}
+	if !expiresAt.After(now) {
+		http.Error(w, "expires_at must be in the future", http.StatusBadRequest)
+		return
+	}
+	if request.MaxUses < 0 {
+		http.Error(w, "max_uses must not be negative", http.StatusBadRequest)
+		return
+	}
+
+	db, err := getDB()
+	if err != nil {
+		log.Printf("failed to open db in CreateInvite: %v", err)
+		http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
+		return
+	}
+	defer database.CloseDb(db)
+
+	group, ok := authorizeGroup(w, r, db, groupID, groupAccessOrganizer)
+	if !ok {
+		return
+	}
+
+	code, err := newInviteCode()
+	if err != nil {
+		log.Printf("failed to generate invite code: %v", err)
+		http.Error(w, "Failed to create invite", http.StatusInternalServerError)
+		return
+	}
+
+	userID, _ := authenticatedUserIDFromRequest(r)
+	invite := models.Invite{
+		GroupID:   group.GroupID,
+		Code:      code,
+		CreatedBy: userID,
+		ExpiresAt: expiresAt,
+		MaxUses:   request.MaxUses,
+	}
+
+	err = database.InsertInvite(db, &invite)
+	if err != nil {
+		http.Error(w, "Failed to create invite", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(invite)
+}
+
+// GetInvites handles GET /group/{id}/invite. Returns every invite of the group to its organizers.
+func GetInvites(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	groupID := vars["id"]
+
+	db, err := getDB()
+	if err != nil {
+		log.Printf("failed to open db in GetInvites: %v", err)
+		http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
+		return
+	}
+	defer database.CloseDb(db)
+
+	if _, ok := authorizeGroup(w, r, db, groupID, groupAccessOrganizer); !ok {
+		return
+	}
+
+	invites, err := database.GetInvitesByGroupID(db, groupID)
+	if err != nil {
+		http.Error(w, "Failed to get invites", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(invites)
+}
+
+// RevokeInvite handles DELETE /group/{id}/invite/{inviteId}. Lets an organizer stop an invite from being used.
+func RevokeInvite(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	groupID := vars["id"]
+	inviteID, err := strconv.Atoi(vars["inviteId"])
+	if err != nil {
+		http.Error(w, "Invalid invite ID", http.StatusBadRequest)
+		return
+	}
+
+	db, err := getDB()
+	if err != nil {
+		log.Printf("failed to open db in RevokeInvite: %v", err)
+		http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
+		return
+	}
+	defer database.CloseDb(db)
+
+	if _, ok := authorizeGroup(w, r, db, groupID, groupAccessOrganizer); !ok {
+		return
+	}
+
+	err = database.RevokeInvite(db, groupID, inviteID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Invite not found", http.StatusNotFound)
+			return
+		}
+
+		http.Error(w, "Failed to revoke invite", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AcceptInvite handles POST /invite/{code}/accept. Adds the authenticated user to the invite's group.
+func AcceptInvite(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	code := vars["code"]
+
+	userID, ok := authenticatedUserIDFromRequest(r)
+	if !ok {
+		http.Error(w, "Invalid token", http.StatusUnauthorized)
+		return
+	}
+
+	db, err := getDB()
+	if err != nil {
+		log.Printf("failed to open db in AcceptInvite: %v", err)
+		http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
+		return
+	}
+	defer database.CloseDb(db)
+
+	if requireEmailVerification {
+		user, ok := loadAuthenticatedUser(w, r, db)
+		if !ok {
+			return
+		}
+		if user.EmailVerifiedAt == nil {
+			http.Error(w, "Verify your email address before joining groups", http.StatusForbidden)
+			return
+		}
+	}
+
+	invite, err := database.AcceptInvite(db, code, userID, time.Now().UTC())
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrInviteNotFound):
+			http.Error(w, "Invite not found", http.StatusNotFound)
+		case errors.Is(err, database.ErrInviteExpired):
+			http.Error(w, "Invite has expired", http.StatusGone)
+		case errors.Is(err, database.ErrInviteExhausted):
+			http.Error(w, "Invite has reached its maximum uses", http.StatusGone)
+		case errors.Is(err, database.ErrAlreadyParticipant):
+			http.Error(w, "User is already a participant of this group", http.StatusConflict)
+		case errors.Is(err, database.ErrDrawExists):
+			http.Error(w, "Group has already been drawn", http.StatusConflict)
+		default:
+			log.Printf("accept invite failed: %v", err)
+			http.Error(w, "Failed to accept invite", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(models.ParticipantRequest{GroupID: invite.GroupID, UserID: userID})
+}
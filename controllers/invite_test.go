@@ -0,0 +1,167 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/akctba/secret-santa-go-api/database"
+	"github.com/gorilla/mux"
+)
+
+func withInviteCode(t *testing.T, code string) {
+	t.Helper()
+
+	original := newInviteCode
+	newInviteCode = func() (string, error) {
+		return code, nil
+	}
+	t.Cleanup(func() {
+		newInviteCode = original
+	})
+}
+
+func createTestInvite(t *testing.T, userID int, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodPost, "/group/1/invite", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	req = withAuthenticatedUser(req, userID)
+
+	rr := httptest.NewRecorder()
+	CreateInvite(rr, req)
+	return rr
+}
+
+func acceptTestInvite(t *testing.T, code string, userID int) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodPost, "/invite/"+code+"/accept", nil)
+	req = mux.SetURLVars(req, map[string]string{"code": code})
+	req = withAuthenticatedUser(req, userID)
+
+	rr := httptest.NewRecorder()
+	AcceptInvite(rr, req)
+	return rr
+}
+
+func TestAcceptInviteAddsParticipantUntilMaxUses(t *testing.T) {
+	db := setupMigratedTestDB(t)
+	seedGroupWithParticipants(t, db, 1, 1, 1)
+	withInviteCode(t, "JOINCODE")
+
+	rr := createTestInvite(t, 1, `{"max_uses":1}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d, body: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+	payload := decodeJSONBody(t, rr.Body.String())
+	if payload["code"] != "JOINCODE" || payload["max_uses"] != float64(1) || payload["created_by"] != float64(1) {
+		t.Fatalf("unexpected invite payload: %s", rr.Body.String())
+	}
+
+	rr = acceptTestInvite(t, "JOINCODE", 2)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d, body: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+	if _, err := database.GetUserParticipant(db, 2, 1); err != nil {
+		t.Fatalf("expected user 2 to be a participant: %v", err)
+	}
+
+	rr = acceptTestInvite(t, "JOINCODE", 3)
+	if rr.Code != http.StatusGone {
+		t.Fatalf("expected status %d once exhausted, got %d, body: %s", http.StatusGone, rr.Code, rr.Body.String())
+	}
+
+	if rr := acceptTestInvite(t, "UNKNOWN", 3); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected status %d for an unknown code, got %d", http.StatusNotFound, rr.Code)
+	}
+}
+
+func TestAcceptInviteRejectsMembersAndDrawnGroups(t *testing.T) {
+	db := setupMigratedTestDB(t)
+	seedGroupWithParticipants(t, db, 1, 1, 1, 2)
+	withInviteCode(t, "JOINCODE")
+
+	if rr := createTestInvite(t, 1, `{}`); rr.Code != http.StatusCreated {
+		t.Fatalf("create invite: status %d, body: %s", rr.Code, rr.Body.String())
+	}
+
+	rr := acceptTestInvite(t, "JOINCODE", 2)
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("expected status %d for an existing participant, got %d, body: %s", http.StatusConflict, rr.Code, rr.Body.String())
+	}
+
+	if rr := runTestDraw(t, ""); rr.Code != http.StatusCreated {
+		t.Fatalf("run draw: status %d, body: %s", rr.Code, rr.Body.String())
+	}
+
+	rr = acceptTestInvite(t, "JOINCODE", 3)
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("expected status %d for a drawn group, got %d, body: %s", http.StatusConflict, rr.Code, rr.Body.String())
+	}
+	if !strings.Contains(rr.Body.String(), "Group has already been drawn") {
+		t.Fatalf("expected already-drawn error, got: %s", rr.Body.String())
+	}
+
+	var uses int
+	if err := db.QueryRow(`SELECT uses FROM GroupInvites WHERE code = 'JOINCODE'`).Scan(&uses); err != nil {
+		t.Fatalf("load invite uses: %v", err)
+	}
+	if uses != 0 {
+		t.Fatalf("expected rejected accepts not to use the invite, got %d uses", uses)
+	}
+}
+
+func TestRevokeInviteStopsAccepts(t *testing.T) {
+	db := setupMigratedTestDB(t)
+	seedGroupWithParticipants(t, db, 1, 1, 1, 2)
+	withInviteCode(t, "JOINCODE")
+
+	if rr := createTestInvite(t, 2, `{}`); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected status %d for a member, got %d, body: %s", http.StatusForbidden, rr.Code, rr.Body.String())
+	}
+	if rr := createTestInvite(t, 1, `{"expires_at":"2000-01-01T00:00:00Z"}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d for a past expiry, got %d, body: %s", http.StatusBadRequest, rr.Code, rr.Body.String())
+	}
+	if rr := createTestInvite(t, 1, `{}`); rr.Code != http.StatusCreated {
+		t.Fatalf("create invite: status %d, body: %s", rr.Code, rr.Body.String())
+	}
+
+	revoke := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodDelete, "/group/1/invite/1", nil)
+		req = mux.SetURLVars(req, map[string]string{"id": "1", "inviteId": "1"})
+		req = withAuthenticatedUser(req, 1)
+
+		rr := httptest.NewRecorder()
+		RevokeInvite(rr, req)
+		return rr
+	}
+
+	if rr := revoke(); rr.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d, got %d, body: %s", http.StatusNoContent, rr.Code, rr.Body.String())
+	}
+	if rr := revoke(); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected status %d revoking twice, got %d", http.StatusNotFound, rr.Code)
+	}
+
+	if rr := acceptTestInvite(t, "JOINCODE", 3); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected status %d for a revoked invite, got %d, body: %s", http.StatusNotFound, rr.Code, rr.Body.String())
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/group/1/invite", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	req = withAuthenticatedUser(req, 1)
+	rr := httptest.NewRecorder()
+	GetInvites(rr, req)
+
+	var invites []map[string]any
+	if err := json.Unmarshal(rr.Body.Bytes(), &invites); err != nil {
+		t.Fatalf("decode invites: %v, body: %s", err, rr.Body.String())
+	}
+	if len(invites) != 1 || invites[0]["revoked_at"] == nil {
+		t.Fatalf("expected one revoked invite, got %s", rr.Body.String())
+	}
+}
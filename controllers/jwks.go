@@ -0,0 +1,27 @@
+package controllers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/akctba/secret-santa-go-api/auth"
+)
+
+// GetJWKS handles GET /.well-known/jwks.json. Publishes the public keys access and refresh
+// tokens are signed with, so other services can verify them.
+func GetJWKS(w http.ResponseWriter, r *http.Request) {
+	keys, err := auth.PublicKeys()
+	if err != nil {
+		log.Printf("failed to load jwt keys in GetJWKS: %v", err)
+		http.Error(w, "Failed to load keys", http.StatusInternalServerError)
+		return
+	}
+
+	// Short enough that verifiers pick up a rotated key well within the
+	// previous key's grace period.
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(keys)
+}
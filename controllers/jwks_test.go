@@ -0,0 +1,21 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGetJWKSNeverPublishesTheSecret(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+
+	rr := httptest.NewRecorder()
+	GetJWKS(rr, httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d, body: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if got := rr.Body.String(); got != "{\"keys\":[]}\n" {
+		t.Fatalf("expected an empty key set, got %s", got)
+	}
+}
@@ -0,0 +1,151 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/akctba/secret-santa-go-api/database"
+	"github.com/akctba/secret-santa-go-api/notify"
+)
+
+// magicLinkTokenLifetime is how long a login link stays usable. It is kept
+// short since the link alone signs the user in.
+const magicLinkTokenLifetime = 15 * time.Minute
+
+// magicLinkURL is the page of the web app that signs users in with a login
+// link. The token is added to it as the token query parameter.
+var magicLinkURL string
+
+type magicLinkRequest struct {
+	Email string `json:"email"`
+}
+
+type consumeMagicLinkRequest struct {
+	Token string `json:"token"`
+}
+
+// SetMagicLinkURL sets the page linked from login link emails. Without one,
+// the email contains the token for the user to paste instead.
+func SetMagicLinkURL(rawURL string) error {
+	if rawURL != "" && !isWebLink(rawURL) {
+		return fmt.Errorf("%q is not an http or https link", rawURL)
+	}
+	magicLinkURL = rawURL
+	return nil
+}
+
+// RequestMagicLink handles POST /user/magic-link. Emails a single-use login link to the
+// address if it belongs to a user. Like ForgotPassword, it always answers 202, so it cannot
+// be used to find out which addresses are registered.
+func RequestMagicLink(w http.ResponseWriter, r *http.Request) {
+	var request magicLinkRequest
+	if err := decodeRequestJSON(r, &request); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	email := normalizeEmail(request.Email)
+	if email == "" {
+		http.Error(w, "email is required", http.StatusBadRequest)
+		return
+	}
+
+	db, err := getDB()
+	if err != nil {
+		log.Printf("failed to open db in RequestMagicLink: %v", err)
+		http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
+		return
+	}
+	defer database.CloseDb(db)
+
+	user, err := database.GetUserByEmail(db, email)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("failed to load user for login link: %v", err)
+		}
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	token, err := newEmailToken()
+	if err != nil {
+		log.Printf("failed to generate login link token: %v", err)
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	expiresAt := time.Now().UTC().Add(magicLinkTokenLifetime)
+	err = database.InsertMagicLinkToken(db, user.UserID, hashToken(token), expiresAt)
+	if err != nil {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	sendNotification(notify.KindMagicLink, user.UserEmail, notify.Data{
+		UserName:  user.UserName,
+		Token:     token,
+		TokenURL:  emailTokenLink(magicLinkURL, token),
+		ExpiresAt: expiresAt,
+	})
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// ConsumeMagicLink handles POST /user/magic-link/consume. Exchanges the token from a login
+// link for the same tokens Signin returns. The link also verifies the user's email address.
+// The first time, it also removes the password and signs out the sessions of whoever
+// registered the address.
+func ConsumeMagicLink(w http.ResponseWriter, r *http.Request) {
+	var request consumeMagicLinkRequest
+	if err := decodeRequestJSON(r, &request); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if request.Token == "" {
+		http.Error(w, "token is required", http.StatusBadRequest)
+		return
+	}
+
+	db, err := getDB()
+	if err != nil {
+		log.Printf("failed to open db in ConsumeMagicLink: %v", err)
+		http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
+		return
+	}
+	defer database.CloseDb(db)
+
+	userID, err := database.ConsumeMagicLinkToken(db, hashToken(request.Token), time.Now().UTC())
+	if err != nil {
+		if errors.Is(err, database.ErrMagicLinkInvalid) {
+			http.Error(w, "Invalid or expired login link", http.StatusUnauthorized)
+			return
+		}
+
+		http.Error(w, "Failed to sign in", http.StatusInternalServerError)
+		return
+	}
+
+	// Like ConfirmEmail, this joins the groups the address was invited to,
+	// which waited for the address to be verified.
+	user, err := database.GetUserByID(db, userID)
+	if err != nil {
+		log.Printf("failed to load user %d after login link: %v", userID, err)
+	} else {
+		acceptEmailInvitations(db, user)
+	}
+
+	tokens, err := issueSigninTokens(db, userID)
+	if err != nil {
+		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(tokens)
+}
@@ -0,0 +1,149 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/akctba/secret-santa-go-api/auth"
+	"github.com/akctba/secret-santa-go-api/database"
+)
+
+func TestMagicLinkSignsTheUserInOnce(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+	db := setupMigratedTestDB(t)
+	seedGroupWithParticipants(t, db, 1, 1, 1)
+	recorder := withRecordingMailer(t)
+	withEmailToken(t, "login-token")
+	if err := SetMagicLinkURL("https://app.example.com/login"); err != nil {
+		t.Fatalf("SetMagicLinkURL returned error: %v", err)
+	}
+
+	if rr := postUserRequest(t, RequestMagicLink, `{"email":"nobody@example.com"}`); rr.Code != http.StatusAccepted {
+		t.Fatalf("expected status %d for an unknown email, got %d", http.StatusAccepted, rr.Code)
+	}
+	if len(recorder.messages) != 0 {
+		t.Fatalf("expected no email for an unknown address, got %+v", recorder.messages)
+	}
+
+	if rr := postUserRequest(t, RequestMagicLink, `{"email":"User1@example.com"}`); rr.Code != http.StatusAccepted {
+		t.Fatalf("expected status %d, got %d", http.StatusAccepted, rr.Code)
+	}
+	if len(recorder.messages) != 1 || !strings.Contains(recorder.messages[0].Text, "https://app.example.com/login?token=login-token") {
+		t.Fatalf("expected a login link to be emailed, got %+v", recorder.messages)
+	}
+
+	body := `{"token":"login-token"}`
+	rr := postUserRequest(t, ConsumeMagicLink, body)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d, body: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	payload := decodeJSONBody(t, rr.Body.String())
+	if userID, err := auth.ValidateToken(payload["access_token"].(string)); err != nil || userID != 1 {
+		t.Fatalf("expected an access token for user 1, got %d, %v", userID, err)
+	}
+	if _, code := refreshTestToken(t, payload["refresh_token"].(string)); code != http.StatusOK {
+		t.Fatalf("expected a working refresh token, got status %d", code)
+	}
+
+	if rr := postUserRequest(t, ConsumeMagicLink, body); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected status %d for a used link, got %d", http.StatusUnauthorized, rr.Code)
+	}
+
+	user, err := database.GetUserByID(db, 1)
+	if err != nil {
+		t.Fatalf("load user: %v", err)
+	}
+	if user.EmailVerifiedAt == nil {
+		t.Fatal("expected the login link to verify the email address")
+	}
+}
+
+func TestMagicLinkRejectsExpiredAndOlderLinks(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+	db := setupMigratedTestDB(t)
+	seedGroupWithParticipants(t, db, 1, 1, 1)
+	withRecordingMailer(t)
+
+	expiresAt := time.Now().UTC().Add(-time.Minute)
+	if err := database.InsertMagicLinkToken(db, 1, hashToken("expired-token"), expiresAt); err != nil {
+		t.Fatalf("InsertMagicLinkToken returned error: %v", err)
+	}
+	if rr := postUserRequest(t, ConsumeMagicLink, `{"token":"expired-token"}`); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected status %d for an expired link, got %d", http.StatusUnauthorized, rr.Code)
+	}
+
+	for _, token := range []string{"first-token", "second-token"} {
+		withEmailToken(t, token)
+		if rr := postUserRequest(t, RequestMagicLink, `{"email":"user1@example.com"}`); rr.Code != http.StatusAccepted {
+			t.Fatalf("expected status %d, got %d", http.StatusAccepted, rr.Code)
+		}
+	}
+
+	if rr := postUserRequest(t, ConsumeMagicLink, `{"token":"second-token"}`); rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d, body: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if rr := postUserRequest(t, ConsumeMagicLink, `{"token":"first-token"}`); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected older links to be used up, got %d", rr.Code)
+	}
+}
+
+func TestMagicLinkSignsOutWhoeverRegisteredAnUnverifiedAddress(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+	db := setupMigratedTestDB(t)
+	seedGroupWithParticipants(t, db, 1, 1, 1)
+	withRecordingMailer(t)
+	withEmailToken(t, "login-token")
+
+	if rr := createTestEmailInvitation(t, `{"email":"victim@example.com"}`); rr.Code != http.StatusCreated {
+		t.Fatalf("create invitation: status %d, body: %s", rr.Code, rr.Body.String())
+	}
+
+	// Someone registers the address before its owner ever signs in.
+	rr := createTestUser(t, `{"user_name":"Mallory","email":"victim@example.com","password":"attacker-secret"}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("create user: status %d, body: %s", rr.Code, rr.Body.String())
+	}
+	userID := int(decodeJSONBody(t, rr.Body.String())["user_id"].(float64))
+	rr = postUserRequest(t, Signin, `{"email":"victim@example.com","password":"attacker-secret"}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("sign in: status %d, body: %s", rr.Code, rr.Body.String())
+	}
+	refreshToken := decodeJSONBody(t, rr.Body.String())["refresh_token"].(string)
+
+	if rr := postUserRequest(t, RequestMagicLink, `{"email":"victim@example.com"}`); rr.Code != http.StatusAccepted {
+		t.Fatalf("request login link: status %d", rr.Code)
+	}
+	if rr := postUserRequest(t, ConsumeMagicLink, `{"token":"login-token"}`); rr.Code != http.StatusOK {
+		t.Fatalf("consume login link: status %d, body: %s", rr.Code, rr.Body.String())
+	}
+
+	if rr := postUserRequest(t, Signin, `{"email":"victim@example.com","password":"attacker-secret"}`); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected the pre-set password to stop working, got status %d", rr.Code)
+	}
+	if _, code := refreshTestToken(t, refreshToken); code != http.StatusUnauthorized {
+		t.Fatalf("expected earlier sessions to be revoked, got status %d", code)
+	}
+	if _, err := database.GetUserParticipant(db, userID, 1); err != nil {
+		t.Fatalf("expected the address's owner to join the group they were invited to: %v", err)
+	}
+
+	// Verified accounts were registered by the address's owner and keep their password.
+	if rr := createTestUser(t, `{"user_name":"Erin","email":"erin@example.com","password":"erin-secret"}`); rr.Code != http.StatusCreated {
+		t.Fatalf("create user: status %d, body: %s", rr.Code, rr.Body.String())
+	}
+	if _, err := db.Exec(`UPDATE Users SET email_verified_at = ? WHERE user_email = 'erin@example.com'`, time.Now().UTC()); err != nil {
+		t.Fatalf("verify email: %v", err)
+	}
+	withEmailToken(t, "erin-token")
+	if rr := postUserRequest(t, RequestMagicLink, `{"email":"erin@example.com"}`); rr.Code != http.StatusAccepted {
+		t.Fatalf("request login link: status %d", rr.Code)
+	}
+	if rr := postUserRequest(t, ConsumeMagicLink, `{"token":"erin-token"}`); rr.Code != http.StatusOK {
+		t.Fatalf("consume login link: status %d, body: %s", rr.Code, rr.Body.String())
+	}
+	if rr := postUserRequest(t, Signin, `{"email":"erin@example.com","password":"erin-secret"}`); rr.Code != http.StatusOK {
+		t.Fatalf("expected a verified account to keep its password, got status %d", rr.Code)
+	}
+}
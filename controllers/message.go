@@ -0,0 +1,229 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/akctba/secret-santa-go-api/database"
+	"github.com/akctba/secret-santa-go-api/models"
+	"github.com/gorilla/mux"
+)
+
+// maxMessageLength is the longest message body, in characters.
+const maxMessageLength = 2000
+
+// secretSantaName is all the receiver ever learns about the giver.
+const secretSantaName = "your Secret Santa"
+
+type postMessageRequest struct {
+	Body string `json:"body"`
+}
+
+// messageResponse deliberately has no sender ID: who is speaking is only told
+// relative to the reader.
+type messageResponse struct {
+	MessageID   int       `json:"message_id"`
+	FromMe      bool      `json:"from_me"`
+	Body        string    `json:"body"`
+	DateCreated time.Time `json:"date_created"`
+}
+
+type messageThreadResponse struct {
+	GroupID  string            `json:"group_id"`
+	With     string            `json:"with"`
+	Messages []messageResponse `json:"messages"`
+}
+
+// messageThread identifies the conversation between a giver and the friend they
+// drew, as seen by one of its sides.
+type messageThread struct {
+	groupID        string
+	drawID         int
+	giverUserID    int
+	receiverUserID int
+	side           string
+}
+
+func toMessageResponse(message models.Message, side string) messageResponse {
+	return messageResponse{
+		MessageID:   message.MessageID,
+		FromMe:      message.Sender == side,
+		Body:        message.Body,
+		DateCreated: message.DateCreated,
+	}
+}
+
+// loadMessageThread finds the thread in which the authenticated user is the given side.
+// It writes the error response and returns false when there is no such thread.
+func loadMessageThread(w http.ResponseWriter, r *http.Request, db *sql.DB, side string) (messageThread, bool) {
+	groupID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid group ID", http.StatusBadRequest)
+		return messageThread{}, false
+	}
+
+	userID, ok := authenticatedUserIDFromRequest(r)
+	if !ok {
+		http.Error(w, "Invalid token", http.StatusUnauthorized)
+		return messageThread{}, false
+	}
+
+	participant, err := database.GetUserParticipant(db, userID, groupID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "User is not a participant of this group", http.StatusForbidden)
+			return messageThread{}, false
+		}
+
+		http.Error(w, "Failed to get participant", http.StatusInternalServerError)
+		return messageThread{}, false
+	}
+
+	thread := messageThread{groupID: strconv.Itoa(groupID), side: side}
+	if side == models.SenderGiver {
+		thread.giverUserID, thread.receiverUserID = userID, participant.FriendUserID
+	} else {
+		thread.receiverUserID = userID
+		thread.giverUserID, err = database.GetGiverUserID(db, thread.groupID, userID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Failed to get participant", http.StatusInternalServerError)
+			return thread, false
+		}
+	}
+
+	if thread.giverUserID == 0 || thread.receiverUserID == 0 {
+		http.Error(w, "Secret friend has not been drawn yet", http.StatusConflict)
+		return thread, false
+	}
+
+	// Groups drawn before draws were recorded keep their thread under draw 0.
+	draw, err := database.GetDrawByGroupID(db, thread.groupID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Failed to get draw", http.StatusInternalServerError)
+		return thread, false
+	}
+	thread.drawID = draw.DrawID
+
+	return thread, true
+}
+
+// getMessageThread returns the thread of the authenticated user on the given side.
+func getMessageThread(w http.ResponseWriter, r *http.Request, side string) {
+	db, err := getDB()
+	if err != nil {
+		log.Printf("failed to open db in getMessageThread: %v", err)
+		http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
+		return
+	}
+	defer database.CloseDb(db)
+
+	thread, ok := loadMessageThread(w, r, db, side)
+	if !ok {
+		return
+	}
+
+	response := messageThreadResponse{GroupID: thread.groupID, With: secretSantaName}
+	if side == models.SenderGiver {
+		friend, err := database.GetUserByID(db, thread.receiverUserID)
+		if err != nil {
+			http.Error(w, "Failed to get secret friend", http.StatusInternalServerError)
+			return
+		}
+		response.With = friend.UserName
+	}
+
+	messages, err := database.GetMessageThread(db, thread.groupID, thread.drawID, thread.giverUserID, thread.receiverUserID)
+	if err != nil {
+		http.Error(w, "Failed to get messages", http.StatusInternalServerError)
+		return
+	}
+
+	response.Messages = make([]messageResponse, 0, len(messages))
+	for _, message := range messages {
+		response.Messages = append(response.Messages, toMessageResponse(message, side))
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// postMessage adds a message from the authenticated user on the given side.
+func postMessage(w http.ResponseWriter, r *http.Request, side string) {
+	var request postMessageRequest
+	if err := decodeRequestJSON(r, &request); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	body := strings.TrimSpace(request.Body)
+	if body == "" {
+		http.Error(w, "body is required", http.StatusBadRequest)
+		return
+	}
+	if utf8.RuneCountInString(body) > maxMessageLength {
+		http.Error(w, "body must be at most 2000 characters", http.StatusBadRequest)
+		return
+	}
+
+	db, err := getDB()
+	if err != nil {
+		log.Printf("failed to open db in postMessage: %v", err)
+		http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
+		return
+	}
+	defer database.CloseDb(db)
+
+	thread, ok := loadMessageThread(w, r, db, side)
+	if !ok {
+		return
+	}
+
+	message := models.Message{
+		GroupID:        thread.groupID,
+		DrawID:         thread.drawID,
+		GiverUserID:    thread.giverUserID,
+		ReceiverUserID: thread.receiverUserID,
+		Sender:         side,
+		Body:           body,
+	}
+
+	err = database.InsertMessage(db, &message)
+	if err != nil {
+		http.Error(w, "Failed to send message", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(toMessageResponse(message, side))
+}
+
+// GetFriendMessages handles GET /group/{id}/friend/message. Returns the authenticated user's
+// conversation with the secret friend they drew.
+func GetFriendMessages(w http.ResponseWriter, r *http.Request) {
+	getMessageThread(w, r, models.SenderGiver)
+}
+
+// PostFriendMessage handles POST /group/{id}/friend/message. Sends an anonymous message to the
+// secret friend the authenticated user drew.
+func PostFriendMessage(w http.ResponseWriter, r *http.Request) {
+	postMessage(w, r, models.SenderGiver)
+}
+
+// GetSantaMessages handles GET /group/{id}/santa/message. Returns the authenticated user's
+// conversation with their Secret Santa, who is never named.
+func GetSantaMessages(w http.ResponseWriter, r *http.Request) {
+	getMessageThread(w, r, models.SenderReceiver)
+}
+
+// PostSantaMessage handles POST /group/{id}/santa/message. Replies to the authenticated user's
+// Secret Santa.
+func PostSantaMessage(w http.ResponseWriter, r *http.Request) {
+	postMessage(w, r, models.SenderReceiver)
+}
@@ -0,0 +1,182 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func serveMessageRequest(t *testing.T, handler http.HandlerFunc, method string, userID int, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(method, "/group/1/message", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	req = withAuthenticatedUser(req, userID)
+
+	rr := httptest.NewRecorder()
+	handler(rr, req)
+	return rr
+}
+
+type testMessageThread struct {
+	GroupID  string `json:"group_id"`
+	With     string `json:"with"`
+	Messages []struct {
+		FromMe bool   `json:"from_me"`
+		Body   string `json:"body"`
+	} `json:"messages"`
+}
+
+func decodeMessageThread(t *testing.T, rr *httptest.ResponseRecorder) testMessageThread {
+	t.Helper()
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d, body: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	var thread testMessageThread
+	if err := json.Unmarshal(rr.Body.Bytes(), &thread); err != nil {
+		t.Fatalf("decode thread: %v, body: %s", err, rr.Body.String())
+	}
+	return thread
+}
+
+// seedNamedGroup gives every participant a distinct name and email, so a leaked
+// identity would show up in the responses.
+func seedNamedGroup(t *testing.T, db *sql.DB) {
+	t.Helper()
+
+	seedGroupWithParticipants(t, db, 1, 1, 1, 2, 3)
+	for userID, name := range map[int]string{1: "Alice", 2: "Bob", 3: "Carol"} {
+		_, err := db.Exec(`UPDATE Users SET user_name = ?, user_email = ? WHERE user_id = ?`,
+			name, strings.ToLower(name)+"@example.com", userID)
+		if err != nil {
+			t.Fatalf("name user %d: %v", userID, err)
+		}
+	}
+}
+
+func testFriendOf(t *testing.T, db *sql.DB, userID int) int {
+	t.Helper()
+
+	var friendUserID int
+	err := db.QueryRow(`SELECT friend_user_id FROM Participants WHERE group_id = 1 AND user_id = ?`, userID).Scan(&friendUserID)
+	if err != nil {
+		t.Fatalf("load friend of user %d: %v", userID, err)
+	}
+	return friendUserID
+}
+
+func TestMessagesKeepTheGiverAnonymous(t *testing.T) {
+	db := setupMigratedTestDB(t)
+	seedNamedGroup(t, db)
+
+	if rr := serveMessageRequest(t, PostFriendMessage, http.MethodPost, 1, `{"body":"Hi"}`); rr.Code != http.StatusConflict {
+		t.Fatalf("expected status %d before the draw, got %d, body: %s", http.StatusConflict, rr.Code, rr.Body.String())
+	}
+	if rr := runTestDraw(t, ""); rr.Code != http.StatusCreated {
+		t.Fatalf("run draw: status %d, body: %s", rr.Code, rr.Body.String())
+	}
+
+	giver := 1
+	receiver := testFriendOf(t, db, giver)
+	names := map[int]string{1: "Alice", 2: "Bob", 3: "Carol"}
+
+	rr := serveMessageRequest(t, PostFriendMessage, http.MethodPost, giver, `{"body":"What size are you?"}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d, body: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+
+	rr = serveMessageRequest(t, GetSantaMessages, http.MethodGet, receiver, "")
+	for _, leak := range []string{names[giver], strings.ToLower(names[giver]), "user_id", "giver"} {
+		if strings.Contains(rr.Body.String(), leak) {
+			t.Fatalf("expected the receiver's thread not to mention %q, got: %s", leak, rr.Body.String())
+		}
+	}
+	thread := decodeMessageThread(t, rr)
+	if thread.With != "your Secret Santa" || len(thread.Messages) != 1 || thread.Messages[0].FromMe {
+		t.Fatalf("unexpected receiver thread: %s", rr.Body.String())
+	}
+
+	rr = serveMessageRequest(t, PostSantaMessage, http.MethodPost, receiver, `{"body":"Medium, thanks!"}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d, body: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+	if strings.Contains(rr.Body.String(), names[giver]) || strings.Contains(rr.Body.String(), "user_id") {
+		t.Fatalf("expected the reply not to identify the giver, got: %s", rr.Body.String())
+	}
+
+	thread = decodeMessageThread(t, serveMessageRequest(t, GetFriendMessages, http.MethodGet, giver, ""))
+	if thread.With != names[receiver] || len(thread.Messages) != 2 || !thread.Messages[0].FromMe || thread.Messages[1].FromMe {
+		t.Fatalf("unexpected giver thread: %+v", thread)
+	}
+
+	// The third participant's threads are separate conversations.
+	for userID := range names {
+		if userID == giver || userID == receiver {
+			continue
+		}
+		thread = decodeMessageThread(t, serveMessageRequest(t, GetSantaMessages, http.MethodGet, userID, ""))
+		if len(thread.Messages) != 0 {
+			t.Fatalf("expected user %d to see no messages, got %+v", userID, thread)
+		}
+	}
+}
+
+func TestMessagesStartOverAfterRedraw(t *testing.T) {
+	db := setupMigratedTestDB(t)
+	seedNamedGroup(t, db)
+
+	if rr := runTestDraw(t, ""); rr.Code != http.StatusCreated {
+		t.Fatalf("run draw: status %d, body: %s", rr.Code, rr.Body.String())
+	}
+	if rr := serveMessageRequest(t, PostFriendMessage, http.MethodPost, 1, `{"body":"Hi"}`); rr.Code != http.StatusCreated {
+		t.Fatalf("post message: status %d, body: %s", rr.Code, rr.Body.String())
+	}
+
+	if rr := postGroupDrawAction(t, Redraw, "/group/1/redraw", 1); rr.Code != http.StatusCreated {
+		t.Fatalf("redraw: status %d, body: %s", rr.Code, rr.Body.String())
+	}
+
+	for _, userID := range []int{1, 2, 3} {
+		thread := decodeMessageThread(t, serveMessageRequest(t, GetSantaMessages, http.MethodGet, userID, ""))
+		if len(thread.Messages) != 0 {
+			t.Fatalf("expected user %d to start a new thread after the redraw, got %+v", userID, thread)
+		}
+	}
+}
+
+func TestPostMessageRejectsInvalidRequests(t *testing.T) {
+	db := setupMigratedTestDB(t)
+	seedNamedGroup(t, db)
+
+	if rr := runTestDraw(t, ""); rr.Code != http.StatusCreated {
+		t.Fatalf("run draw: status %d, body: %s", rr.Code, rr.Body.String())
+	}
+
+	tests := []struct {
+		name     string
+		userID   int
+		body     string
+		wantCode int
+	}{
+		{name: "empty body", userID: 1, body: `{"body":"   "}`, wantCode: http.StatusBadRequest},
+		{name: "too long", userID: 1, body: `{"body":"` + strings.Repeat("a", maxMessageLength+1) + `"}`, wantCode: http.StatusBadRequest},
+		{name: "not a participant", userID: 9, body: `{"body":"Hi"}`, wantCode: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := serveMessageRequest(t, PostFriendMessage, http.MethodPost, tt.userID, tt.body)
+			if rr.Code != tt.wantCode {
+				t.Fatalf("expected status %d, got %d, body: %s", tt.wantCode, rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
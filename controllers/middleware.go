@@ -2,6 +2,7 @@ package controllers
 
 import (
 	"context"
+	"log"
 	"net/http"
 	"strings"
 
@@ -38,6 +39,7 @@ func BearerAuth(next http.HandlerFunc) http.HandlerFunc {
 		token := strings.TrimPrefix(authHeader, "Bearer ")
 		userID, err := auth.ValidateToken(token)
 		if err != nil {
+			log.Printf("rejected access token for %s %s: %v", r.Method, r.URL.Path, err)
 			http.Error(w, "Invalid token", http.StatusUnauthorized)
 			return
 		}
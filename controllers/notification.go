@@ -0,0 +1,140 @@
+package controllers
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+
+	"github.com/akctba/secret-santa-go-api/database"
+	"github.com/akctba/secret-santa-go-api/models"
+	"github.com/akctba/secret-santa-go-api/notify"
+	"github.com/gorilla/mux"
+)
+
+// mailer delivers notifications; main replaces it with the configured mailer.
+var mailer notify.Mailer = notify.DiscardMailer{}
+
+// runAsync keeps email delivery out of the request path. Tests run it inline.
+var runAsync = func(fn func()) {
+	go fn()
+}
+
+// SetMailer sets the mailer used for notifications.
+func SetMailer(m notify.Mailer) {
+	mailer = m
+}
+
+// sendNotification renders and sends one notification in the background.
+// Failures are only logged: a missing email never fails the request.
+func sendNotification(kind notify.Kind, to string, data notify.Data) {
+	msg, err := notify.Render(kind, to, data)
+	if err != nil {
+		log.Printf("failed to render %s email: %v", kind, err)
+		return
+	}
+
+	m := mailer
+	runAsync(func() {
+		if err := m.Send(msg); err != nil {
+			log.Printf("failed to send %s email for group %s: %v", kind, data.GroupID, err)
+		}
+	})
+}
+
+// notifyAdded tells a user they were added to the group.
+func notifyAdded(db *sql.DB, group models.Group, userID int) {
+	user, err := database.GetUserByID(db, userID)
+	if err != nil {
+		log.Printf("failed to load user %d for added email: %v", userID, err)
+		return
+	}
+
+	sendNotification(notify.KindAdded, user.UserEmail, notify.Data{
+		UserName:  user.UserName,
+		GroupID:   group.GroupID,
+		GroupName: group.Name,
+		DateDraw:  group.DateDraw,
+	})
+}
+
+// notifyReassigned tells a giver who their new secret friend is after the
+// friend they drew left the group.
+func notifyReassigned(db *sql.DB, group models.Group, relinked models.Participant) {
+	giver, err := database.GetUserByID(db, relinked.UserID)
+	if err != nil {
+		log.Printf("failed to load user %d for reassigned email: %v", relinked.UserID, err)
+		return
+	}
+	friend, err := database.GetUserByID(db, relinked.FriendUserID)
+	if err != nil {
+		log.Printf("failed to load user %d for reassigned email: %v", relinked.FriendUserID, err)
+		return
+	}
+
+	sendNotification(notify.KindReassigned, giver.UserEmail, notify.Data{
+		UserName:   giver.UserName,
+		GroupID:    group.GroupID,
+		GroupName:  group.Name,
+		DateDraw:   group.DateDraw,
+		FriendName: friend.UserName,
+	})
+}
+
+// notifyGroup sends a notification of the given kind to every participant of
+// the group, naming their secret friend once the group has been drawn.
+func notifyGroup(db *sql.DB, group models.Group, kind notify.Kind) error {
+	participants, err := database.GetParticipantsToDraw(db, group.GroupID)
+	if err != nil {
+		return err
+	}
+
+	users := make(map[int]models.User, len(participants))
+	for _, participant := range participants {
+		user, err := database.GetUserByID(db, participant.UserID)
+		if err != nil {
+			return err
+		}
+		users[participant.UserID] = user
+	}
+
+	for _, participant := range participants {
+		user := users[participant.UserID]
+		sendNotification(kind, user.UserEmail, notify.Data{
+			UserName:   user.UserName,
+			GroupID:    group.GroupID,
+			GroupName:  group.Name,
+			DateDraw:   group.DateDraw,
+			FriendName: users[participant.FriendUserID].UserName,
+		})
+	}
+
+	return nil
+}
+
+// SendReminder handles POST /group/{id}/reminder. Lets an organizer email every participant a
+// reminder, including who they drew once the group has been drawn.
+func SendReminder(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	groupID := vars["id"]
+
+	db, err := getDB()
+	if err != nil {
+		log.Printf("failed to open db in SendReminder: %v", err)
+		http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
+		return
+	}
+	defer database.CloseDb(db)
+
+	group, ok := authorizeGroup(w, r, db, groupID, groupAccessOrganizer)
+	if !ok {
+		return
+	}
+
+	if err := notifyGroup(db, group, notify.KindReminder); err != nil {
+		log.Printf("failed to send reminders for group %s: %v", group.GroupID, err)
+		http.Error(w, "Failed to send reminders", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
@@ -0,0 +1,111 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/akctba/secret-santa-go-api/notify"
+	"github.com/gorilla/mux"
+)
+
+type recordingMailer struct {
+	messages []notify.Message
+}
+
+func (m *recordingMailer) Send(msg notify.Message) error {
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+// withRecordingMailer captures notifications and sends them synchronously.
+func withRecordingMailer(t *testing.T) *recordingMailer {
+	t.Helper()
+
+	originalMailer := mailer
+	originalRunAsync := runAsync
+	recorder := &recordingMailer{}
+	mailer = recorder
+	runAsync = func(fn func()) {
+		fn()
+	}
+
+	t.Cleanup(func() {
+		mailer = originalMailer
+		runAsync = originalRunAsync
+	})
+
+	return recorder
+}
+
+func TestAddParticipantSendsAddedEmail(t *testing.T) {
+	db := setupMigratedTestDB(t)
+	seedGroupWithParticipants(t, db, 1, 1, 1)
+	recorder := withRecordingMailer(t)
+
+	_, err := db.Exec(`INSERT INTO Users (user_id, user_name, user_email, password) VALUES (2, 'Bob', 'bob@example.com', 'secret')`)
+	if err != nil {
+		t.Fatalf("insert user: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/group/1/participant", strings.NewReader(`{"group_id":"1","user_id":2}`))
+	req.Header.Set("Content-Type", "application/json")
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	req = withAuthenticatedUser(req, 1)
+
+	rr := httptest.NewRecorder()
+	AddParticipant(rr, req)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d, body: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+
+	if len(recorder.messages) != 1 {
+		t.Fatalf("expected 1 email, got %d", len(recorder.messages))
+	}
+	msg := recorder.messages[0]
+	if msg.To != "bob@example.com" || msg.Subject != "You have been added to Holiday Crew" {
+		t.Fatalf("unexpected email %+v", msg)
+	}
+}
+
+func TestRunDrawEmailsEveryParticipantTheirFriend(t *testing.T) {
+	db := setupMigratedTestDB(t)
+	seedGroupWithParticipants(t, db, 1, 1, 1, 2, 3)
+	recorder := withRecordingMailer(t)
+
+	if _, err := db.Exec(`UPDATE Users SET user_name = 'User ' || user_id, user_email = 'user' || user_id || '@example.com'`); err != nil {
+		t.Fatalf("name users: %v", err)
+	}
+
+	if rr := runTestDraw(t, ""); rr.Code != http.StatusCreated {
+		t.Fatalf("run draw: status %d, body: %s", rr.Code, rr.Body.String())
+	}
+
+	if len(recorder.messages) != 3 {
+		t.Fatalf("expected 3 emails, got %d", len(recorder.messages))
+	}
+	for _, msg := range recorder.messages {
+		if !strings.Contains(msg.Text, "Your secret friend is User ") {
+			t.Fatalf("expected %s to learn their secret friend, got:\n%s", msg.To, msg.Text)
+		}
+	}
+}
+
+func TestSendReminderIsRestrictedToOrganizers(t *testing.T) {
+	db := setupMigratedTestDB(t)
+	seedGroupWithParticipants(t, db, 1, 1, 1, 2)
+	recorder := withRecordingMailer(t)
+
+	if rr := postGroupDrawAction(t, SendReminder, "/group/1/reminder", 2); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected status %d for a member, got %d", http.StatusForbidden, rr.Code)
+	}
+
+	rr := postGroupDrawAction(t, SendReminder, "/group/1/reminder", 1)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected status %d, got %d, body: %s", http.StatusAccepted, rr.Code, rr.Body.String())
+	}
+	if len(recorder.messages) != 2 || !strings.HasPrefix(recorder.messages[0].Subject, "A reminder from") {
+		t.Fatalf("expected a reminder for each participant, got %+v", recorder.messages)
+	}
+}
@@ -0,0 +1,241 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/akctba/secret-santa-go-api/database"
+	"github.com/akctba/secret-santa-go-api/models"
+	"github.com/akctba/secret-santa-go-api/oidc"
+	"github.com/gorilla/mux"
+)
+
+// oidcSigninLifetime is how long a user has to sign in at the provider.
+const oidcSigninLifetime = 10 * time.Minute
+
+// oidcProviders are the OpenID Connect providers users can sign in with, in
+// the order they are offered.
+var oidcProviders []*oidc.Provider
+
+// errOIDCEmailUnverified is returned for provider accounts that are not linked
+// yet and whose email address the provider has not verified.
+var errOIDCEmailUnverified = errors.New("provider has not verified the email address")
+
+type oidcProviderResponse struct {
+	Name string `json:"name"`
+}
+
+type oidcAuthorizationResponse struct {
+	AuthorizationURL string `json:"authorization_url"`
+}
+
+type oidcCallbackRequest struct {
+	Code  string `json:"code"`
+	State string `json:"state"`
+}
+
+// SetOIDCProviders sets the providers users can sign in with.
+func SetOIDCProviders(providers []*oidc.Provider) {
+	oidcProviders = providers
+}
+
+func findOIDCProvider(name string) *oidc.Provider {
+	for _, provider := range oidcProviders {
+		if provider.Name == name {
+			return provider
+		}
+	}
+	return nil
+}
+
+// ListOIDCProviders handles GET /user/oidc. Lists the providers users can sign in with.
+func ListOIDCProviders(w http.ResponseWriter, r *http.Request) {
+	providers := make([]oidcProviderResponse, 0, len(oidcProviders))
+	for _, provider := range oidcProviders {
+		providers = append(providers, oidcProviderResponse{Name: provider.Name})
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(providers)
+}
+
+// StartOIDCSignin handles POST /user/oidc/{provider}/authorize. Returns the provider page to
+// send the user to. The provider sends them back to its redirect URL with the code and state
+// to pass to CompleteOIDCSignin.
+func StartOIDCSignin(w http.ResponseWriter, r *http.Request) {
+	provider := findOIDCProvider(mux.Vars(r)["provider"])
+	if provider == nil {
+		http.Error(w, "Unknown sign-in provider", http.StatusNotFound)
+		return
+	}
+
+	request, err := oidc.NewAuthRequest()
+	if err != nil {
+		http.Error(w, "Failed to start sign-in", http.StatusInternalServerError)
+		return
+	}
+
+	authorizationURL, err := provider.AuthorizationURL(r.Context(), request)
+	if err != nil {
+		log.Printf("failed to reach sign-in provider %s: %v", provider.Name, err)
+		http.Error(w, "Sign-in provider is unavailable", http.StatusBadGateway)
+		return
+	}
+
+	db, err := getDB()
+	if err != nil {
+		log.Printf("failed to open db in StartOIDCSignin: %v", err)
+		http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
+		return
+	}
+	defer database.CloseDb(db)
+
+	now := time.Now().UTC()
+	err = database.InsertOIDCSignin(db, models.OIDCSignin{
+		StateHash:    hashToken(request.State),
+		Provider:     provider.Name,
+		Nonce:        request.Nonce,
+		CodeVerifier: request.CodeVerifier,
+		ExpiresAt:    now.Add(oidcSigninLifetime),
+	}, now)
+	if err != nil {
+		http.Error(w, "Failed to start sign-in", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(oidcAuthorizationResponse{AuthorizationURL: authorizationURL})
+}
+
+// CompleteOIDCSignin handles POST /user/oidc/{provider}/callback. Exchanges the code the
+// provider sent back for the same tokens Signin returns. Provider accounts are linked to the
+// user with the same email address, or to a new user, once the provider has verified it.
+func CompleteOIDCSignin(w http.ResponseWriter, r *http.Request) {
+	provider := findOIDCProvider(mux.Vars(r)["provider"])
+	if provider == nil {
+		http.Error(w, "Unknown sign-in provider", http.StatusNotFound)
+		return
+	}
+
+	var request oidcCallbackRequest
+	if err := decodeRequestJSON(r, &request); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if request.Code == "" || request.State == "" {
+		http.Error(w, "code and state are required", http.StatusBadRequest)
+		return
+	}
+
+	db, err := getDB()
+	if err != nil {
+		log.Printf("failed to open db in CompleteOIDCSignin: %v", err)
+		http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
+		return
+	}
+	defer database.CloseDb(db)
+
+	signin, err := database.ConsumeOIDCSignin(db, hashToken(request.State), provider.Name, time.Now().UTC())
+	if err != nil {
+		if errors.Is(err, database.ErrOIDCSigninInvalid) {
+			http.Error(w, "Invalid or expired sign-in", http.StatusBadRequest)
+			return
+		}
+
+		http.Error(w, "Failed to complete sign-in", http.StatusInternalServerError)
+		return
+	}
+
+	claims, err := provider.Exchange(r.Context(), request.Code, oidc.AuthRequest{
+		State:        request.State,
+		Nonce:        signin.Nonce,
+		CodeVerifier: signin.CodeVerifier,
+	})
+	if err != nil {
+		log.Printf("sign-in with %s failed: %v", provider.Name, err)
+		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := oidcUser(db, provider.Name, claims)
+	if err != nil {
+		if errors.Is(err, errOIDCEmailUnverified) {
+			http.Error(w, "The provider has not verified your email address", http.StatusForbidden)
+			return
+		}
+
+		log.Printf("failed to find the user signing in with %s: %v", provider.Name, err)
+		http.Error(w, "Failed to complete sign-in", http.StatusInternalServerError)
+		return
+	}
+
+	tokens, err := issueSigninTokens(db, user.UserID)
+	if err != nil {
+		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(tokens)
+}
+
+// oidcUser returns the user the provider account belongs to. Accounts signing
+// in for the first time are linked to the user with their email address, or
+// to a new user without a password, but only when the provider has verified
+// the address: otherwise anyone could claim someone else's account. Linking
+// to a user who never verified the address signs out whoever registered it,
+// see database.LinkUserIdentity.
+func oidcUser(db *sql.DB, providerName string, claims oidc.Claims) (models.User, error) {
+	user, err := database.GetUserByIdentity(db, providerName, claims.Subject)
+	if err == nil {
+		return user, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return models.User{}, err
+	}
+
+	email := normalizeEmail(claims.Email)
+	if !claims.EmailVerified || !isEmailAddress(email) {
+		return models.User{}, errOIDCEmailUnverified
+	}
+
+	identity := models.UserIdentity{Provider: providerName, Subject: claims.Subject}
+	now := time.Now().UTC()
+
+	user, err = database.GetUserByEmail(db, email)
+	if err == nil {
+		identity.UserID = user.UserID
+		if err := database.LinkUserIdentity(db, identity, now); err != nil {
+			return models.User{}, err
+		}
+		if user.EmailVerifiedAt == nil {
+			user.Password = ""
+			user.EmailVerifiedAt = &now
+			acceptEmailInvitations(db, user)
+		}
+		return user, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return models.User{}, err
+	}
+
+	name := strings.TrimSpace(claims.Name)
+	if name == "" {
+		name, _, _ = strings.Cut(email, "@")
+	}
+
+	// Without a password, the user signs in with the provider until they set
+	// one with a password reset.
+	user = models.User{UserName: name, UserEmail: email}
+	if err := database.InsertUserWithIdentity(db, &user, identity, now); err != nil {
+		return models.User{}, err
+	}
+	acceptEmailInvitations(db, user)
+	return user, nil
+}
@@ -0,0 +1,199 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/akctba/secret-santa-go-api/auth"
+	"github.com/akctba/secret-santa-go-api/database"
+	"github.com/akctba/secret-santa-go-api/oidc"
+	"github.com/akctba/secret-santa-go-api/oidc/oidctest"
+	"github.com/gorilla/mux"
+)
+
+func withTestOIDCProvider(t *testing.T) *oidctest.Issuer {
+	t.Helper()
+
+	issuer := oidctest.NewIssuer(t)
+	original := oidcProviders
+	SetOIDCProviders([]*oidc.Provider{{
+		Name:         "mock",
+		Issuer:       issuer.URL,
+		ClientID:     issuer.ClientID,
+		ClientSecret: issuer.ClientSecret,
+		RedirectURL:  "https://app.example.com/signin/mock",
+	}})
+	t.Cleanup(func() {
+		oidcProviders = original
+	})
+	return issuer
+}
+
+func postOIDCRequest(t *testing.T, handler http.HandlerFunc, provider string, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodPost, "/user/oidc/"+provider, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req = mux.SetURLVars(req, map[string]string{"provider": provider})
+
+	rr := httptest.NewRecorder()
+	handler(rr, req)
+	return rr
+}
+
+// signInWithTestOIDCProvider runs the whole flow and returns the callback response.
+func signInWithTestOIDCProvider(t *testing.T, issuer *oidctest.Issuer, user oidctest.User) *httptest.ResponseRecorder {
+	t.Helper()
+
+	rr := postOIDCRequest(t, StartOIDCSignin, "mock", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("start sign-in: status %d, body: %s", rr.Code, rr.Body.String())
+	}
+	authorizationURL := decodeJSONBody(t, rr.Body.String())["authorization_url"].(string)
+
+	code, state := issuer.SignIn(t, authorizationURL, user)
+	return postOIDCRequest(t, CompleteOIDCSignin, "mock", `{"code":"`+code+`","state":"`+state+`"}`)
+}
+
+func oidcSignedInUserID(t *testing.T, rr *httptest.ResponseRecorder) int {
+	t.Helper()
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("complete sign-in: status %d, body: %s", rr.Code, rr.Body.String())
+	}
+	payload := decodeJSONBody(t, rr.Body.String())
+	if _, ok := payload["refresh_token"].(string); !ok {
+		t.Fatalf("expected a refresh token, got %v", payload)
+	}
+	userID, err := auth.ValidateToken(payload["access_token"].(string))
+	if err != nil {
+		t.Fatalf("ValidateToken returned error: %v", err)
+	}
+	return userID
+}
+
+func TestOIDCSigninCreatesAVerifiedUser(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+	db := setupMigratedTestDB(t)
+	issuer := withTestOIDCProvider(t)
+
+	nora := oidctest.User{Subject: "nora-sub", Email: "Nora@Example.com", EmailVerified: true, Name: "Nora"}
+	userID := oidcSignedInUserID(t, signInWithTestOIDCProvider(t, issuer, nora))
+
+	user, err := database.GetUserByID(db, userID)
+	if err != nil {
+		t.Fatalf("GetUserByID returned error: %v", err)
+	}
+	if user.UserName != "Nora" || user.UserEmail != "nora@example.com" || user.EmailVerifiedAt == nil {
+		t.Fatalf("unexpected user %+v", user)
+	}
+	if rr := postUserRequest(t, Signin, `{"email":"nora@example.com","password":"anything"}`); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected password sign-in to fail without a password, got %d", rr.Code)
+	}
+
+	nora.Email = "nora@another.example.com"
+	if again := oidcSignedInUserID(t, signInWithTestOIDCProvider(t, issuer, nora)); again != userID {
+		t.Fatalf("expected the linked account to sign in as user %d, got %d", userID, again)
+	}
+}
+
+func TestOIDCSigninLinksAccountsByVerifiedEmail(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+	setupMigratedTestDB(t)
+	withRecordingMailer(t)
+	issuer := withTestOIDCProvider(t)
+
+	rr := createTestUser(t, `{"user_name":"Dan","email":"dan@example.com","password":"secret123"}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("create user: status %d, body: %s", rr.Code, rr.Body.String())
+	}
+	userID := int(decodeJSONBody(t, rr.Body.String())["user_id"].(float64))
+
+	unverified := oidctest.User{Subject: "mallory-sub", Email: "dan@example.com", EmailVerified: false}
+	if rr := signInWithTestOIDCProvider(t, issuer, unverified); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected status %d for an unverified email, got %d, body: %s", http.StatusForbidden, rr.Code, rr.Body.String())
+	}
+
+	dan := oidctest.User{Subject: "dan-sub", Email: "dan@example.com", EmailVerified: true, Name: "Daniel"}
+	if linked := oidcSignedInUserID(t, signInWithTestOIDCProvider(t, issuer, dan)); linked != userID {
+		t.Fatalf("expected the account to be linked to user %d, got %d", userID, linked)
+	}
+
+	payload := decodeJSONBody(t, serveAccountRequest(t, GetMe, http.MethodGet, userID, "").Body.String())
+	if payload["email_verified"] != true || payload["user_name"] != "Dan" {
+		t.Fatalf("expected the existing user, now verified, got %v", payload)
+	}
+}
+
+func TestOIDCSigninSignsOutWhoeverRegisteredAnUnverifiedAddress(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+	db := setupMigratedTestDB(t)
+	withRecordingMailer(t)
+	issuer := withTestOIDCProvider(t)
+
+	// Someone registers the address before its owner ever signs in.
+	rr := createTestUser(t, `{"user_name":"Mallory","email":"victim@example.com","password":"attacker-secret"}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("create user: status %d, body: %s", rr.Code, rr.Body.String())
+	}
+	userID := int(decodeJSONBody(t, rr.Body.String())["user_id"].(float64))
+	rr = postUserRequest(t, Signin, `{"email":"victim@example.com","password":"attacker-secret"}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("sign in: status %d, body: %s", rr.Code, rr.Body.String())
+	}
+	refreshToken := decodeJSONBody(t, rr.Body.String())["refresh_token"].(string)
+
+	victim := oidctest.User{Subject: "victim-sub", Email: "victim@example.com", EmailVerified: true}
+	if linked := oidcSignedInUserID(t, signInWithTestOIDCProvider(t, issuer, victim)); linked != userID {
+		t.Fatalf("expected the account to be linked to user %d, got %d", userID, linked)
+	}
+
+	if rr := postUserRequest(t, Signin, `{"email":"victim@example.com","password":"attacker-secret"}`); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected the pre-set password to stop working, got status %d", rr.Code)
+	}
+	if _, code := refreshTestToken(t, refreshToken); code != http.StatusUnauthorized {
+		t.Fatalf("expected earlier sessions to be revoked, got status %d", code)
+	}
+
+	// Verified accounts were registered by the address's owner and keep their password.
+	if rr := createTestUser(t, `{"user_name":"Erin","email":"erin@example.com","password":"erin-secret"}`); rr.Code != http.StatusCreated {
+		t.Fatalf("create user: status %d, body: %s", rr.Code, rr.Body.String())
+	}
+	if _, err := db.Exec(`UPDATE Users SET email_verified_at = ? WHERE user_email = 'erin@example.com'`, time.Now().UTC()); err != nil {
+		t.Fatalf("verify email: %v", err)
+	}
+	erin := oidctest.User{Subject: "erin-sub", Email: "erin@example.com", EmailVerified: true}
+	oidcSignedInUserID(t, signInWithTestOIDCProvider(t, issuer, erin))
+	if rr := postUserRequest(t, Signin, `{"email":"erin@example.com","password":"erin-secret"}`); rr.Code != http.StatusOK {
+		t.Fatalf("expected a verified account to keep its password, got status %d", rr.Code)
+	}
+}
+
+func TestOIDCSigninRejectsInvalidCallbacks(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+	setupMigratedTestDB(t)
+	issuer := withTestOIDCProvider(t)
+
+	if rr := postOIDCRequest(t, StartOIDCSignin, "unknown", ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected status %d for an unknown provider, got %d", http.StatusNotFound, rr.Code)
+	}
+
+	rr := postOIDCRequest(t, StartOIDCSignin, "mock", "")
+	authorizationURL := decodeJSONBody(t, rr.Body.String())["authorization_url"].(string)
+	code, state := issuer.SignIn(t, authorizationURL, oidctest.User{Subject: "sub", Email: "a@example.com", EmailVerified: true})
+
+	if rr := postOIDCRequest(t, CompleteOIDCSignin, "mock", `{"code":"`+code+`","state":"forged"}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d for an unknown state, got %d", http.StatusBadRequest, rr.Code)
+	}
+
+	body := `{"code":"` + code + `","state":"` + state + `"}`
+	if rr := postOIDCRequest(t, CompleteOIDCSignin, "mock", body); rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d, body: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if rr := postOIDCRequest(t, CompleteOIDCSignin, "mock", body); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d for a replayed callback, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
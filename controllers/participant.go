@@ -0,0 +1,155 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/akctba/secret-santa-go-api/database"
+	"github.com/akctba/secret-santa-go-api/models"
+	"github.com/gorilla/mux"
+)
+
+// participantResponse is what members see of each other. Email addresses are
+// only shared with organizers, who need them to manage the group.
+type participantResponse struct {
+	UserID        int       `json:"user_id"`
+	UserName      string    `json:"user_name"`
+	UserEmail     string    `json:"user_email,omitempty"`
+	Role          string    `json:"role"`
+	JoinedAt      time.Time `json:"joined_at"`
+	EmailVerified bool      `json:"email_verified"`
+}
+
+// GetParticipants handles GET /group/{id}/participant. Lists the group's participants for its members.
+func GetParticipants(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	groupID := vars["id"]
+
+	db, err := getDB()
+	if err != nil {
+		log.Printf("failed to open db in GetParticipants: %v", err)
+		http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
+		return
+	}
+	defer database.CloseDb(db)
+
+	group, ok := authorizeGroup(w, r, db, groupID, groupAccessMember)
+	if !ok {
+		return
+	}
+
+	participants, err := database.GetParticipantsByGroupID(db, group.GroupID)
+	if err != nil {
+		http.Error(w, "Failed to get participants", http.StatusInternalServerError)
+		return
+	}
+
+	userID, _ := authenticatedUserIDFromRequest(r)
+	showEmails := group.CreatorUserID == userID
+	for _, participant := range participants {
+		if participant.UserID == userID && roleAccess[participant.Role] >= groupAccessOrganizer {
+			showEmails = true
+		}
+	}
+
+	response := make([]participantResponse, 0, len(participants))
+	for _, participant := range participants {
+		item := participantResponse{
+			UserID:        participant.UserID,
+			UserName:      participant.UserName,
+			Role:          participant.Role,
+			JoinedAt:      participant.JoinedAt,
+			EmailVerified: participant.EmailVerified,
+		}
+		if showEmails {
+			item.UserEmail = participant.UserEmail
+		}
+		response = append(response, item)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// RemoveParticipant handles DELETE /group/{id}/participant/{userId}. Lets an organizer take
+// a participant out of the group.
+func RemoveParticipant(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	groupID := vars["id"]
+	userID, err := strconv.Atoi(vars["userId"])
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	db, err := getDB()
+	if err != nil {
+		log.Printf("failed to open db in RemoveParticipant: %v", err)
+		http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
+		return
+	}
+	defer database.CloseDb(db)
+
+	group, ok := authorizeGroup(w, r, db, groupID, groupAccessOrganizer)
+	if !ok {
+		return
+	}
+
+	removeParticipant(w, db, group, userID)
+}
+
+// LeaveGroup handles POST /group/{id}/leave. Lets a participant leave the group.
+func LeaveGroup(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	groupID := vars["id"]
+
+	db, err := getDB()
+	if err != nil {
+		log.Printf("failed to open db in LeaveGroup: %v", err)
+		http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
+		return
+	}
+	defer database.CloseDb(db)
+
+	group, ok := authorizeGroup(w, r, db, groupID, groupAccessMember)
+	if !ok {
+		return
+	}
+
+	userID, _ := authenticatedUserIDFromRequest(r)
+	removeParticipant(w, db, group, userID)
+}
+
+// removeParticipant takes the user out of the group. In a drawn group the
+// person who drew them gets their secret friend instead and is told by email;
+// when that is not possible the draw has to be reset first.
+func removeParticipant(w http.ResponseWriter, db *sql.DB, group models.Group, userID int) {
+	if userID == group.CreatorUserID {
+		http.Error(w, "Transfer ownership before the owner leaves the group", http.StatusConflict)
+		return
+	}
+
+	relinked, err := database.RemoveParticipant(db, group.GroupID, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			http.Error(w, "Participant not found", http.StatusNotFound)
+		case errors.Is(err, database.ErrDrawCannotRelink):
+			http.Error(w, "The draw cannot be kept without this participant, reset the draw first", http.StatusConflict)
+		default:
+			http.Error(w, "Failed to remove participant", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if relinked.UserID != 0 {
+		notifyReassigned(db, group, relinked)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,144 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func serveParticipantRequest(t *testing.T, handler http.HandlerFunc, method string, vars map[string]string, userID int) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(method, "/group/1/participant", nil)
+	req = mux.SetURLVars(req, vars)
+	req = withAuthenticatedUser(req, userID)
+
+	rr := httptest.NewRecorder()
+	handler(rr, req)
+	return rr
+}
+
+func TestGetParticipantsSharesEmailsWithOrganizersOnly(t *testing.T) {
+	db := setupMigratedTestDB(t)
+	seedNamedGroup(t, db)
+
+	tests := []struct {
+		name       string
+		userID     int
+		wantEmails bool
+	}{
+		{name: "owner", userID: 1, wantEmails: true},
+		{name: "member", userID: 2, wantEmails: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := serveParticipantRequest(t, GetParticipants, http.MethodGet, map[string]string{"id": "1"}, tt.userID)
+			if rr.Code != http.StatusOK {
+				t.Fatalf("expected status %d, got %d, body: %s", http.StatusOK, rr.Code, rr.Body.String())
+			}
+
+			var participants []participantResponse
+			if err := json.Unmarshal(rr.Body.Bytes(), &participants); err != nil {
+				t.Fatalf("decode participants: %v, body: %s", err, rr.Body.String())
+			}
+			if len(participants) != 3 || participants[1].UserName != "Bob" {
+				t.Fatalf("expected Alice, Bob and Carol, got: %s", rr.Body.String())
+			}
+			if got := strings.Contains(rr.Body.String(), "bob@example.com"); got != tt.wantEmails {
+				t.Fatalf("expected emails shared to be %t, got: %s", tt.wantEmails, rr.Body.String())
+			}
+		})
+	}
+
+	if rr := serveParticipantRequest(t, GetParticipants, http.MethodGet, map[string]string{"id": "1"}, 9); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected status %d for an outsider, got %d", http.StatusForbidden, rr.Code)
+	}
+}
+
+func TestLeaveGroupRelinksTheDraw(t *testing.T) {
+	db := setupMigratedTestDB(t)
+	seedNamedGroup(t, db)
+	recorder := withRecordingMailer(t)
+
+	if rr := runTestDraw(t, ""); rr.Code != http.StatusCreated {
+		t.Fatalf("run draw: status %d, body: %s", rr.Code, rr.Body.String())
+	}
+	recorder.messages = nil
+
+	leaving := 2
+	friend := testFriendOf(t, db, leaving)
+	giver := 6 - leaving - friend
+
+	rr := serveParticipantRequest(t, LeaveGroup, http.MethodPost, map[string]string{"id": "1"}, leaving)
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d, got %d, body: %s", http.StatusNoContent, rr.Code, rr.Body.String())
+	}
+
+	if got := testFriendOf(t, db, giver); got != friend {
+		t.Fatalf("expected user %d to draw user %d after the leave, got %d", giver, friend, got)
+	}
+	if len(recorder.messages) != 1 || !strings.Contains(recorder.messages[0].Text, "Your secret friend is now") {
+		t.Fatalf("expected the giver to be told about their new friend, got %+v", recorder.messages)
+	}
+
+	if rr := serveParticipantRequest(t, LeaveGroup, http.MethodPost, map[string]string{"id": "1"}, leaving); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected status %d once left, got %d", http.StatusForbidden, rr.Code)
+	}
+}
+
+func TestLeaveGroupBlocksWhenTheDrawCannotBeKept(t *testing.T) {
+	db := setupMigratedTestDB(t)
+	seedGroupWithParticipants(t, db, 1, 1, 1, 2)
+
+	if rr := runTestDraw(t, ""); rr.Code != http.StatusCreated {
+		t.Fatalf("run draw: status %d, body: %s", rr.Code, rr.Body.String())
+	}
+
+	rr := serveParticipantRequest(t, LeaveGroup, http.MethodPost, map[string]string{"id": "1"}, 2)
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("expected status %d, got %d, body: %s", http.StatusConflict, rr.Code, rr.Body.String())
+	}
+	if got := testFriendOf(t, db, 2); got != 1 {
+		t.Fatalf("expected the draw to stay untouched, user 2 draws %d", got)
+	}
+
+	if rr := postGroupDrawAction(t, ResetDraw, "/group/1/draw/reset", 1); rr.Code != http.StatusNoContent {
+		t.Fatalf("reset draw: status %d, body: %s", rr.Code, rr.Body.String())
+	}
+	if rr := serveParticipantRequest(t, LeaveGroup, http.MethodPost, map[string]string{"id": "1"}, 2); rr.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d after the reset, got %d, body: %s", http.StatusNoContent, rr.Code, rr.Body.String())
+	}
+}
+
+func TestRemoveParticipantIsRestrictedToOrganizers(t *testing.T) {
+	db := setupMigratedTestDB(t)
+	seedGroupWithParticipants(t, db, 1, 1, 1, 2, 3)
+
+	tests := []struct {
+		name     string
+		userID   int
+		remove   int
+		wantCode int
+	}{
+		{name: "member", userID: 2, remove: 3, wantCode: http.StatusForbidden},
+		{name: "owner", userID: 1, remove: 1, wantCode: http.StatusConflict},
+		{name: "organizer", userID: 1, remove: 3, wantCode: http.StatusNoContent},
+		{name: "already removed", userID: 1, remove: 3, wantCode: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vars := map[string]string{"id": "1", "userId": strconv.Itoa(tt.remove)}
+			rr := serveParticipantRequest(t, RemoveParticipant, http.MethodDelete, vars, tt.userID)
+			if rr.Code != tt.wantCode {
+				t.Fatalf("expected status %d, got %d, body: %s", tt.wantCode, rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/akctba/secret-santa-go-api/database"
+	"github.com/akctba/secret-santa-go-api/notify"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// passwordResetTokenLifetime is how long a reset email stays usable.
+const passwordResetTokenLifetime = time.Hour
+
+// passwordResetURL is the page of the web app where users choose a new
+// password. The token is added to it as the token query parameter.
+var passwordResetURL string
+
+type forgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+type resetPasswordRequest struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
+
+// SetPasswordResetURL sets the page linked from password reset emails. Without
+// one, the email contains the token for the user to paste instead.
+func SetPasswordResetURL(rawURL string) error {
+	if rawURL != "" && !isWebLink(rawURL) {
+		return fmt.Errorf("%q is not an http or https link", rawURL)
+	}
+	passwordResetURL = rawURL
+	return nil
+}
+
+// ForgotPassword handles POST /user/password/forgot. Emails a single-use reset link to the
+// address if it belongs to a user. It always answers 202, so it cannot be used to find out
+// which addresses are registered.
+func ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var request forgotPasswordRequest
+	if err := decodeRequestJSON(r, &request); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	email := normalizeEmail(request.Email)
+	if email == "" {
+		http.Error(w, "email is required", http.StatusBadRequest)
+		return
+	}
+
+	db, err := getDB()
+	if err != nil {
+		log.Printf("failed to open db in ForgotPassword: %v", err)
+		http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
+		return
+	}
+	defer database.CloseDb(db)
+
+	user, err := database.GetUserByEmail(db, email)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("failed to load user for password reset: %v", err)
+		}
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	token, err := newEmailToken()
+	if err != nil {
+		log.Printf("failed to generate password reset token: %v", err)
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	expiresAt := time.Now().UTC().Add(passwordResetTokenLifetime)
+	err = database.InsertPasswordResetToken(db, user.UserID, hashToken(token), expiresAt)
+	if err != nil {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	sendNotification(notify.KindPasswordReset, user.UserEmail, notify.Data{
+		UserName:  user.UserName,
+		Token:     token,
+		TokenURL:  emailTokenLink(passwordResetURL, token),
+		ExpiresAt: expiresAt,
+	})
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// ResetPassword handles POST /user/password/reset. Sets a new password with a token from a
+// reset email and signs the user out everywhere by revoking their refresh tokens.
+func ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var request resetPasswordRequest
+	if err := decodeRequestJSON(r, &request); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if request.Token == "" || request.NewPassword == "" {
+		http.Error(w, "token and new_password are required", http.StatusBadRequest)
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(request.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		http.Error(w, "Failed to hash password", http.StatusInternalServerError)
+		return
+	}
+
+	db, err := getDB()
+	if err != nil {
+		log.Printf("failed to open db in ResetPassword: %v", err)
+		http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
+		return
+	}
+	defer database.CloseDb(db)
+
+	_, err = database.ResetPassword(db, hashToken(request.Token), string(hashedPassword), time.Now().UTC())
+	if err != nil {
+		if errors.Is(err, database.ErrResetTokenInvalid) {
+			http.Error(w, "Invalid or expired reset token", http.StatusBadRequest)
+			return
+		}
+
+		http.Error(w, "Failed to reset password", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
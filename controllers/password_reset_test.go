@@ -0,0 +1,125 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/akctba/secret-santa-go-api/database"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func postUserRequest(t *testing.T, handler http.HandlerFunc, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodPost, "/user", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	rr := httptest.NewRecorder()
+	handler(rr, req)
+	return rr
+}
+
+func withEmailToken(t *testing.T, token string) {
+	t.Helper()
+
+	originalToken := newEmailToken
+	originalResetURL := passwordResetURL
+	originalVerificationURL := emailVerificationURL
+	originalMagicLinkURL := magicLinkURL
+	newEmailToken = func() (string, error) {
+		return token, nil
+	}
+
+	t.Cleanup(func() {
+		newEmailToken = originalToken
+		passwordResetURL = originalResetURL
+		emailVerificationURL = originalVerificationURL
+		magicLinkURL = originalMagicLinkURL
+	})
+}
+
+func TestForgotPasswordAlwaysAccepts(t *testing.T) {
+	db := setupMigratedTestDB(t)
+	seedGroupWithParticipants(t, db, 1, 1, 1)
+	recorder := withRecordingMailer(t)
+	withEmailToken(t, "reset-token")
+	if err := SetPasswordResetURL("https://app.example.com/reset"); err != nil {
+		t.Fatalf("SetPasswordResetURL returned error: %v", err)
+	}
+
+	if rr := postUserRequest(t, ForgotPassword, `{"email":"nobody@example.com"}`); rr.Code != http.StatusAccepted {
+		t.Fatalf("expected status %d for an unknown email, got %d", http.StatusAccepted, rr.Code)
+	}
+	if len(recorder.messages) != 0 {
+		t.Fatalf("expected no email for an unknown address, got %+v", recorder.messages)
+	}
+
+	if rr := postUserRequest(t, ForgotPassword, `{"email":"User1@example.com"}`); rr.Code != http.StatusAccepted {
+		t.Fatalf("expected status %d, got %d", http.StatusAccepted, rr.Code)
+	}
+	if len(recorder.messages) != 1 || !strings.Contains(recorder.messages[0].Text, "https://app.example.com/reset?token=reset-token") {
+		t.Fatalf("expected a reset link to be emailed, got %+v", recorder.messages)
+	}
+
+	var stored string
+	if err := db.QueryRow(`SELECT token_hash FROM PasswordResetTokens WHERE user_id = 1`).Scan(&stored); err != nil {
+		t.Fatalf("load reset token: %v", err)
+	}
+	if stored == "reset-token" || stored != hashToken("reset-token") {
+		t.Fatalf("expected only the token's hash to be stored, got %q", stored)
+	}
+}
+
+func TestResetPasswordUsesTheTokenOnce(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+	db := setupMigratedTestDB(t)
+	seedGroupWithParticipants(t, db, 1, 1, 1)
+	withRecordingMailer(t)
+	withEmailToken(t, "reset-token")
+
+	refreshToken, err := issueRefreshToken(db, 1)
+	if err != nil {
+		t.Fatalf("issueRefreshToken returned error: %v", err)
+	}
+
+	if rr := postUserRequest(t, ForgotPassword, `{"email":"user1@example.com"}`); rr.Code != http.StatusAccepted {
+		t.Fatalf("expected status %d, got %d", http.StatusAccepted, rr.Code)
+	}
+
+	body := `{"token":"reset-token","new_password":"new-secret"}`
+	if rr := postUserRequest(t, ResetPassword, body); rr.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d, got %d, body: %s", http.StatusNoContent, rr.Code, rr.Body.String())
+	}
+	if rr := postUserRequest(t, ResetPassword, body); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d for a used token, got %d", http.StatusBadRequest, rr.Code)
+	}
+
+	user, err := database.GetUserByID(db, 1)
+	if err != nil {
+		t.Fatalf("load user: %v", err)
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte("new-secret")) != nil {
+		t.Fatal("expected the new password to be saved")
+	}
+	if rr := postUserRequest(t, RefreshToken, `{"refresh_token":"`+refreshToken+`"}`); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected refresh tokens to be revoked, got status %d", rr.Code)
+	}
+}
+
+func TestResetPasswordRejectsExpiredTokens(t *testing.T) {
+	db := setupMigratedTestDB(t)
+	seedGroupWithParticipants(t, db, 1, 1, 1)
+
+	err := database.InsertPasswordResetToken(db, 1, hashToken("old-token"), time.Now().Add(-time.Minute))
+	if err != nil {
+		t.Fatalf("InsertPasswordResetToken returned error: %v", err)
+	}
+
+	rr := postUserRequest(t, ResetPassword, `{"token":"old-token","new_password":"new-secret"}`)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d, got %d, body: %s", http.StatusBadRequest, rr.Code, rr.Body.String())
+	}
+}
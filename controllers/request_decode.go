@@ -5,6 +5,7 @@ import (
 	"errors"
 	"io"
 	"net/http"
+	"time"
 )
 
 // decodeRequestJSON decodes a single JSON object and rejects unknown fields.
@@ -21,4 +22,26 @@ func decodeRequestJSON(r *http.Request, dst any) error {
 	}
 
 	return nil
-}
\ No newline at end of file
+}
+
+// optionalTime tells an absent field apart from an explicit null, so PATCH
+// requests can clear a time.
+type optionalTime struct {
+	Set   bool
+	Value *time.Time
+}
+
+func (o *optionalTime) UnmarshalJSON(data []byte) error {
+	o.Set = true
+	if string(data) == "null" {
+		o.Value = nil
+		return nil
+	}
+
+	var value time.Time
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	o.Value = &value
+	return nil
+}
@@ -0,0 +1,26 @@
+package controllers
+
+import (
+	"net/mail"
+	"net/url"
+	"strings"
+)
+
+// isWebLink reports whether raw is an absolute http or https URL.
+func isWebLink(raw string) bool {
+	link, err := url.Parse(raw)
+	return err == nil && (link.Scheme == "http" || link.Scheme == "https") && link.Host != ""
+}
+
+// normalizeEmail lowercases the address so it matches however the user types
+// it, whether registering, signing in or being invited.
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// isEmailAddress reports whether email is a bare address such as
+// alice@example.com, without a display name or angle brackets.
+func isEmailAddress(email string) bool {
+	address, err := mail.ParseAddress(email)
+	return err == nil && address.Address == email
+}
@@ -0,0 +1,117 @@
+package controllers
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/akctba/secret-santa-go-api/auth"
+	"github.com/akctba/secret-santa-go-api/database"
+	"github.com/akctba/secret-santa-go-api/models"
+)
+
+// issueRefreshToken signs and stores a refresh token that starts a new session for the user.
+func issueRefreshToken(db *sql.DB, userID int) (string, error) {
+	token, claims, err := auth.IssueRefreshToken(userID, "")
+	if err != nil {
+		return "", err
+	}
+
+	if err := database.InsertRefreshToken(db, toStoredRefreshToken(claims)); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// issueSigninTokens returns the access token and new refresh token a user
+// gets when they sign in, however they proved who they are.
+func issueSigninTokens(db *sql.DB, userID int) (signinResponse, error) {
+	accessToken, err := auth.CreateAccessToken(userID)
+	if err != nil {
+		return signinResponse{}, err
+	}
+
+	refreshToken, err := issueRefreshToken(db, userID)
+	if err != nil {
+		return signinResponse{}, err
+	}
+
+	return signinResponse{AccessToken: accessToken, RefreshToken: refreshToken}, nil
+}
+
+func toStoredRefreshToken(claims auth.RefreshTokenClaims) models.RefreshToken {
+	return models.RefreshToken{
+		TokenID:   claims.TokenID,
+		UserID:    claims.UserID,
+		FamilyID:  claims.FamilyID,
+		IssuedAt:  claims.IssuedAt,
+		ExpiresAt: claims.ExpiresAt,
+	}
+}
+
+// Logout handles POST /user/logout. Revokes the refresh token and every token rotated from
+// the same sign-in. Access tokens already issued stay valid until they expire.
+func Logout(w http.ResponseWriter, r *http.Request) {
+	var request refreshTokenRequest
+	if err := decodeRequestJSON(r, &request); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	refreshToken := strings.TrimSpace(request.RefreshToken)
+	if refreshToken == "" {
+		http.Error(w, "refresh_token is required", http.StatusBadRequest)
+		return
+	}
+
+	claims, err := auth.ParseRefreshToken(refreshToken)
+	if err != nil {
+		log.Printf("rejected refresh token in Logout: %v", err)
+		http.Error(w, "Invalid token", http.StatusUnauthorized)
+		return
+	}
+
+	db, err := getDB()
+	if err != nil {
+		log.Printf("failed to open db in Logout: %v", err)
+		http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
+		return
+	}
+	defer database.CloseDb(db)
+
+	err = database.RevokeRefreshTokenFamily(db, claims.FamilyID, time.Now().UTC())
+	if err != nil {
+		http.Error(w, "Failed to log out", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// LogoutAll handles POST /user/logout-all. Revokes every refresh token of the authenticated
+// user, signing them out on all their devices once their access tokens expire.
+func LogoutAll(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authenticatedUserIDFromRequest(r)
+	if !ok {
+		http.Error(w, "Invalid token", http.StatusUnauthorized)
+		return
+	}
+
+	db, err := getDB()
+	if err != nil {
+		log.Printf("failed to open db in LogoutAll: %v", err)
+		http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
+		return
+	}
+	defer database.CloseDb(db)
+
+	err = database.RevokeUserRefreshTokens(db, userID, time.Now().UTC())
+	if err != nil {
+		http.Error(w, "Failed to log out", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,83 @@
+package controllers
+
+import (
+	"net/http"
+	"testing"
+)
+
+func refreshTestToken(t *testing.T, refreshToken string) (string, int) {
+	t.Helper()
+
+	rr := postUserRequest(t, RefreshToken, `{"refresh_token":"`+refreshToken+`"}`)
+	if rr.Code != http.StatusOK {
+		return "", rr.Code
+	}
+	return decodeJSONBody(t, rr.Body.String())["refresh_token"].(string), rr.Code
+}
+
+func TestRefreshTokenReuseSignsOutTheSession(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+	db := setupMigratedTestDB(t)
+	seedGroupWithParticipants(t, db, 1, 1, 1)
+
+	first, err := issueRefreshToken(db, 1)
+	if err != nil {
+		t.Fatalf("issueRefreshToken returned error: %v", err)
+	}
+	other, err := issueRefreshToken(db, 1)
+	if err != nil {
+		t.Fatalf("issueRefreshToken returned error: %v", err)
+	}
+
+	second, code := refreshTestToken(t, first)
+	if code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, code)
+	}
+	if _, code := refreshTestToken(t, first); code != http.StatusUnauthorized {
+		t.Fatalf("expected status %d for a reused token, got %d", http.StatusUnauthorized, code)
+	}
+	if _, code := refreshTestToken(t, second); code != http.StatusUnauthorized {
+		t.Fatalf("expected the reuse to revoke the session, got status %d", code)
+	}
+	if _, code := refreshTestToken(t, other); code != http.StatusOK {
+		t.Fatalf("expected other sessions to keep working, got status %d", code)
+	}
+}
+
+func TestLogoutRevokesRefreshTokens(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+	db := setupMigratedTestDB(t)
+	seedGroupWithParticipants(t, db, 1, 1, 1)
+
+	tokens := make([]string, 3)
+	for i := range tokens {
+		token, err := issueRefreshToken(db, 1)
+		if err != nil {
+			t.Fatalf("issueRefreshToken returned error: %v", err)
+		}
+		tokens[i] = token
+	}
+
+	rotated, code := refreshTestToken(t, tokens[0])
+	if code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, code)
+	}
+	if rr := postUserRequest(t, Logout, `{"refresh_token":"`+tokens[0]+`"}`); rr.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d, got %d, body: %s", http.StatusNoContent, rr.Code, rr.Body.String())
+	}
+	if _, code := refreshTestToken(t, rotated); code != http.StatusUnauthorized {
+		t.Fatalf("expected the logged out session to be revoked, got status %d", code)
+	}
+	if rr := postUserRequest(t, Logout, `{"refresh_token":"not-a-token"}`); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected status %d for an invalid token, got %d", http.StatusUnauthorized, rr.Code)
+	}
+
+	if rr := serveAccountRequest(t, LogoutAll, http.MethodPost, 1, ""); rr.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d, got %d, body: %s", http.StatusNoContent, rr.Code, rr.Body.String())
+	}
+	for _, token := range tokens[1:] {
+		if _, code := refreshTestToken(t, token); code != http.StatusUnauthorized {
+			t.Fatalf("expected every session to be revoked, got status %d", code)
+		}
+	}
+}
@@ -1,7 +1,10 @@
 package controllers
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"strconv"
@@ -15,6 +18,17 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+// dummyPasswordHash is compared against when signing in with an unknown email.
+// It is generated when the package loads, at the same cost as real password
+// hashes, so that no sign-in pays for generating it.
+var dummyPasswordHash = func() []byte {
+	hash, err := bcrypt.GenerateFromPassword([]byte("not a real password"), bcrypt.DefaultCost)
+	if err != nil {
+		panic(fmt.Errorf("bcrypt: failed to generate dummy password hash: %w", err))
+	}
+	return hash
+}()
+
 type createUserRequest struct {
 	UserName string `json:"user_name"`
 	Email    string `json:"email"`
@@ -31,24 +45,27 @@ type signinResponse struct {
 }
 
 type refreshTokenResponse struct {
-	AccessToken string `json:"access_token"`
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
 }
 
 type userResponse struct {
-	UserID      int       `json:"user_id"`
-	UserName    string    `json:"user_name"`
-	UserEmail   string    `json:"user_email"`
-	Gender      string    `json:"gender"`
-	DateOfBirth time.Time `json:"date_of_birth"`
+	UserID        int       `json:"user_id"`
+	UserName      string    `json:"user_name"`
+	UserEmail     string    `json:"user_email"`
+	Gender        string    `json:"gender"`
+	DateOfBirth   time.Time `json:"date_of_birth"`
+	EmailVerified bool      `json:"email_verified"`
 }
 
 func toUserResponse(user models.User) userResponse {
 	resp := userResponse{
-		UserID:      user.UserID,
-		UserName:    user.UserName,
-		UserEmail:   user.UserEmail,
-		Gender:      user.Gender,
-		DateOfBirth: user.DateOfBirth,
+		UserID:        user.UserID,
+		UserName:      user.UserName,
+		UserEmail:     user.UserEmail,
+		Gender:        user.Gender,
+		DateOfBirth:   user.DateOfBirth,
+		EmailVerified: user.EmailVerifiedAt != nil,
 	}
 
 	return resp
@@ -62,7 +79,7 @@ func Signin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	email := strings.TrimSpace(request.UserEmail)
+	email := normalizeEmail(request.UserEmail)
 	password := strings.TrimSpace(request.Password)
 	if email == "" || password == "" {
 		http.Error(w, "email and password are required", http.StatusBadRequest)
@@ -82,7 +99,15 @@ func Signin(w http.ResponseWriter, r *http.Request) {
 
 	user, err := database.GetUserByEmail(db, request.UserEmail)
 	if err != nil {
-		http.Error(w, "Failed to get user", http.StatusInternalServerError)
+		if !errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Failed to get user", http.StatusInternalServerError)
+			return
+		}
+
+		// Spend as long as a real password check, so response times do not
+		// tell which addresses are registered.
+		bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(request.Password))
+		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
 		return
 	}
 
@@ -92,26 +117,19 @@ func Signin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	accessToken, err := auth.CreateAccessToken(user.UserID)
-	if err != nil {
-		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
-		return
-	}
-
-	refreshToken, err := auth.CreateRefreshToken(user.UserID)
+	tokens, err := issueSigninTokens(db, user.UserID)
 	if err != nil {
 		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(signinResponse{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-	})
+	json.NewEncoder(w).Encode(tokens)
 }
 
-// RefreshToken handles POST /user/refresh. Validates a refresh token and returns a new access token.
+// RefreshToken handles POST /user/refresh. Exchanges a refresh token for a new access token and
+// a new refresh token. Each refresh token can only be used once; using one again signs out the
+// session it belongs to.
 func RefreshToken(w http.ResponseWriter, r *http.Request) {
 	var request refreshTokenRequest
 	if err := decodeRequestJSON(r, &request); err != nil {
@@ -125,23 +143,54 @@ func RefreshToken(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userID, err := auth.ValidateRefreshToken(refreshToken)
+	claims, err := auth.ParseRefreshToken(refreshToken)
 	if err != nil {
+		log.Printf("rejected refresh token in RefreshToken: %v", err)
 		http.Error(w, "Invalid token", http.StatusUnauthorized)
 		return
 	}
 
-	accessToken, err := auth.CreateAccessToken(userID)
+	db, err := getDB()
+	if err != nil {
+		log.Printf("failed to open db in RefreshToken: %v", err)
+		http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
+		return
+	}
+	defer database.CloseDb(db)
+
+	nextToken, next, err := auth.IssueRefreshToken(claims.UserID, claims.FamilyID)
+	if err != nil {
+		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		return
+	}
+
+	err = database.RotateRefreshToken(db, claims.TokenID, toStoredRefreshToken(next), time.Now().UTC())
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRefreshTokenReused):
+			log.Printf("refresh token reused for user %d, signed out token family %s", claims.UserID, claims.FamilyID)
+			http.Error(w, "Invalid token", http.StatusUnauthorized)
+		case errors.Is(err, database.ErrRefreshTokenInvalid):
+			http.Error(w, "Invalid token", http.StatusUnauthorized)
+		default:
+			http.Error(w, "Failed to refresh token", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	accessToken, err := auth.CreateAccessToken(claims.UserID)
 	if err != nil {
 		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(refreshTokenResponse{AccessToken: accessToken})
+	json.NewEncoder(w).Encode(refreshTokenResponse{AccessToken: accessToken, RefreshToken: nextToken})
 }
 
-// CreateUser handles POST /user. Hashes the password and persists the new user.
+// CreateUser handles POST /user. Hashes the password, persists the new user and emails them a
+// link to verify their address. Groups they were invited to by email are only joined once the
+// address is verified, since anyone can register an address they do not own.
 func CreateUser(w http.ResponseWriter, r *http.Request) {
 	var request createUserRequest
 	if err := decodeRequestJSON(r, &request); err != nil {
@@ -150,7 +199,7 @@ func CreateUser(w http.ResponseWriter, r *http.Request) {
 	}
 
 	name := strings.TrimSpace(request.UserName)
-	email := strings.TrimSpace(request.Email)
+	email := normalizeEmail(request.Email)
 
 	password := request.Password
 
@@ -158,6 +207,10 @@ func CreateUser(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "user_name, email and password are required", http.StatusBadRequest)
 		return
 	}
+	if !isEmailAddress(email) {
+		http.Error(w, "A valid email is required", http.StatusBadRequest)
+		return
+	}
 
 	user := models.User{
 		UserName:  name,
@@ -180,12 +233,23 @@ func CreateUser(w http.ResponseWriter, r *http.Request) {
 	}
 	defer database.CloseDb(db)
 
-	err = database.InsertUser(db, user)
+	err = database.InsertUser(db, &user)
 	if err != nil {
+		if errors.Is(err, database.ErrEmailTaken) {
+			http.Error(w, "Email is already registered", http.StatusConflict)
+			return
+		}
+
 		http.Error(w, "Failed to create user", http.StatusInternalServerError)
 		return
 	}
 
+	// The account exists either way; a user whose email did not arrive can ask
+	// for another one.
+	if err := sendEmailVerification(db, user); err != nil {
+		log.Printf("failed to send email verification to user %d: %v", user.UserID, err)
+	}
+
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(toUserResponse(user))
 }
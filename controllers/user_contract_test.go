@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"net/http"
@@ -29,7 +30,8 @@ func setupUserContractTestDB(t *testing.T) *sql.DB {
 		user_email TEXT,
 		password TEXT,
 		gender TEXT,
-		date_of_birth TEXT
+		date_of_birth TEXT,
+		email_verified_at DATETIME
 	);`
 
 	if _, err := db.Exec(createUsersTable); err != nil {
@@ -57,6 +59,12 @@ func withTestDB(t *testing.T, db *sql.DB) {
 	})
 }
 
+// withAuthenticatedUser mimics BearerAuth for handlers invoked directly.
+func withAuthenticatedUser(req *http.Request, userID int) *http.Request {
+	ctx := context.WithValue(req.Context(), authenticatedUserIDKey, userID)
+	return req.WithContext(ctx)
+}
+
 func decodeJSONBody(t *testing.T, body string) map[string]any {
 	t.Helper()
 
@@ -114,8 +122,7 @@ func TestGetUserResponseOmitsPassword(t *testing.T) {
 func TestSigninResponseReturnsAccessAndRefreshTokens(t *testing.T) {
 	t.Setenv("JWT_SECRET", "test-secret")
 
-	db := setupUserContractTestDB(t)
-	withTestDB(t, db)
+	db := setupMigratedTestDB(t)
 
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte("secret123"), bcrypt.DefaultCost)
 	if err != nil {
@@ -158,10 +165,14 @@ func TestSigninResponseReturnsAccessAndRefreshTokens(t *testing.T) {
 
 func TestRefreshTokenReturnsNewAccessToken(t *testing.T) {
 	t.Setenv("JWT_SECRET", "test-secret")
+	db := setupMigratedTestDB(t)
+	if _, err := db.Exec(`INSERT INTO Users (user_id, user_name, user_email, password) VALUES (42, 'Alice', 'alice@example.com', 'secret')`); err != nil {
+		t.Fatalf("insert test user: %v", err)
+	}
 
-	refreshToken, err := auth.CreateRefreshToken(42)
+	refreshToken, err := issueRefreshToken(db, 42)
 	if err != nil {
-		t.Fatalf("CreateRefreshToken returned error: %v", err)
+		t.Fatalf("issueRefreshToken returned error: %v", err)
 	}
 
 	req := httptest.NewRequest(http.MethodPost, "/user/refresh", strings.NewReader(`{"refresh_token":"`+refreshToken+`"}`))
@@ -188,6 +199,11 @@ func TestRefreshTokenReturnsNewAccessToken(t *testing.T) {
 	if userID != 42 {
 		t.Fatalf("ValidateToken returned %d, want 42", userID)
 	}
+
+	rotated, ok := payload["refresh_token"].(string)
+	if !ok || rotated == "" || rotated == refreshToken {
+		t.Fatalf("expected a new refresh_token in response, got: %s", rr.Body.String())
+	}
 }
 
 func TestRefreshTokenRejectsAccessToken(t *testing.T) {
@@ -208,3 +224,63 @@ func TestRefreshTokenRejectsAccessToken(t *testing.T) {
 		t.Fatalf("expected status %d, got %d, body: %s", http.StatusUnauthorized, rr.Code, rr.Body.String())
 	}
 }
+
+func TestCreateUserRejectsRegisteredEmail(t *testing.T) {
+	setupMigratedTestDB(t)
+
+	for _, tt := range []struct {
+		email    string
+		wantCode int
+	}{
+		{email: "Alice@Example.com", wantCode: http.StatusCreated},
+		{email: " alice@example.COM ", wantCode: http.StatusConflict},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/user", strings.NewReader(`{"user_name":"Alice","email":"`+tt.email+`","password":"secret123"}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
+		CreateUser(rr, req)
+
+		if rr.Code != tt.wantCode {
+			t.Fatalf("%q: expected status %d, got %d, body: %s", tt.email, tt.wantCode, rr.Code, rr.Body.String())
+		}
+		if rr.Code == http.StatusCreated && decodeJSONBody(t, rr.Body.String())["user_email"] != "alice@example.com" {
+			t.Fatalf("expected the email to be lowercased, got: %s", rr.Body.String())
+		}
+	}
+}
+
+func TestSigninFailsAlikeForUnknownEmailAndWrongPassword(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+	setupMigratedTestDB(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/user", strings.NewReader(`{"user_name":"Alice","email":"alice@example.com","password":"secret123"}`))
+	req.Header.Set("Content-Type", "application/json")
+	CreateUser(httptest.NewRecorder(), req)
+
+	signin := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/user/signin", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		Signin(rr, req)
+		return rr
+	}
+
+	if rr := signin(`{"email":"ALICE@example.com","password":"secret123"}`); rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d, body: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	wrongPassword := signin(`{"email":"alice@example.com","password":"wrong"}`)
+	unknownEmail := signin(`{"email":"mallory@example.com","password":"secret123"}`)
+	if wrongPassword.Code != http.StatusUnauthorized || unknownEmail.Code != http.StatusUnauthorized {
+		t.Fatalf("expected status %d for both, got %d and %d", http.StatusUnauthorized, wrongPassword.Code, unknownEmail.Code)
+	}
+	if wrongPassword.Body.String() != unknownEmail.Body.String() {
+		t.Fatalf("expected identical bodies, got %q and %q", wrongPassword.Body.String(), unknownEmail.Body.String())
+	}
+
+	// Unknown emails are checked against a hash as costly as a real one.
+	if cost, err := bcrypt.Cost(dummyPasswordHash); err != nil || cost != bcrypt.DefaultCost {
+		t.Fatalf("expected a dummy hash of cost %d, got %d, %v", bcrypt.DefaultCost, cost, err)
+	}
+}
@@ -0,0 +1,429 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/akctba/secret-santa-go-api/database"
+	"github.com/akctba/secret-santa-go-api/models"
+	"github.com/gorilla/mux"
+)
+
+type createWishlistRequest struct {
+	GroupID string `json:"group_id"`
+	Title   string `json:"title"`
+}
+
+type updateWishlistRequest struct {
+	Title string `json:"title"`
+}
+
+type wishlistItemRequest struct {
+	Title    string  `json:"title"`
+	URL      string  `json:"url"`
+	PriceMin float64 `json:"price_min"`
+	PriceMax float64 `json:"price_max"`
+	Priority int     `json:"priority"`
+	Notes    string  `json:"notes"`
+}
+
+// toWishlistItem validates the request. The returned error is safe to show to the client.
+func (request wishlistItemRequest) toWishlistItem() (models.WishlistItem, error) {
+	item := models.WishlistItem{
+		Title:    strings.TrimSpace(request.Title),
+		URL:      strings.TrimSpace(request.URL),
+		PriceMin: request.PriceMin,
+		PriceMax: request.PriceMax,
+		Priority: request.Priority,
+		Notes:    strings.TrimSpace(request.Notes),
+	}
+
+	if item.Title == "" {
+		return item, errors.New("title is required")
+	}
+	if item.URL != "" && !isWebLink(item.URL) {
+		return item, errors.New("url must be an http or https link")
+	}
+	if item.PriceMin < 0 || item.PriceMax < 0 {
+		return item, errors.New("prices must not be negative")
+	}
+	if item.PriceMax != 0 && item.PriceMax < item.PriceMin {
+		return item, errors.New("price_max must not be lower than price_min")
+	}
+	if item.Priority == 0 {
+		item.Priority = models.PriorityDefault
+	}
+	if item.Priority < models.PriorityHighest || item.Priority > models.PriorityLowest {
+		return item, errors.New("priority must be between 1 and 5")
+	}
+
+	return item, nil
+}
+
+// loadOwnWishlist loads the wishlist named in the path for the authenticated user.
+// It writes the error response and returns false when the user has no such wishlist.
+func loadOwnWishlist(w http.ResponseWriter, r *http.Request, db *sql.DB) (models.Wishlist, bool) {
+	userID, ok := authenticatedUserIDFromRequest(r)
+	if !ok {
+		http.Error(w, "Invalid token", http.StatusUnauthorized)
+		return models.Wishlist{}, false
+	}
+
+	wishlistID, err := strconv.Atoi(mux.Vars(r)["wishlistId"])
+	if err != nil {
+		http.Error(w, "Invalid wishlist ID", http.StatusBadRequest)
+		return models.Wishlist{}, false
+	}
+
+	wishlist, err := database.GetWishlist(db, userID, wishlistID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Wishlist not found", http.StatusNotFound)
+			return wishlist, false
+		}
+
+		http.Error(w, "Failed to get wishlist", http.StatusInternalServerError)
+		return wishlist, false
+	}
+
+	return wishlist, true
+}
+
+// checkWishlistItemBudget keeps items on a group's wishlist within the group's budget.
+// It writes the error response and returns false when the item is out of budget.
+func checkWishlistItemBudget(w http.ResponseWriter, db *sql.DB, wishlist models.Wishlist, item models.WishlistItem) bool {
+	if wishlist.GroupID == "" {
+		return true
+	}
+
+	group, err := database.GetGroupByID(db, wishlist.GroupID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return true
+		}
+
+		http.Error(w, "Failed to get group", http.StatusInternalServerError)
+		return false
+	}
+
+	if err := checkWithinBudget(group, item.PriceMin, item.PriceMax); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// keepItemsWithinBudget returns the items priced within the group's budget.
+func keepItemsWithinBudget(items []models.WishlistItem, group models.Group) []models.WishlistItem {
+	kept := []models.WishlistItem{}
+	for _, item := range items {
+		if checkWithinBudget(group, item.PriceMin, item.PriceMax) == nil {
+			kept = append(kept, item)
+		}
+	}
+	return kept
+}
+
+// CreateWishlist handles POST /wishlist. Creates a wishlist for the authenticated user, either for
+// one of their groups or, without group_id, for every group they take part in.
+func CreateWishlist(w http.ResponseWriter, r *http.Request) {
+	var request createWishlistRequest
+	if err := decodeRequestJSON(r, &request); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := authenticatedUserIDFromRequest(r)
+	if !ok {
+		http.Error(w, "Invalid token", http.StatusUnauthorized)
+		return
+	}
+
+	wishlist := models.Wishlist{
+		UserID:  userID,
+		GroupID: strings.TrimSpace(request.GroupID),
+		Title:   strings.TrimSpace(request.Title),
+	}
+	if wishlist.Title == "" {
+		http.Error(w, "title is required", http.StatusBadRequest)
+		return
+	}
+
+	db, err := getDB()
+	if err != nil {
+		log.Printf("failed to open db in CreateWishlist: %v", err)
+		http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
+		return
+	}
+	defer database.CloseDb(db)
+
+	if wishlist.GroupID != "" {
+		if _, ok := authorizeGroup(w, r, db, wishlist.GroupID, groupAccessMember); !ok {
+			return
+		}
+	}
+
+	err = database.InsertWishlist(db, &wishlist)
+	if err != nil {
+		if errors.Is(err, database.ErrWishlistExists) {
+			http.Error(w, "Wishlist already exists", http.StatusConflict)
+			return
+		}
+
+		http.Error(w, "Failed to create wishlist", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(wishlist)
+}
+
+// GetWishlists handles GET /wishlist. Returns the authenticated user's wishlists with their items.
+func GetWishlists(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authenticatedUserIDFromRequest(r)
+	if !ok {
+		http.Error(w, "Invalid token", http.StatusUnauthorized)
+		return
+	}
+
+	db, err := getDB()
+	if err != nil {
+		log.Printf("failed to open db in GetWishlists: %v", err)
+		http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
+		return
+	}
+	defer database.CloseDb(db)
+
+	wishlists, err := database.GetWishlistsByUserID(db, userID)
+	if err != nil {
+		http.Error(w, "Failed to get wishlists", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(wishlists)
+}
+
+// GetWishlist handles GET /wishlist/{wishlistId}. Returns one of the authenticated user's wishlists.
+func GetWishlist(w http.ResponseWriter, r *http.Request) {
+	db, err := getDB()
+	if err != nil {
+		log.Printf("failed to open db in GetWishlist: %v", err)
+		http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
+		return
+	}
+	defer database.CloseDb(db)
+
+	wishlist, ok := loadOwnWishlist(w, r, db)
+	if !ok {
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(wishlist)
+}
+
+// UpdateWishlist handles PUT /wishlist/{wishlistId}. Renames one of the authenticated user's wishlists.
+func UpdateWishlist(w http.ResponseWriter, r *http.Request) {
+	var request updateWishlistRequest
+	if err := decodeRequestJSON(r, &request); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	title := strings.TrimSpace(request.Title)
+	if title == "" {
+		http.Error(w, "title is required", http.StatusBadRequest)
+		return
+	}
+
+	db, err := getDB()
+	if err != nil {
+		log.Printf("failed to open db in UpdateWishlist: %v", err)
+		http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
+		return
+	}
+	defer database.CloseDb(db)
+
+	wishlist, ok := loadOwnWishlist(w, r, db)
+	if !ok {
+		return
+	}
+
+	err = database.UpdateWishlistTitle(db, wishlist.UserID, wishlist.WishlistID, title)
+	if err != nil {
+		http.Error(w, "Failed to update wishlist", http.StatusInternalServerError)
+		return
+	}
+	wishlist.Title = title
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(wishlist)
+}
+
+// DeleteWishlist handles DELETE /wishlist/{wishlistId}. Removes one of the authenticated user's
+// wishlists and all of its items.
+func DeleteWishlist(w http.ResponseWriter, r *http.Request) {
+	db, err := getDB()
+	if err != nil {
+		log.Printf("failed to open db in DeleteWishlist: %v", err)
+		http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
+		return
+	}
+	defer database.CloseDb(db)
+
+	wishlist, ok := loadOwnWishlist(w, r, db)
+	if !ok {
+		return
+	}
+
+	err = database.DeleteWishlist(db, wishlist.UserID, wishlist.WishlistID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Wishlist not found", http.StatusNotFound)
+			return
+		}
+
+		http.Error(w, "Failed to delete wishlist", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CreateWishlistItem handles POST /wishlist/{wishlistId}/item. Adds an item to one of the
+// authenticated user's wishlists.
+func CreateWishlistItem(w http.ResponseWriter, r *http.Request) {
+	var request wishlistItemRequest
+	if err := decodeRequestJSON(r, &request); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	item, err := request.toWishlistItem()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	db, err := getDB()
+	if err != nil {
+		log.Printf("failed to open db in CreateWishlistItem: %v", err)
+		http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
+		return
+	}
+	defer database.CloseDb(db)
+
+	wishlist, ok := loadOwnWishlist(w, r, db)
+	if !ok {
+		return
+	}
+	if !checkWishlistItemBudget(w, db, wishlist, item) {
+		return
+	}
+	item.WishlistID = wishlist.WishlistID
+
+	err = database.InsertWishlistItem(db, &item)
+	if err != nil {
+		http.Error(w, "Failed to create wishlist item", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(item)
+}
+
+// UpdateWishlistItem handles PUT /wishlist/{wishlistId}/item/{itemId}. Replaces an item on one of
+// the authenticated user's wishlists.
+func UpdateWishlistItem(w http.ResponseWriter, r *http.Request) {
+	itemID, err := strconv.Atoi(mux.Vars(r)["itemId"])
+	if err != nil {
+		http.Error(w, "Invalid item ID", http.StatusBadRequest)
+		return
+	}
+
+	var request wishlistItemRequest
+	if err := decodeRequestJSON(r, &request); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	item, err := request.toWishlistItem()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	db, err := getDB()
+	if err != nil {
+		log.Printf("failed to open db in UpdateWishlistItem: %v", err)
+		http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
+		return
+	}
+	defer database.CloseDb(db)
+
+	wishlist, ok := loadOwnWishlist(w, r, db)
+	if !ok {
+		return
+	}
+	if !checkWishlistItemBudget(w, db, wishlist, item) {
+		return
+	}
+	item.WishlistID = wishlist.WishlistID
+	item.ItemID = itemID
+
+	err = database.UpdateWishlistItem(db, &item)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Wishlist item not found", http.StatusNotFound)
+			return
+		}
+
+		http.Error(w, "Failed to update wishlist item", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(item)
+}
+
+// DeleteWishlistItem handles DELETE /wishlist/{wishlistId}/item/{itemId}. Removes an item from one
+// of the authenticated user's wishlists.
+func DeleteWishlistItem(w http.ResponseWriter, r *http.Request) {
+	itemID, err := strconv.Atoi(mux.Vars(r)["itemId"])
+	if err != nil {
+		http.Error(w, "Invalid item ID", http.StatusBadRequest)
+		return
+	}
+
+	db, err := getDB()
+	if err != nil {
+		log.Printf("failed to open db in DeleteWishlistItem: %v", err)
+		http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
+		return
+	}
+	defer database.CloseDb(db)
+
+	wishlist, ok := loadOwnWishlist(w, r, db)
+	if !ok {
+		return
+	}
+
+	err = database.DeleteWishlistItem(db, wishlist.WishlistID, itemID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Wishlist item not found", http.StatusNotFound)
+			return
+		}
+
+		http.Error(w, "Failed to delete wishlist item", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,143 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func serveWishlistRequest(t *testing.T, handler http.HandlerFunc, method string, target string, vars map[string]string, userID int, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req = mux.SetURLVars(req, vars)
+	req = withAuthenticatedUser(req, userID)
+
+	rr := httptest.NewRecorder()
+	handler(rr, req)
+	return rr
+}
+
+func TestWishlistCRUD(t *testing.T) {
+	setupMigratedTestDB(t)
+	wishlistVars := map[string]string{"wishlistId": "1"}
+	itemVars := map[string]string{"wishlistId": "1", "itemId": "1"}
+
+	rr := serveWishlistRequest(t, CreateWishlist, http.MethodPost, "/wishlist", nil, 1, `{"title":"Birthday"}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d, body: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+
+	rr = serveWishlistRequest(t, CreateWishlistItem, http.MethodPost, "/wishlist/1/item", wishlistVars, 1,
+		`{"title":"Board game","url":"https://example.com/game","price_min":20,"price_max":35,"priority":2}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d, body: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+
+	rr = serveWishlistRequest(t, UpdateWishlistItem, http.MethodPut, "/wishlist/1/item/1", itemVars, 1,
+		`{"title":"Board game","notes":"Any cooperative one"}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d, body: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	item := decodeJSONBody(t, rr.Body.String())
+	if item["notes"] != "Any cooperative one" || item["priority"] != float64(3) || item["url"] != nil {
+		t.Fatalf("unexpected updated item: %s", rr.Body.String())
+	}
+
+	rr = serveWishlistRequest(t, UpdateWishlist, http.MethodPut, "/wishlist/1", wishlistVars, 1, `{"title":"Christmas"}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d, body: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	rr = serveWishlistRequest(t, GetWishlists, http.MethodGet, "/wishlist", nil, 1, "")
+	var wishlists []struct {
+		Title string           `json:"title"`
+		Items []map[string]any `json:"items"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &wishlists); err != nil {
+		t.Fatalf("decode wishlists: %v, body: %s", err, rr.Body.String())
+	}
+	if len(wishlists) != 1 || wishlists[0].Title != "Christmas" || len(wishlists[0].Items) != 1 {
+		t.Fatalf("unexpected wishlists: %s", rr.Body.String())
+	}
+
+	if rr := serveWishlistRequest(t, DeleteWishlistItem, http.MethodDelete, "/wishlist/1/item/1", itemVars, 1, ""); rr.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d, got %d, body: %s", http.StatusNoContent, rr.Code, rr.Body.String())
+	}
+	if rr := serveWishlistRequest(t, DeleteWishlist, http.MethodDelete, "/wishlist/1", wishlistVars, 1, ""); rr.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d, got %d, body: %s", http.StatusNoContent, rr.Code, rr.Body.String())
+	}
+	if rr := serveWishlistRequest(t, GetWishlist, http.MethodGet, "/wishlist/1", wishlistVars, 1, ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected status %d after delete, got %d", http.StatusNotFound, rr.Code)
+	}
+}
+
+func TestWishlistsAreScopedToTheirOwner(t *testing.T) {
+	db := setupMigratedTestDB(t)
+	seedGroupWithParticipants(t, db, 1, 1, 1, 2)
+	wishlistVars := map[string]string{"wishlistId": "1"}
+
+	if rr := serveWishlistRequest(t, CreateWishlist, http.MethodPost, "/wishlist", nil, 3, `{"group_id":"1","title":"Mine"}`); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected status %d for a non-member, got %d, body: %s", http.StatusForbidden, rr.Code, rr.Body.String())
+	}
+
+	rr := serveWishlistRequest(t, CreateWishlist, http.MethodPost, "/wishlist", nil, 2, `{"group_id":"1","title":"Office party"}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d, body: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+	if decodeJSONBody(t, rr.Body.String())["group_id"] != "1" {
+		t.Fatalf("expected the wishlist to belong to group 1, got: %s", rr.Body.String())
+	}
+
+	if rr := serveWishlistRequest(t, CreateWishlist, http.MethodPost, "/wishlist", nil, 2, `{"group_id":"1","title":"Again"}`); rr.Code != http.StatusConflict {
+		t.Fatalf("expected status %d for a second wishlist in the group, got %d, body: %s", http.StatusConflict, rr.Code, rr.Body.String())
+	}
+	if rr := serveWishlistRequest(t, CreateWishlist, http.MethodPost, "/wishlist", nil, 2, `{"title":"Anything"}`); rr.Code != http.StatusCreated {
+		t.Fatalf("expected a general wishlist next to the group one, got status %d, body: %s", rr.Code, rr.Body.String())
+	}
+
+	if rr := serveWishlistRequest(t, GetWishlist, http.MethodGet, "/wishlist/1", wishlistVars, 1, ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected status %d for another user's wishlist, got %d", http.StatusNotFound, rr.Code)
+	}
+	if rr := serveWishlistRequest(t, CreateWishlistItem, http.MethodPost, "/wishlist/1/item", wishlistVars, 1, `{"title":"Socks"}`); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected status %d adding to another user's wishlist, got %d", http.StatusNotFound, rr.Code)
+	}
+}
+
+func TestCreateWishlistItemValidatesFields(t *testing.T) {
+	setupMigratedTestDB(t)
+	wishlistVars := map[string]string{"wishlistId": "1"}
+
+	if rr := serveWishlistRequest(t, CreateWishlist, http.MethodPost, "/wishlist", nil, 1, `{"title":"Birthday"}`); rr.Code != http.StatusCreated {
+		t.Fatalf("create wishlist: status %d, body: %s", rr.Code, rr.Body.String())
+	}
+
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{name: "missing title", body: `{"title":"  "}`, want: "title is required"},
+		{name: "invalid url", body: `{"title":"Book","url":"javascript:alert(1)"}`, want: "url must be an http or https link"},
+		{name: "negative price", body: `{"title":"Book","price_min":-1}`, want: "prices must not be negative"},
+		{name: "inverted price range", body: `{"title":"Book","price_min":30,"price_max":10}`, want: "price_max must not be lower than price_min"},
+		{name: "priority out of range", body: `{"title":"Book","priority":6}`, want: "priority must be between 1 and 5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := serveWishlistRequest(t, CreateWishlistItem, http.MethodPost, "/wishlist/1/item", wishlistVars, 1, tt.body)
+			if rr.Code != http.StatusBadRequest {
+				t.Fatalf("expected status %d, got %d, body: %s", http.StatusBadRequest, rr.Code, rr.Body.String())
+			}
+			if !strings.Contains(rr.Body.String(), tt.want) {
+				t.Fatalf("expected error %q, got: %s", tt.want, rr.Body.String())
+			}
+		})
+	}
+}
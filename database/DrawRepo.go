@@ -0,0 +1,259 @@
+package database
+
+//this file will contain all the database operations for the Draw model
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/akctba/secret-santa-go-api/models"
+)
+
+var (
+	// ErrDrawExists is returned when a group already has a draw.
+	ErrDrawExists = errors.New("group has already been drawn")
+	// ErrDrawNotFound is returned when a group has no draw to reset.
+	ErrDrawNotFound = errors.New("group has not been drawn")
+	// ErrDrawParticipantsChanged is returned when participants joined or left
+	// between reading them and persisting the draw.
+	ErrDrawParticipantsChanged = errors.New("group participants changed during the draw")
+)
+
+// InsertDraw records the draw and every participant's assignment in a single
+// transaction, so a group is either fully drawn or not drawn at all.
+func InsertDraw(db *sql.DB, draw *models.Draw, participants []models.Participant) error {
+	if draw == nil {
+		return errors.New("draw is nil")
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := insertDrawTx(tx, draw, participants); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ResetDraw clears every assignment of the group and marks its active draw as
+// reset, keeping the draw itself as history. The draw scheduler leaves the
+// group alone until its date_draw is moved. It returns ErrDrawNotFound when
+// the group has not been drawn.
+func ResetDraw(db *sql.DB, groupID string, resetBy int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := resetDrawTx(tx, groupID, resetBy, time.Now().UTC()); err != nil {
+		return err
+	}
+
+	sqlStmt := `UPDATE Groups SET auto_draw_status = ?, auto_draw_error = NULL WHERE group_id = ?;`
+	if _, err := tx.Exec(sqlStmt, AutoDrawPaused, groupID); err != nil {
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Redraw atomically resets the group's active draw and records a new one in
+// its place. It returns ErrDrawNotFound when the group has not been drawn.
+func Redraw(db *sql.DB, draw *models.Draw, participants []models.Participant) error {
+	if draw == nil {
+		return errors.New("draw is nil")
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := resetDrawTx(tx, draw.GroupID, draw.RunBy, draw.RunAt); err != nil {
+		return err
+	}
+	if err := insertDrawTx(tx, draw, participants); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func insertDrawTx(tx *sql.Tx, draw *models.Draw, participants []models.Participant) error {
+	var assigned int
+	sqlStmt := `SELECT COUNT(*) FROM Participants WHERE group_id = ? AND friend_user_id IS NOT NULL;`
+	if err := tx.QueryRow(sqlStmt, draw.GroupID).Scan(&assigned); err != nil {
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return err
+	}
+	if assigned > 0 {
+		return ErrDrawExists
+	}
+
+	var total int
+	sqlStmt = `SELECT COUNT(*) FROM Participants WHERE group_id = ?;`
+	if err := tx.QueryRow(sqlStmt, draw.GroupID).Scan(&total); err != nil {
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return err
+	}
+	if total != len(participants) {
+		return ErrDrawParticipantsChanged
+	}
+
+	sqlStmt = `INSERT INTO Draws(group_id, run_at, run_by, algorithm, idempotency_key
+	) VALUES (?, ?, ?, ?, ?);`
+	result, err := tx.Exec(sqlStmt, draw.GroupID, draw.RunAt, nullableUserID(draw.RunBy), draw.Algorithm,
+		sql.NullString{String: draw.IdempotencyKey, Valid: draw.IdempotencyKey != ""})
+	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrDrawExists
+		}
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	sqlStmt = `UPDATE Participants SET friend_user_id = ?
+	WHERE user_id = ? AND group_id = ?;`
+	for _, participant := range participants {
+		result, err := tx.Exec(sqlStmt, participant.FriendUserID, participant.UserID, draw.GroupID)
+		if err != nil {
+			log.Printf("%q: %s\n", err, sqlStmt)
+			return err
+		}
+
+		updated, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if updated != 1 {
+			return fmt.Errorf("user %d: %w", participant.UserID, ErrDrawParticipantsChanged)
+		}
+	}
+
+	draw.DrawID = int(id)
+
+	return nil
+}
+
+func resetDrawTx(tx *sql.Tx, groupID string, resetBy int, resetAt time.Time) error {
+	sqlStmt := `UPDATE Draws SET reset_at = ?, reset_by = ?
+	WHERE group_id = ? AND reset_at IS NULL;`
+	result, err := tx.Exec(sqlStmt, resetAt, nullableUserID(resetBy), groupID)
+	if err != nil {
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return err
+	}
+	resetDraws, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	// Groups drawn before draws were recorded only have assignments to clear.
+	sqlStmt = `UPDATE Participants SET friend_user_id = NULL
+	WHERE group_id = ? AND friend_user_id IS NOT NULL;`
+	result, err = tx.Exec(sqlStmt, groupID)
+	if err != nil {
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return err
+	}
+	clearedAssignments, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if resetDraws == 0 && clearedAssignments == 0 {
+		return ErrDrawNotFound
+	}
+
+	return nil
+}
+
+// GetDrawByGroupID returns the active draw of the group or sql.ErrNoRows.
+func GetDrawByGroupID(db *sql.DB, groupID string) (models.Draw, error) {
+	sqlStmt := `SELECT draw_id, group_id, run_at, run_by, algorithm, idempotency_key, reset_at, reset_by
+	FROM Draws WHERE group_id = ? AND reset_at IS NULL;`
+	return scanDraw(db.QueryRow(sqlStmt, groupID))
+}
+
+// GetDrawsByGroupID returns every draw of the group, including reset ones, oldest first.
+func GetDrawsByGroupID(db *sql.DB, groupID string) ([]models.Draw, error) {
+	draws := []models.Draw{}
+	sqlStmt := `SELECT draw_id, group_id, run_at, run_by, algorithm, idempotency_key, reset_at, reset_by
+	FROM Draws WHERE group_id = ?
+	ORDER BY draw_id;`
+	rows, err := db.Query(sqlStmt, groupID)
+	if err != nil {
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return draws, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		draw, err := scanDraw(rows)
+		if err != nil {
+			return draws, err
+		}
+		draws = append(draws, draw)
+	}
+	if err := rows.Err(); err != nil {
+		return draws, err
+	}
+	return draws, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanDraw(row rowScanner) (models.Draw, error) {
+	var draw models.Draw
+	var runAtValue any
+	var resetAtValue any
+	var runBy sql.NullInt64
+	var resetBy sql.NullInt64
+	var idempotencyKey sql.NullString
+
+	err := row.Scan(&draw.DrawID, &draw.GroupID, &runAtValue, &runBy, &draw.Algorithm, &idempotencyKey,
+		&resetAtValue, &resetBy)
+	if err != nil {
+		return draw, err
+	}
+
+	draw.RunBy = int(runBy.Int64)
+	draw.ResetBy = int(resetBy.Int64)
+	draw.IdempotencyKey = idempotencyKey.String
+
+	draw.RunAt, err = parseDBTime(runAtValue)
+	if err != nil {
+		return draw, err
+	}
+
+	if resetAtValue != nil {
+		resetAt, err := parseDBTime(resetAtValue)
+		if err != nil {
+			return draw, err
+		}
+		draw.ResetAt = &resetAt
+	}
+
+	return draw, nil
+}
+
+func nullableUserID(userID int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(userID), Valid: userID > 0}
+}
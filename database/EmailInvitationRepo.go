@@ -0,0 +1,169 @@
+package database
+
+//this file will contain all the database operations for the EmailInvitation model
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/akctba/secret-santa-go-api/models"
+)
+
+// ErrInvitationExists is returned when the email was already invited to the group.
+var ErrInvitationExists = errors.New("email has already been invited to the group")
+
+func InsertEmailInvitation(db *sql.DB, invitation *models.EmailInvitation) error {
+	if invitation == nil {
+		return errors.New("invitation is nil")
+	}
+
+	invitation.DateCreated = time.Now().UTC()
+	invitation.Status = models.InvitationPending
+
+	sqlStmt := `INSERT INTO EmailInvitations(group_id, email, invited_by, date_created
+	) VALUES (?, ?, ?, ?);`
+	result, err := db.Exec(sqlStmt, invitation.GroupID, invitation.Email, invitation.InvitedBy, invitation.DateCreated)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrInvitationExists
+		}
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	invitation.InvitationID = int(id)
+
+	return nil
+}
+
+// GetEmailInvitationsByGroupID returns the group's invitations, optionally
+// only those with the given status.
+func GetEmailInvitationsByGroupID(db *sql.DB, groupID string, status string) ([]models.EmailInvitation, error) {
+	invitations := []models.EmailInvitation{}
+	sqlStmt := `SELECT invitation_id, group_id, email, invited_by, date_created, user_id, accepted_at
+	FROM EmailInvitations WHERE group_id = ?
+	ORDER BY invitation_id;`
+	rows, err := db.Query(sqlStmt, groupID)
+	if err != nil {
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return invitations, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		invitation, err := scanEmailInvitation(rows)
+		if err != nil {
+			return invitations, err
+		}
+		if status != "" && invitation.Status != status {
+			continue
+		}
+		invitations = append(invitations, invitation)
+	}
+	if err := rows.Err(); err != nil {
+		return invitations, err
+	}
+	return invitations, nil
+}
+
+// AcceptEmailInvitations turns the pending invitations for email into
+// participants of their groups. Invitations to groups that have been drawn in
+// the meantime stay pending, since the user could not be given a secret friend.
+func AcceptEmailInvitations(db *sql.DB, userID int, email string) ([]models.EmailInvitation, error) {
+	accepted := []models.EmailInvitation{}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return accepted, err
+	}
+	defer tx.Rollback()
+
+	sqlStmt := `SELECT invitation_id, group_id, email, invited_by, date_created, user_id, accepted_at
+	FROM EmailInvitations WHERE email = ? AND accepted_at IS NULL
+	ORDER BY invitation_id;`
+	rows, err := tx.Query(sqlStmt, email)
+	if err != nil {
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return accepted, err
+	}
+
+	var pending []models.EmailInvitation
+	for rows.Next() {
+		invitation, err := scanEmailInvitation(rows)
+		if err != nil {
+			rows.Close()
+			return accepted, err
+		}
+		pending = append(pending, invitation)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return accepted, err
+	}
+
+	acceptedAt := time.Now().UTC()
+	sqlStmt = `UPDATE EmailInvitations SET user_id = ?, accepted_at = ? WHERE invitation_id = ?;`
+	for _, invitation := range pending {
+		err := joinGroupTx(tx, invitation.GroupID, userID)
+		if errors.Is(err, ErrDrawExists) {
+			continue
+		}
+		if err != nil && !errors.Is(err, ErrAlreadyParticipant) {
+			return accepted, err
+		}
+
+		if _, err := tx.Exec(sqlStmt, userID, acceptedAt, invitation.InvitationID); err != nil {
+			log.Printf("%q: %s\n", err, sqlStmt)
+			return accepted, err
+		}
+
+		invitation.Status = models.InvitationAccepted
+		invitation.UserID = userID
+		invitation.AcceptedAt = &acceptedAt
+		accepted = append(accepted, invitation)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return []models.EmailInvitation{}, err
+	}
+	return accepted, nil
+}
+
+func scanEmailInvitation(row rowScanner) (models.EmailInvitation, error) {
+	var invitation models.EmailInvitation
+	var dateCreatedValue any
+	var acceptedAtValue any
+	var userID sql.NullInt64
+
+	err := row.Scan(&invitation.InvitationID, &invitation.GroupID, &invitation.Email, &invitation.InvitedBy,
+		&dateCreatedValue, &userID, &acceptedAtValue)
+	if err != nil {
+		return invitation, err
+	}
+
+	invitation.UserID = int(userID.Int64)
+	invitation.Status = models.InvitationPending
+
+	invitation.DateCreated, err = parseDBTime(dateCreatedValue)
+	if err != nil {
+		return invitation, err
+	}
+
+	if acceptedAtValue != nil {
+		acceptedAt, err := parseDBTime(acceptedAtValue)
+		if err != nil {
+			return invitation, err
+		}
+		invitation.AcceptedAt = &acceptedAt
+		invitation.Status = models.InvitationAccepted
+	}
+
+	return invitation, nil
+}
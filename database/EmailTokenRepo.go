@@ -0,0 +1,139 @@
+package database
+
+//this file will contain the database operations shared by the single-use tokens sent by email
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+)
+
+// emailTokenTable is a table of single-use tokens sent by email. Every such
+// table has the same columns: token_id, user_id, token_hash, date_created,
+// expires_at and used_at.
+type emailTokenTable string
+
+const (
+	passwordResetTokens     emailTokenTable = "PasswordResetTokens"
+	emailVerificationTokens emailTokenTable = "EmailVerificationTokens"
+	magicLinkTokens         emailTokenTable = "MagicLinkTokens"
+)
+
+// insertEmailToken stores a token for the user. Only the token's hash is kept,
+// so a leaked database cannot be used to take over accounts. Tokens of the
+// table that expired, used or not, are cleaned up along the way.
+func insertEmailToken(db *sql.DB, table emailTokenTable, userID int, tokenHash string, expiresAt time.Time) error {
+	now := time.Now().UTC()
+
+	sqlStmt := `DELETE FROM ` + string(table) + ` WHERE expires_at < ?;`
+	if _, err := db.Exec(sqlStmt, now); err != nil {
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return err
+	}
+
+	sqlStmt = `INSERT INTO ` + string(table) + `(user_id, token_hash, date_created, expires_at
+	) VALUES (?, ?, ?, ?);`
+	_, err := db.Exec(sqlStmt, userID, tokenHash, now, expiresAt.UTC())
+	if err != nil {
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return err
+	}
+	return nil
+}
+
+// consumeEmailToken uses up the token with the given hash, along with every
+// other outstanding token of the user in the table, and calls apply with the
+// user's ID in the same transaction. Unknown, expired and already used tokens
+// return invalid, and so may apply when the user is gone. It returns the
+// user's ID.
+func consumeEmailToken(db *sql.DB, table emailTokenTable, tokenHash string, now time.Time, invalid error,
+	apply func(tx *sql.Tx, userID int) error) (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var userID int
+	var expiresAtValue any
+	sqlStmt := `SELECT user_id, expires_at FROM ` + string(table) + `
+	WHERE token_hash = ? AND used_at IS NULL;`
+	if err := tx.QueryRow(sqlStmt, tokenHash).Scan(&userID, &expiresAtValue); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, invalid
+		}
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return 0, err
+	}
+
+	expiresAt, err := parseDBTime(expiresAtValue)
+	if err != nil {
+		return 0, err
+	}
+	if !now.Before(expiresAt) {
+		return 0, invalid
+	}
+
+	sqlStmt = `UPDATE ` + string(table) + ` SET used_at = ? WHERE user_id = ? AND used_at IS NULL;`
+	if _, err := tx.Exec(sqlStmt, now.UTC(), userID); err != nil {
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return 0, err
+	}
+
+	if err := apply(tx, userID); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return userID, nil
+}
+
+// verifyUserEmailTx marks the user's email address as verified, keeping an
+// earlier verification time. The first verification revokes the user's
+// refresh tokens, since whoever registered the address never proved they own
+// it and must sign in again. It reports whether this was the first
+// verification, and returns sql.ErrNoRows when the user is gone.
+func verifyUserEmailTx(tx *sql.Tx, userID int, now time.Time) (bool, error) {
+	var unverified bool
+	sqlStmt := `SELECT email_verified_at IS NULL FROM Users WHERE user_id = ?;`
+	if err := tx.QueryRow(sqlStmt, userID).Scan(&unverified); err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("%q: %s\n", err, sqlStmt)
+		}
+		return false, err
+	}
+	if !unverified {
+		return false, nil
+	}
+
+	sqlStmt = `UPDATE Users SET email_verified_at = ? WHERE user_id = ?;`
+	if _, err := tx.Exec(sqlStmt, now.UTC(), userID); err != nil {
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return false, err
+	}
+	if err := revokeUserRefreshTokensTx(tx, userID, now); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// claimUserEmailTx verifies the user's email address for a sign-in that does
+// not use the password, such as a login link or a provider account. On the
+// first verification the password is cleared as well: it was set by whoever
+// registered the address, who may not be the person now proving they own it.
+func claimUserEmailTx(tx *sql.Tx, userID int, now time.Time) error {
+	first, err := verifyUserEmailTx(tx, userID, now)
+	if err != nil || !first {
+		return err
+	}
+
+	sqlStmt := `UPDATE Users SET password = '' WHERE user_id = ?;`
+	if _, err := tx.Exec(sqlStmt, userID); err != nil {
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package database
+
+//this file will contain all the database operations for email verification tokens
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ErrVerificationTokenInvalid is returned for unknown, expired and already used
+// email verification tokens.
+var ErrVerificationTokenInvalid = errors.New("email verification token is invalid or has expired")
+
+// InsertEmailVerificationToken stores a verification token for the user.
+func InsertEmailVerificationToken(db *sql.DB, userID int, tokenHash string, expiresAt time.Time) error {
+	return insertEmailToken(db, emailVerificationTokens, userID, tokenHash, expiresAt)
+}
+
+// VerifyEmail uses up the verification token with the given hash, along with
+// every other outstanding verification token of the user, and marks the
+// user's email address as verified. An address verified earlier keeps its
+// original verification time. The first verification revokes the user's
+// refresh tokens. It returns the user's ID, or ErrVerificationTokenInvalid.
+func VerifyEmail(db *sql.DB, tokenHash string, now time.Time) (int, error) {
+	return consumeEmailToken(db, emailVerificationTokens, tokenHash, now, ErrVerificationTokenInvalid,
+		func(tx *sql.Tx, userID int) error {
+			_, err := verifyUserEmailTx(tx, userID, now)
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrVerificationTokenInvalid
+			}
+			return err
+		})
+}
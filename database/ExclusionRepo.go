@@ -0,0 +1,104 @@
+package database
+
+//this file will contain all the database operations for the Exclusion model
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/akctba/secret-santa-go-api/models"
+)
+
+func InsertExclusion(db *sql.DB, exclusion *models.Exclusion) error {
+	if exclusion == nil {
+		return errors.New("exclusion is nil")
+	}
+
+	exclusion.DateCreated = time.Now().UTC()
+
+	sqlStmt := `INSERT INTO Exclusions(group_id, user_id, excluded_user_id, one_way, date_created
+	) VALUES (?, ?, ?, ?, ?);`
+	result, err := db.Exec(sqlStmt, exclusion.GroupID, exclusion.UserID, exclusion.ExcludedUserID,
+		exclusion.OneWay, exclusion.DateCreated)
+	if err != nil {
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	exclusion.ExclusionID = int(id)
+
+	return nil
+}
+
+func GetExclusionByID(db *sql.DB, groupID string, exclusionID int) (models.Exclusion, error) {
+	var exclusion models.Exclusion
+	sqlStmt := `SELECT exclusion_id, group_id, user_id, excluded_user_id, one_way, date_created
+	FROM Exclusions WHERE group_id = ? AND exclusion_id = ?;`
+	row := db.QueryRow(sqlStmt, groupID, exclusionID)
+	var dateCreatedValue any
+
+	err := row.Scan(&exclusion.ExclusionID, &exclusion.GroupID, &exclusion.UserID,
+		&exclusion.ExcludedUserID, &exclusion.OneWay, &dateCreatedValue)
+	if err != nil {
+		return exclusion, err
+	}
+
+	exclusion.DateCreated, err = parseDBTime(dateCreatedValue)
+	if err != nil {
+		return exclusion, err
+	}
+
+	return exclusion, nil
+}
+
+func GetExclusionsByGroupID(db *sql.DB, groupID string) ([]models.Exclusion, error) {
+	exclusions := []models.Exclusion{}
+	sqlStmt := `SELECT exclusion_id, group_id, user_id, excluded_user_id, one_way, date_created
+	FROM Exclusions WHERE group_id = ?
+	ORDER BY exclusion_id;`
+	rows, err := db.Query(sqlStmt, groupID)
+	if err != nil {
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return exclusions, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var exclusion models.Exclusion
+		var dateCreatedValue any
+
+		err := rows.Scan(&exclusion.ExclusionID, &exclusion.GroupID, &exclusion.UserID,
+			&exclusion.ExcludedUserID, &exclusion.OneWay, &dateCreatedValue)
+		if err != nil {
+			return exclusions, err
+		}
+
+		exclusion.DateCreated, err = parseDBTime(dateCreatedValue)
+		if err != nil {
+			return exclusions, err
+		}
+
+		exclusions = append(exclusions, exclusion)
+	}
+	if err := rows.Err(); err != nil {
+		return exclusions, err
+	}
+	return exclusions, nil
+}
+
+func DeleteExclusion(db *sql.DB, groupID string, exclusionID int) error {
+	sqlStmt := `DELETE FROM Exclusions WHERE group_id = ? AND exclusion_id = ?;`
+	_, err := db.Exec(sqlStmt, groupID, exclusionID)
+	if err != nil {
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return err
+	}
+	return nil
+}
@@ -7,18 +7,32 @@ import (
 	"errors"
 	"log"
 	"strconv"
+	"time"
 
 	"github.com/akctba/secret-santa-go-api/models"
 )
 
+// ErrOwnerNotParticipant is returned when an owner who does not take part in
+// their group tries to hand it over, since they could not stay on as an
+// organizer.
+var ErrOwnerNotParticipant = errors.New("owner does not take part in the group")
+
+// selectGroupStmt lists the columns scanGroup expects.
+const selectGroupStmt = `SELECT g.group_id, g.name, g.date_created, g.date_draw, g.creator_user_id,
+	g.budget_min, g.budget_max, g.currency, g.event_date, g.location, g.meeting_url, g.description
+	FROM Groups g`
+
 func InsertGroup(db *sql.DB, group *models.Group) error {
 	if group == nil {
 		return errors.New("group is nil")
 	}
 
-	sqlStmt := `INSERT INTO Groups(name, date_created, date_draw, creator_user_id
-	) VALUES (?, ?, ?, ?);`
-	result, err := db.Exec(sqlStmt, group.Name, group.DateCreated, group.DateDraw, group.CreatorUserID)
+	sqlStmt := `INSERT INTO Groups(name, date_created, date_draw, creator_user_id, budget_min, budget_max, currency,
+	event_date, location, meeting_url, description
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`
+	result, err := db.Exec(sqlStmt, group.Name, group.DateCreated, group.DateDraw.UTC(), group.CreatorUserID,
+		group.BudgetMin, group.BudgetMax, group.Currency, nullableTime(group.EventDate), group.Location,
+		group.MeetingURL, group.Description)
 	if err != nil {
 		log.Printf("%q: %s\n", err, sqlStmt)
 		return err
@@ -35,21 +49,17 @@ func InsertGroup(db *sql.DB, group *models.Group) error {
 }
 
 func GetGroupByID(db *sql.DB, id string) (models.Group, error) {
-	var group models.Group
-	sqlStmt := `SELECT group_id, name, date_created, date_draw, creator_user_id
-	FROM Groups WHERE group_id = ?;`
-	row := db.QueryRow(sqlStmt, id)
-	err := row.Scan(&group.GroupID, &group.Name, &group.DateCreated, &group.DateDraw, &group.CreatorUserID)
-	if err != nil {
-		return group, err
-	}
-	return group, nil
+	sqlStmt := selectGroupStmt + ` WHERE g.group_id = ?;`
+	return scanGroup(db.QueryRow(sqlStmt, id))
 }
 
 func UpdateGroup(db *sql.DB, group models.Group) error {
-	sqlStmt := `UPDATE Groups SET name = ?, date_created = ?, date_draw = ?, creator_user_id = ?
+	sqlStmt := `UPDATE Groups SET name = ?, date_created = ?, date_draw = ?, creator_user_id = ?,
+	budget_min = ?, budget_max = ?, currency = ?, event_date = ?, location = ?, meeting_url = ?, description = ?
 	WHERE group_id = ?;`
-	_, err := db.Exec(sqlStmt, group.Name, group.DateCreated, group.DateDraw, group.CreatorUserID, group.GroupID)
+	_, err := db.Exec(sqlStmt, group.Name, group.DateCreated, group.DateDraw.UTC(), group.CreatorUserID,
+		group.BudgetMin, group.BudgetMax, group.Currency, nullableTime(group.EventDate), group.Location,
+		group.MeetingURL, group.Description, group.GroupID)
 	if err != nil {
 		log.Printf("%q: %s\n", err, sqlStmt)
 		return err
@@ -57,20 +67,104 @@ func UpdateGroup(db *sql.DB, group models.Group) error {
 	return nil
 }
 
+// TransferGroupOwnership makes newOwnerID, who must already take part in the
+// group, its owner. The previous owner stays on as an organizer, so they must
+// take part in the group too, or ErrOwnerNotParticipant is returned.
+func TransferGroupOwnership(db *sql.DB, groupID string, newOwnerID int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	sqlStmt := `UPDATE Participants SET role = 'owner' WHERE group_id = ? AND user_id = ?;`
+	result, err := tx.Exec(sqlStmt, groupID, newOwnerID)
+	if err != nil {
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	sqlStmt = `UPDATE Participants SET role = 'organizer' WHERE group_id = ? AND user_id = (
+		SELECT creator_user_id FROM Groups WHERE group_id = ?
+	);`
+	result, err = tx.Exec(sqlStmt, groupID, groupID)
+	if err != nil {
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return err
+	}
+	affected, err = result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrOwnerNotParticipant
+	}
+
+	sqlStmt = `UPDATE Groups SET creator_user_id = ? WHERE group_id = ?;`
+	if _, err := tx.Exec(sqlStmt, newOwnerID, groupID); err != nil {
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DeleteGroup removes the group together with its participants, draws,
+// exclusions, invites, group wishlists and messages. It returns sql.ErrNoRows
+// when the group does not exist.
 func DeleteGroup(db *sql.DB, id string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	cleanup := []string{
+		`DELETE FROM WishlistItems WHERE wishlist_id IN (SELECT wishlist_id FROM Wishlists WHERE group_id = ?);`,
+		`DELETE FROM Wishlists WHERE group_id = ?;`,
+		`DELETE FROM Messages WHERE group_id = ?;`,
+		`DELETE FROM EmailInvitations WHERE group_id = ?;`,
+		`DELETE FROM GroupInvites WHERE group_id = ?;`,
+		`DELETE FROM Exclusions WHERE group_id = ?;`,
+		`DELETE FROM Draws WHERE group_id = ?;`,
+		`DELETE FROM Participants WHERE group_id = ?;`,
+	}
+	for _, sqlStmt := range cleanup {
+		if _, err := tx.Exec(sqlStmt, id); err != nil {
+			log.Printf("%q: %s\n", err, sqlStmt)
+			return err
+		}
+	}
+
 	sqlStmt := `DELETE FROM Groups WHERE group_id = ?;`
-	_, err := db.Exec(sqlStmt, id)
+	result, err := tx.Exec(sqlStmt, id)
 	if err != nil {
 		log.Printf("%q: %s\n", err, sqlStmt)
 		return err
 	}
-	return nil
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return tx.Commit()
 }
 
+// GetGroupsByUserID returns the groups the user created.
 func GetGroupsByUserID(db *sql.DB, id int) ([]models.Group, error) {
-	var groups []models.Group
-	sqlStmt := `SELECT group_id, name, date_created, date_draw, creator_user_id
-	FROM Groups WHERE creator_user_id = ?;`
+	groups := []models.Group{}
+	sqlStmt := selectGroupStmt + ` WHERE g.creator_user_id = ?
+	ORDER BY g.group_id;`
 	rows, err := db.Query(sqlStmt, id)
 	if err != nil {
 		log.Printf("%q: %s\n", err, sqlStmt)
@@ -78,12 +172,131 @@ func GetGroupsByUserID(db *sql.DB, id int) ([]models.Group, error) {
 	}
 	defer rows.Close()
 	for rows.Next() {
-		var group models.Group
-		err := rows.Scan(&group.GroupID, &group.Name, &group.DateCreated, &group.DateDraw, &group.CreatorUserID)
+		group, err := scanGroup(rows)
 		if err != nil {
-			log.Fatal(err)
+			return groups, err
 		}
 		groups = append(groups, group)
 	}
+	if err := rows.Err(); err != nil {
+		return groups, err
+	}
 	return groups, nil
 }
+
+// Outcomes the draw scheduler records on a group.
+const (
+	AutoDrawDrawn   = "drawn"
+	AutoDrawFailed  = "failed"
+	AutoDrawSkipped = "skipped"
+	// AutoDrawPaused is recorded when an organizer resets the draw, so the
+	// scheduler does not redraw the group until its date_draw is moved.
+	AutoDrawPaused = "paused"
+)
+
+// GetGroupsDueForDraw returns the groups whose date_draw has passed and that
+// have no active draw, skipping groups leased to a scheduler instance, groups
+// that already failed maxAttempts times and groups paused by a reset. Groups
+// without a date_draw are never due.
+func GetGroupsDueForDraw(db *sql.DB, now time.Time, maxAttempts int) ([]models.Group, error) {
+	groups := []models.Group{}
+	// date_draw is stored in UTC with the driver's fixed layout, so comparing
+	// it as text orders it by time.
+	sqlStmt := selectGroupStmt + `
+	WHERE g.date_draw > ? AND g.date_draw <= ?
+	AND (g.auto_draw_status IS NULL OR (g.auto_draw_status = 'failed' AND g.auto_draw_attempts < ?))
+	AND (g.draw_claim_expires_at IS NULL OR g.draw_claim_expires_at <= ?)
+	AND NOT EXISTS (SELECT 1 FROM Draws d WHERE d.group_id = g.group_id AND d.reset_at IS NULL)
+	AND NOT EXISTS (SELECT 1 FROM Participants p WHERE p.group_id = g.group_id AND p.friend_user_id IS NOT NULL)
+	ORDER BY g.group_id;`
+	rows, err := db.Query(sqlStmt, time.Time{}, now.UTC(), maxAttempts, now.Unix())
+	if err != nil {
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return groups, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		group, err := scanGroup(rows)
+		if err != nil {
+			return groups, err
+		}
+		groups = append(groups, group)
+	}
+	if err := rows.Err(); err != nil {
+		return groups, err
+	}
+	return groups, nil
+}
+
+// ResetAutoDraw forgets earlier scheduled draw attempts, e.g. after the group's
+// date_draw was moved, so the scheduler tries again from scratch.
+func ResetAutoDraw(db *sql.DB, groupID string) error {
+	sqlStmt := `UPDATE Groups SET auto_draw_status = NULL, auto_draw_error = NULL, auto_draw_attempts = 0
+	WHERE group_id = ?;`
+	_, err := db.Exec(sqlStmt, groupID)
+	if err != nil {
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return err
+	}
+	return nil
+}
+
+// ClaimGroupForDraw leases the group to instanceID until now+lease. It reports
+// false when another instance holds an unexpired lease.
+func ClaimGroupForDraw(db *sql.DB, groupID string, instanceID string, now time.Time, lease time.Duration) (bool, error) {
+	sqlStmt := `UPDATE Groups SET draw_claimed_by = ?, draw_claim_expires_at = ?
+	WHERE group_id = ? AND (draw_claim_expires_at IS NULL OR draw_claim_expires_at <= ?);`
+	result, err := db.Exec(sqlStmt, instanceID, now.Add(lease).Unix(), groupID, now.Unix())
+	if err != nil {
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+// RecordAutoDrawOutcome stores the outcome of a scheduled draw and releases
+// the lease held by instanceID. A non-zero retryAt keeps the group from being
+// picked up again before then.
+func RecordAutoDrawOutcome(db *sql.DB, groupID string, instanceID string, status string, message string, retryAt time.Time) error {
+	retry := sql.NullInt64{Int64: retryAt.Unix(), Valid: !retryAt.IsZero()}
+	sqlStmt := `UPDATE Groups SET auto_draw_status = ?, auto_draw_error = ?, auto_draw_attempts = auto_draw_attempts + 1,
+	draw_claimed_by = NULL, draw_claim_expires_at = ?
+	WHERE group_id = ? AND draw_claimed_by = ?;`
+	_, err := db.Exec(sqlStmt, status, sql.NullString{String: message, Valid: message != ""}, retry, groupID, instanceID)
+	if err != nil {
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return err
+	}
+	return nil
+}
+
+func scanGroup(row rowScanner) (models.Group, error) {
+	var group models.Group
+	var eventDate sql.NullTime
+
+	err := row.Scan(&group.GroupID, &group.Name, &group.DateCreated, &group.DateDraw, &group.CreatorUserID,
+		&group.BudgetMin, &group.BudgetMax, &group.Currency, &eventDate, &group.Location, &group.MeetingURL,
+		&group.Description)
+	if err != nil {
+		return group, err
+	}
+
+	if eventDate.Valid {
+		group.EventDate = &eventDate.Time
+	}
+	return group, nil
+}
+
+// nullableTime stores a missing time as NULL.
+func nullableTime(value *time.Time) sql.NullTime {
+	if value == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: value.UTC(), Valid: true}
+}
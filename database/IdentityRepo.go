@@ -0,0 +1,180 @@
+package database
+
+//this file will contain all the database operations for OpenID Connect sign-ins
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/akctba/secret-santa-go-api/models"
+)
+
+var (
+	// ErrOIDCSigninInvalid is returned for unknown, expired and already
+	// completed sign-ins.
+	ErrOIDCSigninInvalid = errors.New("sign-in is invalid or has expired")
+	// ErrIdentityTaken is returned when linking a provider account that is
+	// already linked to a user.
+	ErrIdentityTaken = errors.New("provider account is already linked")
+)
+
+// InsertOIDCSignin stores a sign-in waiting for the provider's callback.
+// Sign-ins that expired without a callback are cleaned up along the way.
+func InsertOIDCSignin(db *sql.DB, signin models.OIDCSignin, now time.Time) error {
+	sqlStmt := `DELETE FROM OIDCSignins WHERE expires_at < ?;`
+	if _, err := db.Exec(sqlStmt, now.UTC()); err != nil {
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return err
+	}
+
+	sqlStmt = `INSERT INTO OIDCSignins(state_hash, provider, nonce, code_verifier, expires_at
+	) VALUES (?, ?, ?, ?, ?);`
+	_, err := db.Exec(sqlStmt, signin.StateHash, signin.Provider, signin.Nonce, signin.CodeVerifier, signin.ExpiresAt.UTC())
+	if err != nil {
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return err
+	}
+	return nil
+}
+
+// ConsumeOIDCSignin removes the sign-in with the given state hash and returns
+// it, so each callback can only be completed once. It returns
+// ErrOIDCSigninInvalid when the sign-in is unknown, expired or was started
+// with another provider.
+func ConsumeOIDCSignin(db *sql.DB, stateHash string, provider string, now time.Time) (models.OIDCSignin, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return models.OIDCSignin{}, err
+	}
+	defer tx.Rollback()
+
+	var signin models.OIDCSignin
+	var expiresAtValue any
+	sqlStmt := `SELECT state_hash, provider, nonce, code_verifier, expires_at FROM OIDCSignins WHERE state_hash = ?;`
+	err = tx.QueryRow(sqlStmt, stateHash).Scan(&signin.StateHash, &signin.Provider, &signin.Nonce,
+		&signin.CodeVerifier, &expiresAtValue)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.OIDCSignin{}, ErrOIDCSigninInvalid
+		}
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return models.OIDCSignin{}, err
+	}
+
+	signin.ExpiresAt, err = parseDBTime(expiresAtValue)
+	if err != nil {
+		return models.OIDCSignin{}, err
+	}
+
+	sqlStmt = `DELETE FROM OIDCSignins WHERE state_hash = ?;`
+	if _, err := tx.Exec(sqlStmt, stateHash); err != nil {
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return models.OIDCSignin{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return models.OIDCSignin{}, err
+	}
+
+	if signin.Provider != provider || !now.Before(signin.ExpiresAt) {
+		return models.OIDCSignin{}, ErrOIDCSigninInvalid
+	}
+	return signin, nil
+}
+
+// GetUserByIdentity returns the user linked to the provider account.
+func GetUserByIdentity(db *sql.DB, provider string, subject string) (models.User, error) {
+	sqlStmt := selectUserStmt + ` WHERE user_id = (
+		SELECT user_id FROM UserIdentities WHERE provider = ? AND subject = ?);`
+	return scanUser(db.QueryRow(sqlStmt, provider, subject))
+}
+
+// LinkUserIdentity links the provider account to an existing user and marks
+// the user's email address as verified, since accounts are only linked on an
+// address the provider has verified. An address verified earlier keeps its
+// original verification time. When the address was not verified yet, whoever
+// registered the account never proved they own it, so its password is cleared
+// and its refresh tokens are revoked: otherwise someone could register the
+// address first and keep access once its owner signs in with the provider.
+func LinkUserIdentity(db *sql.DB, identity models.UserIdentity, now time.Time) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := claimUserEmailTx(tx, identity.UserID, now); err != nil {
+		return err
+	}
+	if err := insertUserIdentityTx(tx, identity, now); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// InsertUserWithIdentity registers a user who signed in with a provider and
+// links the provider account to them. Their email address, verified by the
+// provider, is verified from the start. It returns ErrEmailTaken when the
+// address is in use and ErrIdentityTaken when the account is already linked.
+func InsertUserWithIdentity(db *sql.DB, user *models.User, identity models.UserIdentity, now time.Time) error {
+	if user == nil {
+		return errors.New("user is nil")
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	sqlStmt := `INSERT INTO Users(user_name, user_email, password, email_verified_at)
+	SELECT ?, ?, ?, ? ` + emailFreeCondition + `;`
+	result, err := tx.Exec(sqlStmt, user.UserName, user.UserEmail, user.Password, now.UTC(), user.UserEmail)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrEmailTaken
+		}
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return err
+	}
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if inserted == 0 {
+		return ErrEmailTaken
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	identity.UserID = int(id)
+	if err := insertUserIdentityTx(tx, identity, now); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	verifiedAt := now.UTC()
+	user.UserID = int(id)
+	user.EmailVerifiedAt = &verifiedAt
+	return nil
+}
+
+func insertUserIdentityTx(tx *sql.Tx, identity models.UserIdentity, now time.Time) error {
+	sqlStmt := `INSERT INTO UserIdentities(provider, subject, user_id, date_created) VALUES (?, ?, ?, ?);`
+	_, err := tx.Exec(sqlStmt, identity.Provider, identity.Subject, identity.UserID, now.UTC())
+	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrIdentityTaken
+		}
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package database
+
+//this file will contain all the database operations for the Invite model
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/akctba/secret-santa-go-api/models"
+)
+
+var (
+	// ErrInviteNotFound is returned for unknown and revoked invite codes.
+	ErrInviteNotFound = errors.New("invite not found")
+	// ErrInviteExpired is returned when an invite is used after its expiry.
+	ErrInviteExpired = errors.New("invite has expired")
+	// ErrInviteExhausted is returned when an invite has no uses left.
+	ErrInviteExhausted = errors.New("invite has no uses left")
+)
+
+func InsertInvite(db *sql.DB, invite *models.Invite) error {
+	if invite == nil {
+		return errors.New("invite is nil")
+	}
+
+	invite.DateCreated = time.Now().UTC()
+
+	sqlStmt := `INSERT INTO GroupInvites(group_id, code, created_by, date_created, expires_at, max_uses
+	) VALUES (?, ?, ?, ?, ?, ?);`
+	result, err := db.Exec(sqlStmt, invite.GroupID, invite.Code, invite.CreatedBy, invite.DateCreated,
+		invite.ExpiresAt.UTC(), invite.MaxUses)
+	if err != nil {
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	invite.InviteID = int(id)
+
+	return nil
+}
+
+// GetInvitesByGroupID returns every invite of the group, including expired and revoked ones.
+func GetInvitesByGroupID(db *sql.DB, groupID string) ([]models.Invite, error) {
+	invites := []models.Invite{}
+	sqlStmt := `SELECT invite_id, group_id, code, created_by, date_created, expires_at, max_uses, uses, revoked_at
+	FROM GroupInvites WHERE group_id = ?
+	ORDER BY invite_id;`
+	rows, err := db.Query(sqlStmt, groupID)
+	if err != nil {
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return invites, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		invite, err := scanInvite(rows)
+		if err != nil {
+			return invites, err
+		}
+		invites = append(invites, invite)
+	}
+	if err := rows.Err(); err != nil {
+		return invites, err
+	}
+	return invites, nil
+}
+
+// RevokeInvite stops an invite from being accepted. It returns sql.ErrNoRows
+// when the group has no such invite that is still active.
+func RevokeInvite(db *sql.DB, groupID string, inviteID int) error {
+	sqlStmt := `UPDATE GroupInvites SET revoked_at = ?
+	WHERE group_id = ? AND invite_id = ? AND revoked_at IS NULL;`
+	result, err := db.Exec(sqlStmt, time.Now().UTC(), groupID, inviteID)
+	if err != nil {
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// AcceptInvite adds the user to the invite's group and uses up one of its uses
+// in a single transaction, so concurrent accepts can never exceed max_uses.
+func AcceptInvite(db *sql.DB, code string, userID int, now time.Time) (models.Invite, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return models.Invite{}, err
+	}
+	defer tx.Rollback()
+
+	sqlStmt := `SELECT invite_id, group_id, code, created_by, date_created, expires_at, max_uses, uses, revoked_at
+	FROM GroupInvites WHERE code = ?;`
+	invite, err := scanInvite(tx.QueryRow(sqlStmt, code))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return invite, ErrInviteNotFound
+		}
+		return invite, err
+	}
+
+	if invite.RevokedAt != nil {
+		return invite, ErrInviteNotFound
+	}
+	if !now.Before(invite.ExpiresAt) {
+		return invite, ErrInviteExpired
+	}
+
+	sqlStmt = `UPDATE GroupInvites SET uses = uses + 1
+	WHERE invite_id = ? AND (max_uses = 0 OR uses < max_uses);`
+	result, err := tx.Exec(sqlStmt, invite.InviteID)
+	if err != nil {
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return invite, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return invite, err
+	}
+	if affected == 0 {
+		return invite, ErrInviteExhausted
+	}
+	invite.Uses++
+
+	if err := joinGroupTx(tx, invite.GroupID, userID); err != nil {
+		return invite, err
+	}
+
+	return invite, tx.Commit()
+}
+
+func scanInvite(row rowScanner) (models.Invite, error) {
+	var invite models.Invite
+	var dateCreatedValue any
+	var expiresAtValue any
+	var revokedAtValue any
+
+	err := row.Scan(&invite.InviteID, &invite.GroupID, &invite.Code, &invite.CreatedBy, &dateCreatedValue,
+		&expiresAtValue, &invite.MaxUses, &invite.Uses, &revokedAtValue)
+	if err != nil {
+		return invite, err
+	}
+
+	invite.DateCreated, err = parseDBTime(dateCreatedValue)
+	if err != nil {
+		return invite, err
+	}
+	invite.ExpiresAt, err = parseDBTime(expiresAtValue)
+	if err != nil {
+		return invite, err
+	}
+
+	if revokedAtValue != nil {
+		revokedAt, err := parseDBTime(revokedAtValue)
+		if err != nil {
+			return invite, err
+		}
+		invite.RevokedAt = &revokedAt
+	}
+
+	return invite, nil
+}
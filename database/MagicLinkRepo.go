@@ -0,0 +1,36 @@
+package database
+
+//this file will contain all the database operations for login links
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ErrMagicLinkInvalid is returned for unknown, expired and already used login
+// link tokens.
+var ErrMagicLinkInvalid = errors.New("login link is invalid or has expired")
+
+// InsertMagicLinkToken stores a login link token for the user.
+func InsertMagicLinkToken(db *sql.DB, userID int, tokenHash string, expiresAt time.Time) error {
+	return insertEmailToken(db, magicLinkTokens, userID, tokenHash, expiresAt)
+}
+
+// ConsumeMagicLinkToken uses up the login link token with the given hash,
+// along with every other outstanding login link of the user, so only the
+// latest sign-in goes through. Following the link proves the user reads the
+// address, so it is marked as verified, keeping an earlier verification
+// time. The first verification clears the password and revokes the refresh
+// tokens, which belong to whoever registered the address. It returns the
+// user's ID, or ErrMagicLinkInvalid.
+func ConsumeMagicLinkToken(db *sql.DB, tokenHash string, now time.Time) (int, error) {
+	return consumeEmailToken(db, magicLinkTokens, tokenHash, now, ErrMagicLinkInvalid,
+		func(tx *sql.Tx, userID int) error {
+			err := claimUserEmailTx(tx, userID, now)
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrMagicLinkInvalid
+			}
+			return err
+		})
+}
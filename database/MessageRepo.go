@@ -0,0 +1,74 @@
+package database
+
+//this file will contain all the database operations for the Message model
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/akctba/secret-santa-go-api/models"
+)
+
+func InsertMessage(db *sql.DB, message *models.Message) error {
+	if message == nil {
+		return errors.New("message is nil")
+	}
+
+	message.DateCreated = time.Now().UTC()
+
+	sqlStmt := `INSERT INTO Messages(group_id, draw_id, giver_user_id, receiver_user_id, sender, body, date_created
+	) VALUES (?, ?, ?, ?, ?, ?, ?);`
+	result, err := db.Exec(sqlStmt, message.GroupID, message.DrawID, message.GiverUserID, message.ReceiverUserID,
+		message.Sender, message.Body, message.DateCreated)
+	if err != nil {
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	message.MessageID = int(id)
+
+	return nil
+}
+
+// GetMessageThread returns the messages between a giver and the friend they drew
+// in one draw of the group, oldest first. A redraw starts a new thread.
+func GetMessageThread(db *sql.DB, groupID string, drawID int, giverUserID int, receiverUserID int) ([]models.Message, error) {
+	messages := []models.Message{}
+	sqlStmt := `SELECT message_id, group_id, draw_id, giver_user_id, receiver_user_id, sender, body, date_created
+	FROM Messages WHERE group_id = ? AND draw_id = ? AND giver_user_id = ? AND receiver_user_id = ?
+	ORDER BY message_id;`
+	rows, err := db.Query(sqlStmt, groupID, drawID, giverUserID, receiverUserID)
+	if err != nil {
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return messages, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var message models.Message
+		var dateCreatedValue any
+
+		err := rows.Scan(&message.MessageID, &message.GroupID, &message.DrawID, &message.GiverUserID,
+			&message.ReceiverUserID, &message.Sender, &message.Body, &dateCreatedValue)
+		if err != nil {
+			return messages, err
+		}
+
+		message.DateCreated, err = parseDBTime(dateCreatedValue)
+		if err != nil {
+			return messages, err
+		}
+		messages = append(messages, message)
+	}
+	if err := rows.Err(); err != nil {
+		return messages, err
+	}
+	return messages, nil
+}
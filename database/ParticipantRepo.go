@@ -4,20 +4,69 @@ package database
 
 import (
 	"database/sql"
+	"errors"
 	"log"
 	"time"
 
 	"github.com/akctba/secret-santa-go-api/models"
 )
 
+var (
+	// ErrAlreadyParticipant is returned when a user joins a group they already take part in.
+	ErrAlreadyParticipant = errors.New("user already takes part in the group")
+	// ErrDrawCannotRelink is returned when a participant cannot leave a drawn
+	// group without breaking its draw, so the draw has to be reset first.
+	ErrDrawCannotRelink = errors.New("draw cannot be kept without the participant")
+)
+
+// insertParticipantStmt adds a user to a group as a member, or as its owner
+// when the user created the group.
+const insertParticipantStmt = `INSERT INTO Participants(group_id, user_id, joined_at, role
+	) VALUES (?, ?, ?, CASE WHEN EXISTS (
+		SELECT 1 FROM Groups WHERE group_id = ? AND creator_user_id = ?
+	) THEN 'owner' ELSE 'member' END);`
+
+// InsertParticipant adds a user to a group as a member, or as its owner when the
+// user created the group.
 func InsertParticipant(db *sql.DB, participant models.ParticipantRequest) error {
-	sqlStmt := `INSERT INTO Participants(group_id, user_id, joined_at
-	) VALUES (?, ?, ?);`
-	_, err := db.Exec(sqlStmt, participant.GroupID, participant.UserID, time.Now())
+	_, err := db.Exec(insertParticipantStmt, participant.GroupID, participant.UserID, time.Now(),
+		participant.GroupID, participant.UserID)
 	if err != nil {
+		log.Printf("%q: %s\n", err, insertParticipantStmt)
+		return err
+	}
+	return nil
+}
+
+// joinGroupTx adds a user who is joining on their own, e.g. through an invite.
+// Unlike InsertParticipant it refuses groups that have already been drawn,
+// since a late participant would be left without a secret friend.
+func joinGroupTx(tx *sql.Tx, groupID string, userID int) error {
+	var existing int
+	sqlStmt := `SELECT COUNT(*) FROM Participants WHERE group_id = ? AND user_id = ?;`
+	if err := tx.QueryRow(sqlStmt, groupID, userID).Scan(&existing); err != nil {
 		log.Printf("%q: %s\n", err, sqlStmt)
 		return err
 	}
+	if existing > 0 {
+		return ErrAlreadyParticipant
+	}
+
+	var drawn int
+	sqlStmt = `SELECT COUNT(*) FROM Participants WHERE group_id = ? AND friend_user_id IS NOT NULL;`
+	if err := tx.QueryRow(sqlStmt, groupID).Scan(&drawn); err != nil {
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return err
+	}
+	if drawn > 0 {
+		return ErrDrawExists
+	}
+
+	_, err := tx.Exec(insertParticipantStmt, groupID, userID, time.Now(), groupID, userID)
+	if err != nil {
+		log.Printf("%q: %s\n", err, insertParticipantStmt)
+		return err
+	}
 	return nil
 }
 
@@ -32,6 +81,48 @@ func UpdateParticipant(db *sql.DB, participant models.Participant) error {
 	return nil
 }
 
+// GetGiverUserID returns the participant who drew the user in the group, or
+// sql.ErrNoRows when the group has not been drawn. Never expose the result to
+// the user themselves.
+func GetGiverUserID(db *sql.DB, groupID string, receiverUserID int) (int, error) {
+	var giverUserID int
+	sqlStmt := `SELECT user_id FROM Participants WHERE group_id = ? AND friend_user_id = ?;`
+	err := db.QueryRow(sqlStmt, groupID, receiverUserID).Scan(&giverUserID)
+	return giverUserID, err
+}
+
+// GetParticipantRole returns the role of a user within a group, or
+// sql.ErrNoRows when the user does not take part in it.
+func GetParticipantRole(db *sql.DB, userID int, groupID string) (string, error) {
+	var role string
+	sqlStmt := `SELECT role FROM Participants WHERE user_id = ? AND group_id = ?;`
+	err := db.QueryRow(sqlStmt, userID, groupID).Scan(&role)
+	if err != nil {
+		return role, err
+	}
+	return role, nil
+}
+
+// UpdateParticipantRole promotes or demotes a participant. It returns
+// sql.ErrNoRows when the user does not take part in the group.
+func UpdateParticipantRole(db *sql.DB, userID int, groupID string, role string) error {
+	sqlStmt := `UPDATE Participants SET role = ? WHERE user_id = ? AND group_id = ?;`
+	result, err := db.Exec(sqlStmt, role, userID, groupID)
+	if err != nil {
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
 func DeleteParticipant(db *sql.DB, userId int, groupId int) error {
 	sqlStmt := `DELETE FROM Participants WHERE user_id = ? AND group_id = ?;`
 	_, err := db.Exec(sqlStmt, userId, groupId)
@@ -42,9 +133,114 @@ func DeleteParticipant(db *sql.DB, userId int, groupId int) error {
 	return nil
 }
 
+// RemoveParticipant takes a user out of a group along with the exclusions that
+// mention them. It returns sql.ErrNoRows when the user does not take part in
+// the group.
+//
+// When the group has been drawn, whoever drew the user is given the user's
+// secret friend instead, closing the chain around them; relinked is that new
+// assignment and is left empty for undrawn groups. ErrDrawCannotRelink is
+// returned, and nothing changes, when that would have the giver draw
+// themselves or someone they are excluded from, or, when more than two
+// participants remain, someone who draws them back.
+func RemoveParticipant(db *sql.DB, groupID string, userID int) (models.Participant, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return models.Participant{}, err
+	}
+	defer tx.Rollback()
+
+	relinked, err := removeParticipantTx(tx, groupID, userID)
+	if err != nil {
+		return models.Participant{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.Participant{}, err
+	}
+	return relinked, nil
+}
+
+func removeParticipantTx(tx *sql.Tx, groupID string, userID int) (relinked models.Participant, err error) {
+	var friendUserID sql.NullInt64
+	sqlStmt := `SELECT friend_user_id FROM Participants WHERE group_id = ? AND user_id = ?;`
+	if err := tx.QueryRow(sqlStmt, groupID, userID).Scan(&friendUserID); err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("%q: %s\n", err, sqlStmt)
+		}
+		return relinked, err
+	}
+
+	if friendUserID.Valid {
+		var giverUserID int
+		sqlStmt = `SELECT user_id FROM Participants WHERE group_id = ? AND friend_user_id = ?;`
+		err := tx.QueryRow(sqlStmt, groupID, userID).Scan(&giverUserID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("%q: %s\n", err, sqlStmt)
+			return relinked, err
+		}
+
+		if giverUserID != 0 {
+			newFriendUserID := int(friendUserID.Int64)
+			if giverUserID == newFriendUserID {
+				return relinked, ErrDrawCannotRelink
+			}
+
+			var excluded int
+			sqlStmt = `SELECT COUNT(*) FROM Exclusions WHERE group_id = ? AND (
+				(user_id = ? AND excluded_user_id = ?) OR
+				(one_way = 0 AND user_id = ? AND excluded_user_id = ?));`
+			err := tx.QueryRow(sqlStmt, groupID, giverUserID, newFriendUserID, newFriendUserID, giverUserID).Scan(&excluded)
+			if err != nil {
+				log.Printf("%q: %s\n", err, sqlStmt)
+				return relinked, err
+			}
+			if excluded > 0 {
+				return relinked, ErrDrawCannotRelink
+			}
+
+			// Like the draw itself, groups larger than two must not be left
+			// with two participants drawing each other.
+			var remaining, reciprocal int
+			sqlStmt = `SELECT COUNT(*) - 1, COUNT(CASE WHEN user_id = ? AND friend_user_id = ? THEN 1 END)
+			FROM Participants WHERE group_id = ?;`
+			err = tx.QueryRow(sqlStmt, newFriendUserID, giverUserID, groupID).Scan(&remaining, &reciprocal)
+			if err != nil {
+				log.Printf("%q: %s\n", err, sqlStmt)
+				return relinked, err
+			}
+			if remaining > 2 && reciprocal > 0 {
+				return relinked, ErrDrawCannotRelink
+			}
+
+			sqlStmt = `UPDATE Participants SET friend_user_id = ? WHERE group_id = ? AND user_id = ?;`
+			if _, err := tx.Exec(sqlStmt, newFriendUserID, groupID, giverUserID); err != nil {
+				log.Printf("%q: %s\n", err, sqlStmt)
+				return relinked, err
+			}
+			relinked = models.Participant{GroupID: groupID, UserID: giverUserID, FriendUserID: newFriendUserID}
+		}
+	}
+
+	sqlStmt = `DELETE FROM Exclusions WHERE group_id = ? AND (user_id = ? OR excluded_user_id = ?);`
+	if _, err := tx.Exec(sqlStmt, groupID, userID, userID); err != nil {
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return relinked, err
+	}
+
+	sqlStmt = `DELETE FROM Participants WHERE group_id = ? AND user_id = ?;`
+	if _, err := tx.Exec(sqlStmt, groupID, userID); err != nil {
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return relinked, err
+	}
+
+	return relinked, nil
+}
+
 func GetParticipantByUserID(db *sql.DB, userID string) ([]models.UserParticipant, error) {
 	var participants []models.UserParticipant
-	sqlStmt := `SELECT p.group_id, p.user_id, u.user_name, u.user_email, u.gender, u.date_of_birth, p.joined_at
+	sqlStmt := `SELECT p.group_id, p.user_id, u.user_name, u.user_email, COALESCE(u.gender, ''), u.date_of_birth, p.joined_at, p.role,
+	u.email_verified_at IS NOT NULL
 	FROM Participants p
 	JOIN Users u ON u.user_id = p.user_id
 	WHERE p.user_id = ?;`
@@ -59,7 +255,8 @@ func GetParticipantByUserID(db *sql.DB, userID string) ([]models.UserParticipant
 		var joinedAtValue any
 
 		err := rows.Scan(&participant.GroupID, &participant.UserID, &participant.UserName,
-			&participant.UserEmail, &participant.Gender, &dateOfBirthValue, &joinedAtValue)
+			&participant.UserEmail, &participant.Gender, &dateOfBirthValue, &joinedAtValue, &participant.Role,
+			&participant.EmailVerified)
 		if err != nil {
 			return participants, err
 		}
@@ -83,10 +280,12 @@ func GetParticipantByUserID(db *sql.DB, userID string) ([]models.UserParticipant
 
 func GetParticipantsByGroupID(db *sql.DB, id string) ([]models.UserParticipant, error) {
 	var participants []models.UserParticipant
-	sqlStmt := `SELECT p.group_id, u.user_id, u.user_name, u.user_email, u.gender, u.date_of_birth, p.joined_at
+	sqlStmt := `SELECT p.group_id, u.user_id, u.user_name, u.user_email, COALESCE(u.gender, ''), u.date_of_birth, p.joined_at, p.role,
+	u.email_verified_at IS NOT NULL
 	FROM Users u
 	JOIN Participants p ON u.user_id = p.user_id
-	WHERE p.group_id = ?;`
+	WHERE p.group_id = ?
+	ORDER BY p.joined_at, u.user_id;`
 	rows, err := db.Query(sqlStmt, id)
 	if err != nil {
 		log.Printf("%q: %s\n", err, sqlStmt)
@@ -99,7 +298,8 @@ func GetParticipantsByGroupID(db *sql.DB, id string) ([]models.UserParticipant,
 		var joinedAtValue any
 
 		err := rows.Scan(&participant.GroupID, &participant.UserID, &participant.UserName,
-			&participant.UserEmail, &participant.Gender, &dateOfBirthValue, &joinedAtValue)
+			&participant.UserEmail, &participant.Gender, &dateOfBirthValue, &joinedAtValue, &participant.Role,
+			&participant.EmailVerified)
 		if err != nil {
 			return participants, err
 		}
@@ -121,11 +321,13 @@ func GetParticipantsByGroupID(db *sql.DB, id string) ([]models.UserParticipant,
 	return participants, nil
 }
 
+// GetParticipantsToDraw returns every participant of the group, including any
+// existing assignment, so callers can refuse to draw an already drawn group.
 func GetParticipantsToDraw(db *sql.DB, groupID string) ([]models.Participant, error) {
 	var participants []models.Participant
-	sqlStmt := `SELECT p.group_id, p.user_id, p.joined_at
+	sqlStmt := `SELECT p.group_id, p.user_id, p.joined_at, p.friend_user_id
 	FROM Participants p
-	WHERE p.group_id = ? AND p.friend_user_id IS NULL;`
+	WHERE p.group_id = ?;`
 	rows, err := db.Query(sqlStmt, groupID)
 	if err != nil {
 		return participants, err
@@ -134,12 +336,15 @@ func GetParticipantsToDraw(db *sql.DB, groupID string) ([]models.Participant, er
 	for rows.Next() {
 		var participant models.Participant
 		var joinedAtValue any
+		var friendUserID sql.NullInt64
 
-		err := rows.Scan(&participant.GroupID, &participant.UserID, &joinedAtValue)
+		err := rows.Scan(&participant.GroupID, &participant.UserID, &joinedAtValue, &friendUserID)
 		if err != nil {
 			return participants, err
 		}
 
+		participant.FriendUserID = int(friendUserID.Int64)
+
 		participant.JoinedAt, err = parseDBTime(joinedAtValue)
 		if err != nil {
 			return participants, err
@@ -0,0 +1,33 @@
+package database
+
+//this file will contain all the database operations for password reset tokens
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ErrResetTokenInvalid is returned for unknown, expired and already used
+// password reset tokens.
+var ErrResetTokenInvalid = errors.New("password reset token is invalid or has expired")
+
+// InsertPasswordResetToken stores a reset token for the user.
+func InsertPasswordResetToken(db *sql.DB, userID int, tokenHash string, expiresAt time.Time) error {
+	return insertEmailToken(db, passwordResetTokens, userID, tokenHash, expiresAt)
+}
+
+// ResetPassword uses up the reset token with the given hash and sets the
+// user's new password hash. Every other outstanding reset token of the user
+// is used up too, and all of the user's refresh tokens are revoked.
+// It returns the user's ID, or ErrResetTokenInvalid.
+func ResetPassword(db *sql.DB, tokenHash string, passwordHash string, now time.Time) (int, error) {
+	return consumeEmailToken(db, passwordResetTokens, tokenHash, now, ErrResetTokenInvalid,
+		func(tx *sql.Tx, userID int) error {
+			err := changeUserPasswordTx(tx, userID, passwordHash, now)
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrResetTokenInvalid
+			}
+			return err
+		})
+}
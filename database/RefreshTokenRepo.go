@@ -0,0 +1,174 @@
+package database
+
+//this file will contain all the database operations for refresh tokens
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/akctba/secret-santa-go-api/models"
+)
+
+var (
+	// ErrRefreshTokenInvalid is returned for unknown, expired and revoked
+	// refresh tokens.
+	ErrRefreshTokenInvalid = errors.New("refresh token is invalid, expired or revoked")
+	// ErrRefreshTokenReused is returned when a refresh token that was already
+	// rotated is presented again. Its whole family has been revoked by then.
+	ErrRefreshTokenReused = errors.New("refresh token has already been used")
+)
+
+// InsertRefreshToken stores a newly issued refresh token.
+func InsertRefreshToken(db *sql.DB, token models.RefreshToken) error {
+	sqlStmt := `INSERT INTO RefreshTokens(token_id, user_id, family_id, issued_at, expires_at
+	) VALUES (?, ?, ?, ?, ?);`
+	_, err := db.Exec(sqlStmt, token.TokenID, token.UserID, token.FamilyID, token.IssuedAt.UTC(), token.ExpiresAt.UTC())
+	if err != nil {
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return err
+	}
+	return nil
+}
+
+// RotateRefreshToken swaps the refresh token with the given ID for next,
+// which must belong to the same user and family. A token can only be rotated
+// once: presenting it again revokes every token in its family and returns
+// ErrRefreshTokenReused, since either the client or someone who stole the
+// token is using an old copy. Unknown, expired and revoked tokens return
+// ErrRefreshTokenInvalid.
+func RotateRefreshToken(db *sql.DB, tokenID string, next models.RefreshToken, now time.Time) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	sqlStmt := `SELECT token_id, user_id, family_id, issued_at, expires_at, rotated_at, revoked_at
+	FROM RefreshTokens WHERE token_id = ?;`
+	current, err := scanRefreshToken(tx.QueryRow(sqlStmt, tokenID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrRefreshTokenInvalid
+		}
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return err
+	}
+
+	if current.UserID != next.UserID || current.FamilyID != next.FamilyID {
+		return ErrRefreshTokenInvalid
+	}
+	if current.RevokedAt != nil || !now.Before(current.ExpiresAt) {
+		return ErrRefreshTokenInvalid
+	}
+	if current.RotatedAt != nil {
+		if err := revokeRefreshTokenFamilyTx(tx, current.FamilyID, now); err != nil {
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		return ErrRefreshTokenReused
+	}
+
+	sqlStmt = `UPDATE RefreshTokens SET rotated_at = ? WHERE token_id = ? AND rotated_at IS NULL;`
+	result, err := tx.Exec(sqlStmt, now.UTC(), tokenID)
+	if err != nil {
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return err
+	}
+	rotated, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rotated == 0 {
+		return ErrRefreshTokenReused
+	}
+
+	sqlStmt = `INSERT INTO RefreshTokens(token_id, user_id, family_id, issued_at, expires_at
+	) VALUES (?, ?, ?, ?, ?);`
+	_, err = tx.Exec(sqlStmt, next.TokenID, next.UserID, next.FamilyID, next.IssuedAt.UTC(), next.ExpiresAt.UTC())
+	if err != nil {
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RevokeRefreshTokenFamily revokes every token in the family, ending the
+// session they belong to.
+func RevokeRefreshTokenFamily(db *sql.DB, familyID string, now time.Time) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := revokeRefreshTokenFamilyTx(tx, familyID, now); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RevokeUserRefreshTokens revokes every refresh token of the user, ending all
+// of their sessions.
+func RevokeUserRefreshTokens(db *sql.DB, userID int, now time.Time) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := revokeUserRefreshTokensTx(tx, userID, now); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func revokeRefreshTokenFamilyTx(tx *sql.Tx, familyID string, now time.Time) error {
+	sqlStmt := `UPDATE RefreshTokens SET revoked_at = ? WHERE family_id = ? AND revoked_at IS NULL;`
+	if _, err := tx.Exec(sqlStmt, now.UTC(), familyID); err != nil {
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return err
+	}
+	return nil
+}
+
+func revokeUserRefreshTokensTx(tx *sql.Tx, userID int, now time.Time) error {
+	sqlStmt := `UPDATE RefreshTokens SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL;`
+	if _, err := tx.Exec(sqlStmt, now.UTC(), userID); err != nil {
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return err
+	}
+	return nil
+}
+
+func scanRefreshToken(row rowScanner) (models.RefreshToken, error) {
+	var token models.RefreshToken
+	var issuedAtValue, expiresAtValue any
+	var rotatedAt, revokedAt sql.NullTime
+
+	err := row.Scan(&token.TokenID, &token.UserID, &token.FamilyID, &issuedAtValue, &expiresAtValue,
+		&rotatedAt, &revokedAt)
+	if err != nil {
+		return token, err
+	}
+
+	token.IssuedAt, err = parseDBTime(issuedAtValue)
+	if err != nil {
+		return token, err
+	}
+	token.ExpiresAt, err = parseDBTime(expiresAtValue)
+	if err != nil {
+		return token, err
+	}
+	if rotatedAt.Valid {
+		token.RotatedAt = &rotatedAt.Time
+	}
+	if revokedAt.Valid {
+		token.RevokedAt = &revokedAt.Time
+	}
+	return token, nil
+}
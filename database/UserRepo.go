@@ -2,53 +2,108 @@ package database
 
 import (
 	"database/sql"
+	"errors"
 	"log"
+	"time"
 
 	"github.com/akctba/secret-santa-go-api/models"
 )
 
 //this file will contain all the database operations for the User model
 
-func InsertUser(db *sql.DB, user models.User) error {
-	sqlStmt := `INSERT INTO Users(user_name, user_email, password
+var (
+	// ErrEmailTaken is returned when registering an email address that is
+	// already in use.
+	ErrEmailTaken = errors.New("email address is already registered")
+	// ErrUserOwnsGroups is returned when deleting a user who still owns groups.
+	ErrUserOwnsGroups = errors.New("user still owns groups")
+)
+
+// emailFreeCondition guards inserts into Users with the email address as its
+// last parameter. The unique index on user_email is only added once legacy
+// duplicates are merged, so until then this is what keeps new duplicates out.
+const emailFreeCondition = `WHERE NOT EXISTS (SELECT 1 FROM Users WHERE user_email = ? COLLATE NOCASE)`
 
-	) VALUES (?, ?, ?);`
-	_, err := db.Exec(sqlStmt, user.UserName, user.UserEmail, user.Password)
+// InsertUser registers the user. Email addresses are expected to be
+// normalized already; it returns ErrEmailTaken when the address is in use.
+func InsertUser(db *sql.DB, user *models.User) error {
+	if user == nil {
+		return errors.New("user is nil")
+	}
+
+	sqlStmt := `INSERT INTO Users(user_name, user_email, password)
+	SELECT ?, ?, ? ` + emailFreeCondition + `;`
+	result, err := db.Exec(sqlStmt, user.UserName, user.UserEmail, user.Password, user.UserEmail)
 	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrEmailTaken
+		}
 		log.Printf("%q: %s\n", err, sqlStmt)
 		return err
 	}
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if inserted == 0 {
+		return ErrEmailTaken
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	user.UserID = int(id)
+
 	return nil
 }
 
-func GetUserByEmail(db *sql.DB, email string) (models.User, error) {
+// selectUserStmt reads every column scanUser expects.
+const selectUserStmt = `SELECT user_id, user_name, user_email, password, COALESCE(gender, ''), date_of_birth,
+	email_verified_at FROM Users`
+
+func scanUser(row rowScanner) (models.User, error) {
 	var user models.User
-	sqlStmt := `SELECT user_id, user_name, user_email, password
-	FROM Users WHERE user_email = ?;`
-	row := db.QueryRow(sqlStmt, email)
-	err := row.Scan(&user.UserID, &user.UserName, &user.UserEmail, &user.Password)
+	var dateOfBirthValue any
+	var emailVerifiedAt sql.NullTime
+
+	err := row.Scan(&user.UserID, &user.UserName, &user.UserEmail, &user.Password, &user.Gender, &dateOfBirthValue,
+		&emailVerifiedAt)
 	if err != nil {
 		return user, err
 	}
-	return user, nil
-}
 
-func GetUserByID(db *sql.DB, id int) (models.User, error) {
-	var user models.User
-	sqlStmt := `SELECT user_id, user_name, user_email, password
-	FROM Users WHERE user_id = ?;`
-	row := db.QueryRow(sqlStmt, id)
-	err := row.Scan(&user.UserID, &user.UserName, &user.UserEmail, &user.Password)
+	user.DateOfBirth, err = parseDBTime(dateOfBirthValue)
 	if err != nil {
 		return user, err
 	}
+	if emailVerifiedAt.Valid {
+		user.EmailVerifiedAt = &emailVerifiedAt.Time
+	}
 	return user, nil
 }
 
+// GetUserByEmail looks the user up by email address, ignoring case. While
+// legacy accounts still share an address, the oldest of them is returned.
+func GetUserByEmail(db *sql.DB, email string) (models.User, error) {
+	sqlStmt := selectUserStmt + ` WHERE user_email = ? COLLATE NOCASE
+	ORDER BY user_id LIMIT 1;`
+	return scanUser(db.QueryRow(sqlStmt, email))
+}
+
+func GetUserByID(db *sql.DB, id int) (models.User, error) {
+	sqlStmt := selectUserStmt + ` WHERE user_id = ?;`
+	return scanUser(db.QueryRow(sqlStmt, id))
+}
+
+// UpdateUser saves the user's profile and password. A zero date of birth is
+// stored as unknown.
 func UpdateUser(db *sql.DB, user models.User) error {
-	sqlStmt := `UPDATE Users SET user_name = ?, user_email = ?, password = ?
+	sqlStmt := `UPDATE Users SET user_name = ?, user_email = ?, password = ?, gender = ?, date_of_birth = ?
 	WHERE user_id = ?;`
-	_, err := db.Exec(sqlStmt, user.UserName, user.UserEmail, user.Password, user.UserID)
+	_, err := db.Exec(sqlStmt, user.UserName, user.UserEmail, user.Password, user.Gender,
+		sql.NullTime{Time: user.DateOfBirth, Valid: !user.DateOfBirth.IsZero()}, user.UserID)
 	if err != nil {
 		log.Printf("%q: %s\n", err, sqlStmt)
 		return err
@@ -56,6 +111,117 @@ func UpdateUser(db *sql.DB, user models.User) error {
 	return nil
 }
 
+// ChangeUserPassword sets the user's new password hash and revokes all of the
+// user's refresh tokens, so sessions started with the old password end. It
+// returns sql.ErrNoRows when the user does not exist.
+func ChangeUserPassword(db *sql.DB, userID int, passwordHash string, now time.Time) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := changeUserPasswordTx(tx, userID, passwordHash, now); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func changeUserPasswordTx(tx *sql.Tx, userID int, passwordHash string, now time.Time) error {
+	sqlStmt := `UPDATE Users SET password = ? WHERE user_id = ?;`
+	result, err := tx.Exec(sqlStmt, passwordHash, userID)
+	if err != nil {
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return err
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		return sql.ErrNoRows
+	}
+
+	return revokeUserRefreshTokensTx(tx, userID, now)
+}
+
+// DeleteUserAccount deletes the user together with their wishlists and the
+// messages they exchanged. They are taken out of every group they take part
+// in the way RemoveParticipant does; relinked lists the new assignments made
+// in drawn groups. It returns ErrUserOwnsGroups while the user still owns a
+// group, and ErrDrawCannotRelink when one of the draws cannot be kept; in
+// both cases nothing is deleted.
+func DeleteUserAccount(db *sql.DB, userID int) (relinked []models.Participant, err error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var owned int
+	sqlStmt := `SELECT COUNT(*) FROM Groups WHERE creator_user_id = ?;`
+	if err := tx.QueryRow(sqlStmt, userID).Scan(&owned); err != nil {
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return nil, err
+	}
+	if owned > 0 {
+		return nil, ErrUserOwnsGroups
+	}
+
+	var groupIDs []string
+	sqlStmt = `SELECT group_id FROM Participants WHERE user_id = ? ORDER BY group_id;`
+	rows, err := tx.Query(sqlStmt, userID)
+	if err != nil {
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return nil, err
+	}
+	for rows.Next() {
+		var groupID string
+		if err := rows.Scan(&groupID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		groupIDs = append(groupIDs, groupID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, groupID := range groupIDs {
+		participant, err := removeParticipantTx(tx, groupID, userID)
+		if err != nil {
+			return nil, err
+		}
+		if participant.UserID != 0 {
+			relinked = append(relinked, participant)
+		}
+	}
+
+	for _, sqlStmt := range []string{
+		`DELETE FROM WishlistItems WHERE wishlist_id IN (SELECT wishlist_id FROM Wishlists WHERE user_id = ?);`,
+		`DELETE FROM Wishlists WHERE user_id = ?;`,
+		`DELETE FROM Messages WHERE giver_user_id = ?1 OR receiver_user_id = ?1;`,
+		`DELETE FROM PasswordResetTokens WHERE user_id = ?;`,
+		`DELETE FROM EmailVerificationTokens WHERE user_id = ?;`,
+		`DELETE FROM MagicLinkTokens WHERE user_id = ?;`,
+		`DELETE FROM RefreshTokens WHERE user_id = ?;`,
+		`DELETE FROM UserIdentities WHERE user_id = ?;`,
+		`DELETE FROM Users WHERE user_id = ?;`,
+	} {
+		if _, err := tx.Exec(sqlStmt, userID); err != nil {
+			log.Printf("%q: %s\n", err, sqlStmt)
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return relinked, nil
+}
+
 func DeleteUser(db *sql.DB, id int) error {
 	sqlStmt := `DELETE FROM Users WHERE user_id = ?;`
 	_, err := db.Exec(sqlStmt, id)
@@ -88,12 +254,13 @@ func GetAllUsers(db *sql.DB) ([]models.User, error) {
 	return users, nil
 }
 
+// GetUserGroups returns the groups the user takes part in.
 func GetUserGroups(db *sql.DB, id int) ([]models.Group, error) {
-	var groups []models.Group
-	sqlStmt := `SELECT g.group_id, g.name, g.date_created, g.date_draw, g.creator_user_id
-	FROM Groups g
+	groups := []models.Group{}
+	sqlStmt := selectGroupStmt + `
 	JOIN Participants p ON g.group_id = p.group_id
-	WHERE p.user_id = ?;`
+	WHERE p.user_id = ?
+	ORDER BY g.group_id;`
 	rows, err := db.Query(sqlStmt, id)
 	if err != nil {
 		log.Printf("%q: %s\n", err, sqlStmt)
@@ -102,8 +269,7 @@ func GetUserGroups(db *sql.DB, id int) ([]models.Group, error) {
 	defer rows.Close()
 
 	for rows.Next() {
-		var group models.Group
-		err = rows.Scan(&group.GroupID, &group.Name, &group.DateCreated, &group.DateDraw, &group.CreatorUserID)
+		group, err := scanGroup(rows)
 		if err != nil {
 			log.Printf("%q: %s\n", err, sqlStmt)
 			return groups, err
@@ -0,0 +1,309 @@
+package database
+
+//this file will contain all the database operations for the Wishlist model
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/akctba/secret-santa-go-api/models"
+)
+
+// ErrWishlistExists is returned when the user already has a wishlist for the same group,
+// or a general wishlist when no group is given.
+var ErrWishlistExists = errors.New("wishlist already exists")
+
+const selectWishlistStmt = `SELECT wishlist_id, user_id, group_id, title, date_created FROM Wishlists`
+
+func InsertWishlist(db *sql.DB, wishlist *models.Wishlist) error {
+	if wishlist == nil {
+		return errors.New("wishlist is nil")
+	}
+
+	wishlist.DateCreated = time.Now().UTC()
+
+	sqlStmt := `INSERT INTO Wishlists(user_id, group_id, title, date_created) VALUES (?, ?, ?, ?);`
+	result, err := db.Exec(sqlStmt, wishlist.UserID, nullableGroupID(wishlist.GroupID), wishlist.Title,
+		wishlist.DateCreated)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrWishlistExists
+		}
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	wishlist.WishlistID = int(id)
+	wishlist.Items = []models.WishlistItem{}
+
+	return nil
+}
+
+// GetWishlist returns one of the user's wishlists with its items. It returns
+// sql.ErrNoRows when the wishlist does not exist or belongs to someone else.
+func GetWishlist(db *sql.DB, userID int, wishlistID int) (models.Wishlist, error) {
+	sqlStmt := selectWishlistStmt + ` WHERE user_id = ? AND wishlist_id = ?;`
+	wishlist, err := scanWishlist(db.QueryRow(sqlStmt, userID, wishlistID))
+	if err != nil {
+		return wishlist, err
+	}
+
+	wishlist.Items, err = getWishlistItems(db, wishlist.WishlistID)
+	return wishlist, err
+}
+
+// GetWishlistForGroup returns the wishlist the user keeps for the group, falling
+// back to their general wishlist. It returns sql.ErrNoRows when there is neither.
+func GetWishlistForGroup(db *sql.DB, userID int, groupID string) (models.Wishlist, error) {
+	sqlStmt := selectWishlistStmt + ` WHERE user_id = ? AND (group_id = ? OR group_id IS NULL)
+	ORDER BY group_id IS NULL LIMIT 1;`
+	wishlist, err := scanWishlist(db.QueryRow(sqlStmt, userID, groupID))
+	if err != nil {
+		return wishlist, err
+	}
+
+	wishlist.Items, err = getWishlistItems(db, wishlist.WishlistID)
+	return wishlist, err
+}
+
+func GetWishlistsByUserID(db *sql.DB, userID int) ([]models.Wishlist, error) {
+	wishlists := []models.Wishlist{}
+	sqlStmt := selectWishlistStmt + ` WHERE user_id = ?
+	ORDER BY wishlist_id;`
+	rows, err := db.Query(sqlStmt, userID)
+	if err != nil {
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return wishlists, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		wishlist, err := scanWishlist(rows)
+		if err != nil {
+			return wishlists, err
+		}
+		wishlists = append(wishlists, wishlist)
+	}
+	if err := rows.Err(); err != nil {
+		return wishlists, err
+	}
+	rows.Close()
+
+	for i := range wishlists {
+		wishlists[i].Items, err = getWishlistItems(db, wishlists[i].WishlistID)
+		if err != nil {
+			return wishlists, err
+		}
+	}
+	return wishlists, nil
+}
+
+// UpdateWishlistTitle renames one of the user's wishlists. It returns
+// sql.ErrNoRows when the user has no such wishlist.
+func UpdateWishlistTitle(db *sql.DB, userID int, wishlistID int, title string) error {
+	sqlStmt := `UPDATE Wishlists SET title = ? WHERE user_id = ? AND wishlist_id = ?;`
+	result, err := db.Exec(sqlStmt, title, userID, wishlistID)
+	if err != nil {
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DeleteWishlist removes one of the user's wishlists together with its items.
+// It returns sql.ErrNoRows when the user has no such wishlist.
+func DeleteWishlist(db *sql.DB, userID int, wishlistID int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	sqlStmt := `DELETE FROM Wishlists WHERE user_id = ? AND wishlist_id = ?;`
+	result, err := tx.Exec(sqlStmt, userID, wishlistID)
+	if err != nil {
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	sqlStmt = `DELETE FROM WishlistItems WHERE wishlist_id = ?;`
+	if _, err := tx.Exec(sqlStmt, wishlistID); err != nil {
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func InsertWishlistItem(db *sql.DB, item *models.WishlistItem) error {
+	if item == nil {
+		return errors.New("wishlist item is nil")
+	}
+
+	item.DateCreated = time.Now().UTC()
+
+	sqlStmt := `INSERT INTO WishlistItems(wishlist_id, title, url, price_min, price_max, priority, notes, date_created
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?);`
+	result, err := db.Exec(sqlStmt, item.WishlistID, item.Title, item.URL, item.PriceMin, item.PriceMax,
+		item.Priority, item.Notes, item.DateCreated)
+	if err != nil {
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	item.ItemID = int(id)
+
+	return nil
+}
+
+// UpdateWishlistItem replaces the editable fields of an item and reloads it.
+// It returns sql.ErrNoRows when the wishlist has no such item.
+func UpdateWishlistItem(db *sql.DB, item *models.WishlistItem) error {
+	if item == nil {
+		return errors.New("wishlist item is nil")
+	}
+
+	sqlStmt := `UPDATE WishlistItems SET title = ?, url = ?, price_min = ?, price_max = ?, priority = ?, notes = ?
+	WHERE wishlist_id = ? AND item_id = ?;`
+	result, err := db.Exec(sqlStmt, item.Title, item.URL, item.PriceMin, item.PriceMax, item.Priority, item.Notes,
+		item.WishlistID, item.ItemID)
+	if err != nil {
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	sqlStmt = `SELECT item_id, wishlist_id, title, url, price_min, price_max, priority, notes, date_created
+	FROM WishlistItems WHERE item_id = ?;`
+	updated, err := scanWishlistItem(db.QueryRow(sqlStmt, item.ItemID))
+	if err != nil {
+		return err
+	}
+
+	*item = updated
+	return nil
+}
+
+// DeleteWishlistItem returns sql.ErrNoRows when the wishlist has no such item.
+func DeleteWishlistItem(db *sql.DB, wishlistID int, itemID int) error {
+	sqlStmt := `DELETE FROM WishlistItems WHERE wishlist_id = ? AND item_id = ?;`
+	result, err := db.Exec(sqlStmt, wishlistID, itemID)
+	if err != nil {
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// getWishlistItems returns the items of a wishlist, most wanted first.
+func getWishlistItems(db *sql.DB, wishlistID int) ([]models.WishlistItem, error) {
+	items := []models.WishlistItem{}
+	sqlStmt := `SELECT item_id, wishlist_id, title, url, price_min, price_max, priority, notes, date_created
+	FROM WishlistItems WHERE wishlist_id = ?
+	ORDER BY priority, item_id;`
+	rows, err := db.Query(sqlStmt, wishlistID)
+	if err != nil {
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return items, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		item, err := scanWishlistItem(rows)
+		if err != nil {
+			return items, err
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return items, err
+	}
+	return items, nil
+}
+
+func scanWishlist(row rowScanner) (models.Wishlist, error) {
+	var wishlist models.Wishlist
+	var groupID sql.NullString
+	var dateCreatedValue any
+
+	err := row.Scan(&wishlist.WishlistID, &wishlist.UserID, &groupID, &wishlist.Title, &dateCreatedValue)
+	if err != nil {
+		return wishlist, err
+	}
+
+	wishlist.GroupID = groupID.String
+	wishlist.DateCreated, err = parseDBTime(dateCreatedValue)
+	if err != nil {
+		return wishlist, err
+	}
+
+	return wishlist, nil
+}
+
+func scanWishlistItem(row rowScanner) (models.WishlistItem, error) {
+	var item models.WishlistItem
+	var url, notes sql.NullString
+	var dateCreatedValue any
+
+	err := row.Scan(&item.ItemID, &item.WishlistID, &item.Title, &url, &item.PriceMin, &item.PriceMax,
+		&item.Priority, &notes, &dateCreatedValue)
+	if err != nil {
+		return item, err
+	}
+
+	item.URL = url.String
+	item.Notes = notes.String
+	item.DateCreated, err = parseDBTime(dateCreatedValue)
+	if err != nil {
+		return item, err
+	}
+
+	return item, nil
+}
+
+// nullableGroupID stores an empty group ID as NULL.
+func nullableGroupID(groupID string) sql.NullString {
+	return sql.NullString{String: groupID, Valid: groupID != ""}
+}
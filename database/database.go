@@ -2,18 +2,28 @@ package database
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
 	"log"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
 )
 
 const (
 	DbDriver = "sqlite3"
 	DbName   = "secretsanta.db"
+
+	// dbDSN waits for locks instead of failing immediately, since several
+	// instances and the draw scheduler may write to the database at once.
+	dbDSN = DbName + "?_busy_timeout=5000"
 )
 
 func CreateTables() {
 	// Connect to the database
 	// Open a connection to the SQLite database
-	db, err := sql.Open(DbDriver, DbName)
+	db, err := sql.Open(DbDriver, dbDSN)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -57,6 +67,7 @@ func CreateTables() {
 		user_id INTEGER,
 		joined_at TEXT,
 		friend_user_id INTEGER,
+		role TEXT NOT NULL DEFAULT 'member',
 		PRIMARY KEY (group_id, user_id)
 	);
 	`
@@ -66,9 +77,395 @@ func CreateTables() {
 		return
 	}
 
+	sqlStmt = `
+	CREATE TABLE IF NOT EXISTS Exclusions (
+		exclusion_id INTEGER PRIMARY KEY AUTOINCREMENT,
+		group_id INTEGER,
+		user_id INTEGER,
+		excluded_user_id INTEGER,
+		one_way INTEGER NOT NULL DEFAULT 0,
+		date_created DATETIME
+	);
+	`
+	_, err = db.Exec(sqlStmt)
+	if err != nil {
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return
+	}
+
+	sqlStmt = `
+	CREATE TABLE IF NOT EXISTS Draws (
+		draw_id INTEGER PRIMARY KEY AUTOINCREMENT,
+		group_id INTEGER,
+		run_at DATETIME,
+		run_by INTEGER,
+		algorithm TEXT,
+		idempotency_key TEXT,
+		reset_at DATETIME,
+		reset_by INTEGER
+	);
+	`
+	_, err = db.Exec(sqlStmt)
+	if err != nil {
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return
+	}
+
+	sqlStmt = `
+	CREATE TABLE IF NOT EXISTS GroupInvites (
+		invite_id INTEGER PRIMARY KEY AUTOINCREMENT,
+		group_id INTEGER,
+		code TEXT NOT NULL UNIQUE,
+		created_by INTEGER,
+		date_created DATETIME,
+		expires_at DATETIME,
+		max_uses INTEGER NOT NULL DEFAULT 0,
+		uses INTEGER NOT NULL DEFAULT 0,
+		revoked_at DATETIME
+	);
+	`
+	_, err = db.Exec(sqlStmt)
+	if err != nil {
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return
+	}
+
+	sqlStmt = `
+	CREATE TABLE IF NOT EXISTS EmailInvitations (
+		invitation_id INTEGER PRIMARY KEY AUTOINCREMENT,
+		group_id INTEGER,
+		email TEXT NOT NULL,
+		invited_by INTEGER,
+		date_created DATETIME,
+		user_id INTEGER,
+		accepted_at DATETIME
+	);
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_email_invitations_group_email ON EmailInvitations(group_id, email);
+	`
+	_, err = db.Exec(sqlStmt)
+	if err != nil {
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return
+	}
+
+	sqlStmt = `
+	CREATE TABLE IF NOT EXISTS Wishlists (
+		wishlist_id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		group_id INTEGER,
+		title TEXT,
+		date_created DATETIME
+	);
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_wishlists_user_group ON Wishlists(user_id, IFNULL(group_id, 0));
+	CREATE TABLE IF NOT EXISTS WishlistItems (
+		item_id INTEGER PRIMARY KEY AUTOINCREMENT,
+		wishlist_id INTEGER NOT NULL,
+		title TEXT,
+		url TEXT,
+		price_min REAL NOT NULL DEFAULT 0,
+		price_max REAL NOT NULL DEFAULT 0,
+		priority INTEGER NOT NULL DEFAULT 3,
+		notes TEXT,
+		date_created DATETIME
+	);
+	`
+	_, err = db.Exec(sqlStmt)
+	if err != nil {
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return
+	}
+
+	sqlStmt = `
+	CREATE TABLE IF NOT EXISTS Messages (
+		message_id INTEGER PRIMARY KEY AUTOINCREMENT,
+		group_id INTEGER,
+		draw_id INTEGER NOT NULL DEFAULT 0,
+		giver_user_id INTEGER,
+		receiver_user_id INTEGER,
+		sender TEXT NOT NULL,
+		body TEXT,
+		date_created DATETIME
+	);
+	CREATE INDEX IF NOT EXISTS idx_messages_thread ON Messages(group_id, draw_id, giver_user_id, receiver_user_id);
+	`
+	_, err = db.Exec(sqlStmt)
+	if err != nil {
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return
+	}
+
+	// The single-use tokens sent by email share their layout, see EmailTokenRepo.go.
+	for _, table := range []emailTokenTable{passwordResetTokens, emailVerificationTokens, magicLinkTokens} {
+		sqlStmt = `
+	CREATE TABLE IF NOT EXISTS ` + string(table) + ` (
+		token_id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		token_hash TEXT NOT NULL UNIQUE,
+		date_created DATETIME,
+		expires_at DATETIME NOT NULL,
+		used_at DATETIME
+	);
+	`
+		_, err = db.Exec(sqlStmt)
+		if err != nil {
+			log.Printf("%q: %s\n", err, sqlStmt)
+			return
+		}
+	}
+
+	sqlStmt = `
+	CREATE TABLE IF NOT EXISTS RefreshTokens (
+		token_id TEXT PRIMARY KEY,
+		user_id INTEGER NOT NULL,
+		family_id TEXT NOT NULL,
+		issued_at DATETIME NOT NULL,
+		expires_at DATETIME NOT NULL,
+		rotated_at DATETIME,
+		revoked_at DATETIME
+	);
+	CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family ON RefreshTokens(family_id);
+	CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user ON RefreshTokens(user_id);
+	`
+	_, err = db.Exec(sqlStmt)
+	if err != nil {
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return
+	}
+
+	sqlStmt = `
+	CREATE TABLE IF NOT EXISTS UserIdentities (
+		identity_id INTEGER PRIMARY KEY AUTOINCREMENT,
+		provider TEXT NOT NULL,
+		subject TEXT NOT NULL,
+		user_id INTEGER NOT NULL,
+		date_created DATETIME,
+		UNIQUE (provider, subject)
+	);
+	CREATE INDEX IF NOT EXISTS idx_user_identities_user ON UserIdentities(user_id);
+	`
+	_, err = db.Exec(sqlStmt)
+	if err != nil {
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return
+	}
+
+	sqlStmt = `
+	CREATE TABLE IF NOT EXISTS OIDCSignins (
+		state_hash TEXT PRIMARY KEY,
+		provider TEXT NOT NULL,
+		nonce TEXT NOT NULL,
+		code_verifier TEXT NOT NULL,
+		expires_at DATETIME NOT NULL
+	);
+	`
+	_, err = db.Exec(sqlStmt)
+	if err != nil {
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return
+	}
+
 	if err := ensureParticipantFriendColumn(db); err != nil {
 		log.Printf("ensure participant friend_user_id column: %v\n", err)
 	}
+
+	if err := ensureDrawHistory(db); err != nil {
+		log.Printf("ensure draw history: %v\n", err)
+	}
+
+	if err := ensureParticipantRoles(db); err != nil {
+		log.Printf("ensure participant roles: %v\n", err)
+	}
+
+	if err := ensureAutoDrawColumns(db); err != nil {
+		log.Printf("ensure auto draw columns: %v\n", err)
+	}
+
+	if err := ensureGroupBudgetColumns(db); err != nil {
+		log.Printf("ensure group budget columns: %v\n", err)
+	}
+
+	if err := ensureGroupEventColumns(db); err != nil {
+		log.Printf("ensure group event columns: %v\n", err)
+	}
+
+	if err := ensureUTCDrawDates(db); err != nil {
+		log.Printf("ensure UTC draw dates: %v\n", err)
+	}
+
+	if err := ensureUniqueUserEmails(db); err != nil {
+		log.Printf("ensure unique user emails: %v\n", err)
+	}
+
+	// Accounts created before verification existed start out unverified.
+	if err := ensureColumn(db, "Users", "email_verified_at", "DATETIME"); err != nil {
+		log.Printf("ensure user email_verified_at column: %v\n", err)
+	}
+}
+
+// ensureUniqueUserEmails lowercases stored email addresses and adds a unique
+// index on them. Accounts registered before the index may share an address
+// that differs only in case; those are left alone and reported, since merging
+// accounts is a decision for an operator, and the index is added once they are
+// resolved.
+func ensureUniqueUserEmails(db *sql.DB) error {
+	rows, err := db.Query(`SELECT LOWER(TRIM(user_email)), GROUP_CONCAT(user_id, ', ')
+	FROM Users GROUP BY LOWER(TRIM(user_email)) HAVING COUNT(*) > 1 ORDER BY 1;`)
+	if err != nil {
+		return err
+	}
+	var duplicates []string
+	for rows.Next() {
+		var email, userIDs string
+		if err := rows.Scan(&email, &userIDs); err != nil {
+			rows.Close()
+			return err
+		}
+		duplicates = append(duplicates, fmt.Sprintf("%s (users %s)", email, userIDs))
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	_, err = db.Exec(`UPDATE Users SET user_email = LOWER(TRIM(user_email))
+	WHERE user_email <> LOWER(TRIM(user_email)) AND LOWER(TRIM(user_email)) NOT IN (
+		SELECT LOWER(TRIM(user_email)) FROM Users GROUP BY LOWER(TRIM(user_email)) HAVING COUNT(*) > 1
+	);`)
+	if err != nil {
+		return err
+	}
+
+	if len(duplicates) > 0 {
+		return fmt.Errorf("email addresses shared by several users, merge them to enforce unique emails: %s",
+			strings.Join(duplicates, "; "))
+	}
+
+	_, err = db.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email ON Users(user_email COLLATE NOCASE);`)
+	return err
+}
+
+// ensureGroupBudgetColumns adds the gift budget. A budget_max of 0 means no upper limit.
+func ensureGroupBudgetColumns(db *sql.DB) error {
+	if err := ensureColumn(db, "Groups", "budget_min", "REAL NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := ensureColumn(db, "Groups", "budget_max", "REAL NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	return ensureColumn(db, "Groups", "currency", "TEXT NOT NULL DEFAULT ''")
+}
+
+// ensureGroupEventColumns adds the details of the gift exchange itself.
+func ensureGroupEventColumns(db *sql.DB) error {
+	if err := ensureColumn(db, "Groups", "event_date", "DATETIME"); err != nil {
+		return err
+	}
+	for _, column := range []string{"location", "meeting_url", "description"} {
+		if err := ensureColumn(db, "Groups", column, "TEXT NOT NULL DEFAULT ''"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ensureUTCDrawDates rewrites draw dates stored with the offset the client
+// sent in UTC, so the draw scheduler can compare them in SQL.
+func ensureUTCDrawDates(db *sql.DB) error {
+	rows, err := db.Query(`SELECT group_id, CAST(date_draw AS TEXT) FROM Groups WHERE date_draw IS NOT NULL;`)
+	if err != nil {
+		return err
+	}
+	dates := map[int]time.Time{}
+	for rows.Next() {
+		var groupID int
+		var stored string
+		if err := rows.Scan(&groupID, &stored); err != nil {
+			rows.Close()
+			return err
+		}
+		dateDraw, err := parseDBTimeString(stored)
+		if err != nil {
+			rows.Close()
+			return fmt.Errorf("group %d: %w", groupID, err)
+		}
+		if stored != dateDraw.UTC().Format(sqlite3.SQLiteTimestampFormats[0]) {
+			dates[groupID] = dateDraw.UTC()
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for groupID, dateDraw := range dates {
+		if _, err := db.Exec(`UPDATE Groups SET date_draw = ? WHERE group_id = ?;`, dateDraw, groupID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ensureAutoDrawColumns adds the columns the draw scheduler uses to lease a
+// group to one instance and to record the outcome of drawing it.
+func ensureAutoDrawColumns(db *sql.DB) error {
+	columns := []struct {
+		name       string
+		definition string
+	}{
+		{name: "draw_claimed_by", definition: "TEXT"},
+		{name: "draw_claim_expires_at", definition: "INTEGER"},
+		{name: "auto_draw_status", definition: "TEXT"},
+		{name: "auto_draw_error", definition: "TEXT"},
+		{name: "auto_draw_attempts", definition: "INTEGER NOT NULL DEFAULT 0"},
+	}
+
+	for _, column := range columns {
+		if err := ensureColumn(db, "Groups", column.name, column.definition); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ensureParticipantRoles adds the role column and marks each group creator who
+// takes part in their own group as its owner.
+func ensureParticipantRoles(db *sql.DB) error {
+	if err := ensureColumn(db, "Participants", "role", "TEXT NOT NULL DEFAULT 'member'"); err != nil {
+		return err
+	}
+
+	_, err := db.Exec(`UPDATE Participants SET role = 'owner'
+	WHERE role <> 'owner' AND EXISTS (
+		SELECT 1 FROM Groups g WHERE g.group_id = Participants.group_id AND g.creator_user_id = Participants.user_id
+	);`)
+	return err
+}
+
+// ensureDrawHistory lets a group keep reset draws next to its single active one.
+func ensureDrawHistory(db *sql.DB) error {
+	if err := ensureColumn(db, "Draws", "reset_at", "DATETIME"); err != nil {
+		return err
+	}
+	if err := ensureColumn(db, "Draws", "reset_by", "INTEGER"); err != nil {
+		return err
+	}
+
+	_, err := db.Exec(`
+	DROP INDEX IF EXISTS idx_draws_group_id;
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_draws_active_group_id ON Draws(group_id) WHERE reset_at IS NULL;
+	`)
+	return err
+}
+
+// ensureColumn adds a column to an existing table when it is missing.
+func ensureColumn(db *sql.DB, tableName string, columnName string, definition string) error {
+	exists, err := columnExists(db, tableName, columnName)
+	if err != nil || exists {
+		return err
+	}
+
+	_, err = db.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s;`, tableName, columnName, definition))
+	return err
 }
 
 func ensureParticipantFriendColumn(db *sql.DB) error {
@@ -96,7 +493,11 @@ func ensureParticipantFriendColumn(db *sql.DB) error {
 }
 
 func participantColumnExists(db *sql.DB, columnName string) (bool, error) {
-	rows, err := db.Query(`PRAGMA table_info(Participants);`)
+	return columnExists(db, "Participants", columnName)
+}
+
+func columnExists(db *sql.DB, tableName string, columnName string) (bool, error) {
+	rows, err := db.Query(fmt.Sprintf(`PRAGMA table_info(%s);`, tableName))
 	if err != nil {
 		return false, err
 	}
@@ -125,9 +526,15 @@ func participantColumnExists(db *sql.DB, columnName string) (bool, error) {
 	return false, nil
 }
 
+// isUniqueViolation reports whether err was caused by a UNIQUE constraint.
+func isUniqueViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique
+}
+
 func GetDb() (*sql.DB, error) {
 	// Connect to the database
-	db, err := sql.Open(DbDriver, DbName)
+	db, err := sql.Open(DbDriver, dbDSN)
 	if err != nil {
 		log.Fatal(err)
 		return nil, err
@@ -161,4 +568,95 @@ func DropTables(db *sql.DB) {
 		log.Printf("%q: %s\n", err, sqlStmt)
 		return
 	}
+
+	sqlStmt = `DROP TABLE IF EXISTS Exclusions;`
+	_, err = db.Exec(sqlStmt)
+	if err != nil {
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return
+	}
+
+	sqlStmt = `DROP TABLE IF EXISTS Draws;`
+	_, err = db.Exec(sqlStmt)
+	if err != nil {
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return
+	}
+
+	sqlStmt = `DROP TABLE IF EXISTS GroupInvites;`
+	_, err = db.Exec(sqlStmt)
+	if err != nil {
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return
+	}
+
+	sqlStmt = `DROP TABLE IF EXISTS EmailInvitations;`
+	_, err = db.Exec(sqlStmt)
+	if err != nil {
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return
+	}
+
+	sqlStmt = `DROP TABLE IF EXISTS Wishlists;`
+	_, err = db.Exec(sqlStmt)
+	if err != nil {
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return
+	}
+
+	sqlStmt = `DROP TABLE IF EXISTS WishlistItems;`
+	_, err = db.Exec(sqlStmt)
+	if err != nil {
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return
+	}
+
+	sqlStmt = `DROP TABLE IF EXISTS Messages;`
+	_, err = db.Exec(sqlStmt)
+	if err != nil {
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return
+	}
+
+	sqlStmt = `DROP TABLE IF EXISTS PasswordResetTokens;`
+	_, err = db.Exec(sqlStmt)
+	if err != nil {
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return
+	}
+
+	sqlStmt = `DROP TABLE IF EXISTS EmailVerificationTokens;`
+	_, err = db.Exec(sqlStmt)
+	if err != nil {
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return
+	}
+
+	sqlStmt = `DROP TABLE IF EXISTS RefreshTokens;`
+	_, err = db.Exec(sqlStmt)
+	if err != nil {
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return
+	}
+
+	sqlStmt = `DROP TABLE IF EXISTS UserIdentities;`
+	_, err = db.Exec(sqlStmt)
+	if err != nil {
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return
+	}
+
+	sqlStmt = `DROP TABLE IF EXISTS OIDCSignins;`
+	_, err = db.Exec(sqlStmt)
+	if err != nil {
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return
+	}
+
+	sqlStmt = `DROP TABLE IF EXISTS MagicLinkTokens;`
+	_, err = db.Exec(sqlStmt)
+	if err != nil {
+		log.Printf("%q: %s\n", err, sqlStmt)
+		return
+	}
 }
@@ -0,0 +1,145 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/akctba/secret-santa-go-api/models"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestInsertDrawPersistsDrawAndAssignments(t *testing.T) {
+	db := openParticipantTestDB(t)
+
+	for _, userID := range []int{1, 2} {
+		if err := InsertParticipant(db, models.ParticipantRequest{GroupID: "1", UserID: userID}); err != nil {
+			t.Fatalf("InsertParticipant returned error: %v", err)
+		}
+	}
+
+	participants := []models.Participant{
+		{GroupID: "1", UserID: 1, FriendUserID: 2},
+		{GroupID: "1", UserID: 2, FriendUserID: 1},
+	}
+	draw := models.Draw{GroupID: "1", RunAt: time.Now().UTC(), RunBy: 1, Algorithm: "backtracking", IdempotencyKey: "key"}
+	if err := InsertDraw(db, &draw, participants); err != nil {
+		t.Fatalf("InsertDraw returned error: %v", err)
+	}
+	if draw.DrawID == 0 {
+		t.Fatal("expected generated draw id after insert")
+	}
+
+	got, err := GetDrawByGroupID(db, "1")
+	if err != nil {
+		t.Fatalf("GetDrawByGroupID returned error: %v", err)
+	}
+	if got.DrawID != draw.DrawID || got.RunBy != 1 || got.IdempotencyKey != "key" {
+		t.Fatalf("unexpected draw %+v", got)
+	}
+
+	persisted, err := GetUserParticipant(db, 1, 1)
+	if err != nil {
+		t.Fatalf("GetUserParticipant returned error: %v", err)
+	}
+	if persisted.FriendUserID != 2 {
+		t.Fatalf("expected friend_user_id 2, got %d", persisted.FriendUserID)
+	}
+
+	again := models.Draw{GroupID: "1", RunAt: time.Now().UTC(), RunBy: 1, Algorithm: "backtracking"}
+	if err := InsertDraw(db, &again, participants); !errors.Is(err, ErrDrawExists) {
+		t.Fatalf("expected ErrDrawExists for a second draw, got %v", err)
+	}
+}
+
+func TestInsertDrawRollsBackWhenParticipantsChanged(t *testing.T) {
+	db := openParticipantTestDB(t)
+
+	for _, userID := range []int{1, 2, 3} {
+		if err := InsertParticipant(db, models.ParticipantRequest{GroupID: "1", UserID: userID}); err != nil {
+			t.Fatalf("InsertParticipant returned error: %v", err)
+		}
+	}
+
+	// User 3 joined after the participants were read for the draw.
+	participants := []models.Participant{
+		{GroupID: "1", UserID: 1, FriendUserID: 2},
+		{GroupID: "1", UserID: 2, FriendUserID: 1},
+	}
+	draw := models.Draw{GroupID: "1", RunAt: time.Now().UTC(), RunBy: 1, Algorithm: "backtracking"}
+	if err := InsertDraw(db, &draw, participants); !errors.Is(err, ErrDrawParticipantsChanged) {
+		t.Fatalf("expected ErrDrawParticipantsChanged, got %v", err)
+	}
+
+	if _, err := GetDrawByGroupID(db, "1"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected no draw record after rollback, got %v", err)
+	}
+
+	persisted, err := GetUserParticipant(db, 1, 1)
+	if err != nil {
+		t.Fatalf("GetUserParticipant returned error: %v", err)
+	}
+	if persisted.FriendUserID != 0 {
+		t.Fatalf("expected no assignment after rollback, got %d", persisted.FriendUserID)
+	}
+}
+
+func TestCreateTablesMigratesDrawsForHistory(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	db, err := sql.Open(DbDriver, DbName)
+	if err != nil {
+		t.Fatalf("open sqlite db: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+
+	_, err = db.Exec(`CREATE TABLE Draws (
+		draw_id INTEGER PRIMARY KEY AUTOINCREMENT,
+		group_id INTEGER,
+		run_at DATETIME,
+		run_by INTEGER,
+		algorithm TEXT,
+		idempotency_key TEXT
+	);
+	CREATE UNIQUE INDEX idx_draws_group_id ON Draws(group_id);`)
+	if err != nil {
+		t.Fatalf("create legacy Draws table: %v", err)
+	}
+
+	CreateTables()
+
+	for _, userID := range []int{1, 2} {
+		if err := InsertParticipant(db, models.ParticipantRequest{GroupID: "1", UserID: userID}); err != nil {
+			t.Fatalf("InsertParticipant returned error: %v", err)
+		}
+	}
+	participants := []models.Participant{
+		{GroupID: "1", UserID: 1, FriendUserID: 2},
+		{GroupID: "1", UserID: 2, FriendUserID: 1},
+	}
+
+	if err := InsertDraw(db, &models.Draw{GroupID: "1", RunAt: time.Now().UTC(), RunBy: 1}, participants); err != nil {
+		t.Fatalf("InsertDraw returned error: %v", err)
+	}
+	if err := Redraw(db, &models.Draw{GroupID: "1", RunAt: time.Now().UTC(), RunBy: 1}, participants); err != nil {
+		t.Fatalf("Redraw after migration returned error: %v", err)
+	}
+
+	draws, err := GetDrawsByGroupID(db, "1")
+	if err != nil {
+		t.Fatalf("GetDrawsByGroupID returned error: %v", err)
+	}
+	if len(draws) != 2 || draws[0].ResetAt == nil || draws[1].ResetAt != nil {
+		t.Fatalf("expected one reset and one active draw, got %+v", draws)
+	}
+
+	if err := ResetDraw(db, "1", 1); err != nil {
+		t.Fatalf("ResetDraw returned error: %v", err)
+	}
+	if err := ResetDraw(db, "1", 1); !errors.Is(err, ErrDrawNotFound) {
+		t.Fatalf("expected ErrDrawNotFound resetting twice, got %v", err)
+	}
+}
@@ -0,0 +1,74 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestEmailTokensAreSingleUseAndExpiredOnesCleanedUp(t *testing.T) {
+	db := openParticipantTestDB(t)
+	insertParticipantTestUser(t, db, 1, "Alice", "alice@example.com")
+
+	now := time.Now().UTC()
+	for _, table := range []emailTokenTable{passwordResetTokens, emailVerificationTokens, magicLinkTokens} {
+		if err := insertEmailToken(db, table, 1, "expired", now.Add(-time.Minute)); err != nil {
+			t.Fatalf("insertEmailToken(%s) returned error: %v", table, err)
+		}
+		for _, tokenHash := range []string{"first", "second"} {
+			if err := insertEmailToken(db, table, 1, tokenHash, now.Add(time.Hour)); err != nil {
+				t.Fatalf("insertEmailToken(%s) returned error: %v", table, err)
+			}
+		}
+
+		var stored int
+		if err := db.QueryRow(`SELECT COUNT(*) FROM ` + string(table)).Scan(&stored); err != nil {
+			t.Fatalf("count %s: %v", table, err)
+		}
+		if stored != 2 {
+			t.Fatalf("expected the expired %s row to be cleaned up, got %d rows", table, stored)
+		}
+
+		invalid := errors.New("invalid")
+		applied := 0
+		apply := func(*sql.Tx, int) error {
+			applied++
+			return nil
+		}
+		if userID, err := consumeEmailToken(db, table, "second", now, invalid, apply); err != nil || userID != 1 {
+			t.Fatalf("consumeEmailToken(%s) returned %d, %v", table, userID, err)
+		}
+		for _, tokenHash := range []string{"second", "first", "expired"} {
+			if _, err := consumeEmailToken(db, table, tokenHash, now, invalid, apply); !errors.Is(err, invalid) {
+				t.Fatalf("expected %s token %q to be used up, got %v", table, tokenHash, err)
+			}
+		}
+		if applied != 1 {
+			t.Fatalf("expected apply to run once for %s, ran %d times", table, applied)
+		}
+	}
+}
+
+func TestDropTablesRemovesEveryTable(t *testing.T) {
+	db := openParticipantTestDB(t)
+
+	DropTables(db)
+
+	var tables []string
+	rows, err := db.Query(`SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%'`)
+	if err != nil {
+		t.Fatalf("list tables: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatalf("scan table name: %v", err)
+		}
+		tables = append(tables, name)
+	}
+	if len(tables) != 0 {
+		t.Fatalf("expected every table to be dropped, left %v", tables)
+	}
+}
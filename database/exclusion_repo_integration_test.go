@@ -0,0 +1,58 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/akctba/secret-santa-go-api/models"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestExclusionRepoCRUD(t *testing.T) {
+	db := openParticipantTestDB(t)
+
+	exclusion := models.Exclusion{GroupID: "1", UserID: 1, ExcludedUserID: 2, OneWay: true}
+	if err := InsertExclusion(db, &exclusion); err != nil {
+		t.Fatalf("InsertExclusion returned error: %v", err)
+	}
+	if exclusion.ExclusionID == 0 {
+		t.Fatal("expected generated exclusion id after insert")
+	}
+
+	if err := InsertExclusion(db, &models.Exclusion{GroupID: "2", UserID: 3, ExcludedUserID: 4}); err != nil {
+		t.Fatalf("InsertExclusion for other group returned error: %v", err)
+	}
+
+	got, err := GetExclusionByID(db, "1", exclusion.ExclusionID)
+	if err != nil {
+		t.Fatalf("GetExclusionByID returned error: %v", err)
+	}
+	if got.UserID != 1 || got.ExcludedUserID != 2 || !got.OneWay {
+		t.Fatalf("unexpected exclusion %+v", got)
+	}
+
+	if _, err := GetExclusionByID(db, "2", exclusion.ExclusionID); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected sql.ErrNoRows for exclusion of another group, got %v", err)
+	}
+
+	byGroup, err := GetExclusionsByGroupID(db, "1")
+	if err != nil {
+		t.Fatalf("GetExclusionsByGroupID returned error: %v", err)
+	}
+	if len(byGroup) != 1 {
+		t.Fatalf("expected 1 exclusion for group, got %d", len(byGroup))
+	}
+
+	if err := DeleteExclusion(db, "1", exclusion.ExclusionID); err != nil {
+		t.Fatalf("DeleteExclusion returned error: %v", err)
+	}
+
+	byGroup, err = GetExclusionsByGroupID(db, "1")
+	if err != nil {
+		t.Fatalf("GetExclusionsByGroupID after delete returned error: %v", err)
+	}
+	if len(byGroup) != 0 {
+		t.Fatalf("expected no exclusions after delete, got %d", len(byGroup))
+	}
+}
@@ -13,23 +13,12 @@ import (
 func newGroupTestDB(t *testing.T) *sql.DB {
 	t.Helper()
 
-	db, err := sql.Open("sqlite3", ":memory:")
-	if err != nil {
-		t.Fatalf("open sqlite db: %v", err)
-	}
+	t.Chdir(t.TempDir())
+	CreateTables()
 
-	createStmt := `
-	CREATE TABLE Groups (
-		group_id INTEGER PRIMARY KEY AUTOINCREMENT,
-		name TEXT,
-		date_created DATETIME,
-		date_draw DATETIME,
-		creator_user_id INTEGER
-	);`
-
-	if _, err = db.Exec(createStmt); err != nil {
-		db.Close()
-		t.Fatalf("create Groups table: %v", err)
+	db, err := GetDb()
+	if err != nil {
+		t.Fatalf("open group test db: %v", err)
 	}
 
 	t.Cleanup(func() {
@@ -48,6 +37,9 @@ func TestGroupRepoCRUD(t *testing.T) {
 		DateCreated:   now,
 		DateDraw:      now.Add(24 * time.Hour),
 		CreatorUserID: 42,
+		BudgetMin:     10,
+		BudgetMax:     25.5,
+		Currency:      "EUR",
 	}
 
 	if err := InsertGroup(db, &group); err != nil {
@@ -72,6 +64,10 @@ func TestGroupRepoCRUD(t *testing.T) {
 	if got.CreatorUserID != group.CreatorUserID {
 		t.Fatalf("expected creator_user_id %d, got %d", group.CreatorUserID, got.CreatorUserID)
 	}
+	if got.BudgetMin != group.BudgetMin || got.BudgetMax != group.BudgetMax || got.Currency != group.Currency {
+		t.Fatalf("expected budget %v-%v %s, got %v-%v %s", group.BudgetMin, group.BudgetMax, group.Currency,
+			got.BudgetMin, got.BudgetMax, got.Currency)
+	}
 
 	group.Name = "Updated Holiday Crew"
 	if err := UpdateGroup(db, group); err != nil {
@@ -127,4 +123,79 @@ func TestInsertGroupIgnoresProvidedGroupID(t *testing.T) {
 	if got.Name != group.Name {
 		t.Fatalf("expected name %q, got %q", group.Name, got.Name)
 	}
-}
\ No newline at end of file
+}
+func TestDeleteGroupRemovesEverythingInTheGroup(t *testing.T) {
+	db := newGroupTestDB(t)
+
+	for _, groupID := range []int{1, 2} {
+		_, err := db.Exec(`INSERT INTO Groups (group_id, name, creator_user_id) VALUES (?, 'Office', 1);
+		INSERT INTO Participants (group_id, user_id, friend_user_id) VALUES (?, 1, 2), (?, 2, 1);
+		INSERT INTO Draws (group_id, algorithm) VALUES (?, 'backtracking');
+		INSERT INTO Exclusions (group_id, user_id, excluded_user_id) VALUES (?, 1, 2);
+		INSERT INTO GroupInvites (group_id, code) VALUES (?, 'CODE' || ?);
+		INSERT INTO EmailInvitations (group_id, email) VALUES (?, 'new@example.com');
+		INSERT INTO Wishlists (user_id, group_id, title) VALUES (1, ?, 'Office party');
+		INSERT INTO WishlistItems (wishlist_id, title) VALUES (last_insert_rowid(), 'Mug');
+		INSERT INTO Messages (group_id, giver_user_id, receiver_user_id, sender, body) VALUES (?, 1, 2, 'giver', 'Hi');`,
+			groupID, groupID, groupID, groupID, groupID, groupID, groupID, groupID, groupID, groupID)
+		if err != nil {
+			t.Fatalf("seed group %d: %v", groupID, err)
+		}
+	}
+
+	if err := DeleteGroup(db, "1"); err != nil {
+		t.Fatalf("DeleteGroup returned error: %v", err)
+	}
+	if err := DeleteGroup(db, "1"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected sql.ErrNoRows deleting twice, got %v", err)
+	}
+
+	for _, table := range []string{"Groups", "Participants", "Draws", "Exclusions", "GroupInvites", "EmailInvitations", "Wishlists", "Messages"} {
+		var remaining, other int
+		if err := db.QueryRow(`SELECT COUNT(*) FROM ` + table + ` WHERE group_id = 1`).Scan(&remaining); err != nil {
+			t.Fatalf("count %s: %v", table, err)
+		}
+		if err := db.QueryRow(`SELECT COUNT(*) FROM ` + table + ` WHERE group_id = 2`).Scan(&other); err != nil {
+			t.Fatalf("count %s: %v", table, err)
+		}
+		if remaining != 0 || other == 0 {
+			t.Fatalf("expected only group 1 to be removed from %s, got %d left and %d for group 2", table, remaining, other)
+		}
+	}
+
+	var items int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM WishlistItems`).Scan(&items); err != nil {
+		t.Fatalf("count wishlist items: %v", err)
+	}
+	if items != 1 {
+		t.Fatalf("expected only group 2's wishlist item to remain, got %d", items)
+	}
+}
+
+func TestGetGroupsDueForDrawComparesDrawDatesInUTC(t *testing.T) {
+	db := newGroupTestDB(t)
+
+	now := time.Now().UTC()
+	ahead := time.FixedZone("UTC+8", 8*60*60)
+	group := models.Group{Name: "Office", DateCreated: now, DateDraw: now.Add(-time.Hour).In(ahead), CreatorUserID: 1}
+	if err := InsertGroup(db, &group); err != nil {
+		t.Fatalf("InsertGroup returned error: %v", err)
+	}
+
+	// Groups saved before draw dates were stored in UTC kept the client's offset.
+	_, err := db.Exec(`INSERT INTO Groups (group_id, name, date_created, date_draw, creator_user_id)
+	VALUES (2, 'Legacy', ?1, ?2, 1), (3, 'Later', ?1, ?3, 1)`,
+		now, now.Add(-time.Hour).In(ahead).Format("2006-01-02 15:04:05-07:00"), now.Add(time.Hour).Format(time.RFC3339))
+	if err != nil {
+		t.Fatalf("insert legacy groups: %v", err)
+	}
+	CreateTables()
+
+	due, err := GetGroupsDueForDraw(db, now, 3)
+	if err != nil {
+		t.Fatalf("GetGroupsDueForDraw returned error: %v", err)
+	}
+	if len(due) != 2 || due[0].GroupID != group.GroupID || due[1].GroupID != "2" {
+		t.Fatalf("expected groups %s and 2 to be due, got %+v", group.GroupID, due)
+	}
+}
@@ -0,0 +1,70 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/akctba/secret-santa-go-api/models"
+)
+
+func TestConsumeOIDCSigninIsSingleUse(t *testing.T) {
+	db := openParticipantTestDB(t)
+
+	now := time.Now().UTC()
+	signin := func(stateHash string, expiresAt time.Time) models.OIDCSignin {
+		return models.OIDCSignin{StateHash: stateHash, Provider: "google", Nonce: "nonce", CodeVerifier: "verifier", ExpiresAt: expiresAt}
+	}
+	for _, s := range []models.OIDCSignin{signin("current", now.Add(time.Minute)), signin("expired", now.Add(-time.Minute))} {
+		if err := InsertOIDCSignin(db, s, now.Add(-time.Hour)); err != nil {
+			t.Fatalf("InsertOIDCSignin returned error: %v", err)
+		}
+	}
+
+	if _, err := ConsumeOIDCSignin(db, "current", "microsoft", now); !errors.Is(err, ErrOIDCSigninInvalid) {
+		t.Fatalf("expected another provider's callback to be rejected, got %v", err)
+	}
+	if err := InsertOIDCSignin(db, signin("current", now.Add(time.Minute)), now); err != nil {
+		t.Fatalf("InsertOIDCSignin returned error: %v", err)
+	}
+
+	consumed, err := ConsumeOIDCSignin(db, "current", "google", now)
+	if err != nil || consumed.Nonce != "nonce" || consumed.CodeVerifier != "verifier" {
+		t.Fatalf("ConsumeOIDCSignin returned %+v, %v", consumed, err)
+	}
+	if _, err := ConsumeOIDCSignin(db, "current", "google", now); !errors.Is(err, ErrOIDCSigninInvalid) {
+		t.Fatalf("expected a completed sign-in to be rejected, got %v", err)
+	}
+	if _, err := ConsumeOIDCSignin(db, "expired", "google", now); !errors.Is(err, ErrOIDCSigninInvalid) {
+		t.Fatalf("expected expired sign-ins to be cleaned up, got %v", err)
+	}
+}
+
+func TestUserIdentitiesAreLinkedOnce(t *testing.T) {
+	db := openParticipantTestDB(t)
+	insertParticipantTestUser(t, db, 1, "Alice", "alice@example.com")
+
+	now := time.Now().UTC()
+	alice := models.UserIdentity{Provider: "google", Subject: "alice-sub", UserID: 1}
+	if err := LinkUserIdentity(db, alice, now); err != nil {
+		t.Fatalf("LinkUserIdentity returned error: %v", err)
+	}
+
+	user, err := GetUserByIdentity(db, "google", "alice-sub")
+	if err != nil || user.UserID != 1 || user.EmailVerifiedAt == nil {
+		t.Fatalf("GetUserByIdentity returned %+v, %v", user, err)
+	}
+
+	bob := models.User{UserName: "Bob", UserEmail: "bob@example.com"}
+	if err := InsertUserWithIdentity(db, &bob, alice, now); !errors.Is(err, ErrIdentityTaken) {
+		t.Fatalf("expected ErrIdentityTaken, got %v", err)
+	}
+	if _, err := GetUserByEmail(db, "bob@example.com"); err == nil {
+		t.Fatal("expected the user not to be created when the identity is taken")
+	}
+
+	taken := models.User{UserName: "Alice", UserEmail: "alice@example.com"}
+	if err := InsertUserWithIdentity(db, &taken, models.UserIdentity{Provider: "google", Subject: "other"}, now); !errors.Is(err, ErrEmailTaken) {
+		t.Fatalf("expected ErrEmailTaken, got %v", err)
+	}
+}
@@ -0,0 +1,78 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/akctba/secret-santa-go-api/models"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestAcceptInviteHonoursExpiryAndMaxUses(t *testing.T) {
+	db := openParticipantTestDB(t)
+
+	now := time.Now().UTC()
+	invite := models.Invite{GroupID: "1", Code: "ABC", CreatedBy: 1, ExpiresAt: now.Add(time.Hour), MaxUses: 2}
+	if err := InsertInvite(db, &invite); err != nil {
+		t.Fatalf("InsertInvite returned error: %v", err)
+	}
+	if invite.InviteID == 0 {
+		t.Fatal("expected generated invite id after insert")
+	}
+
+	for _, userID := range []int{2, 3} {
+		accepted, err := AcceptInvite(db, "ABC", userID, now)
+		if err != nil {
+			t.Fatalf("AcceptInvite(%d) returned error: %v", userID, err)
+		}
+		if accepted.GroupID != "1" {
+			t.Fatalf("expected invite for group 1, got %+v", accepted)
+		}
+	}
+
+	if _, err := AcceptInvite(db, "ABC", 4, now); !errors.Is(err, ErrInviteExhausted) {
+		t.Fatalf("expected ErrInviteExhausted, got %v", err)
+	}
+	if _, err := AcceptInvite(db, "ABC", 4, now.Add(2*time.Hour)); !errors.Is(err, ErrInviteExpired) {
+		t.Fatalf("expected ErrInviteExpired, got %v", err)
+	}
+	if _, err := GetUserParticipant(db, 4, 1); err == nil {
+		t.Fatal("expected user 4 not to join through a used up invite")
+	}
+
+	invites, err := GetInvitesByGroupID(db, "1")
+	if err != nil {
+		t.Fatalf("GetInvitesByGroupID returned error: %v", err)
+	}
+	if len(invites) != 1 || invites[0].Uses != 2 || invites[0].RevokedAt != nil {
+		t.Fatalf("unexpected invites %+v", invites)
+	}
+}
+
+func TestAcceptInviteRollsBackUseForExistingParticipant(t *testing.T) {
+	db := openParticipantTestDB(t)
+
+	if err := InsertParticipant(db, models.ParticipantRequest{GroupID: "1", UserID: 2}); err != nil {
+		t.Fatalf("InsertParticipant returned error: %v", err)
+	}
+
+	invite := models.Invite{GroupID: "1", Code: "ABC", CreatedBy: 1, ExpiresAt: time.Now().Add(time.Hour), MaxUses: 1}
+	if err := InsertInvite(db, &invite); err != nil {
+		t.Fatalf("InsertInvite returned error: %v", err)
+	}
+
+	if _, err := AcceptInvite(db, "ABC", 2, time.Now()); !errors.Is(err, ErrAlreadyParticipant) {
+		t.Fatalf("expected ErrAlreadyParticipant, got %v", err)
+	}
+	if _, err := AcceptInvite(db, "ABC", 3, time.Now()); err != nil {
+		t.Fatalf("expected the invite use to be rolled back, got %v", err)
+	}
+
+	if err := RevokeInvite(db, "1", invite.InviteID); err != nil {
+		t.Fatalf("RevokeInvite returned error: %v", err)
+	}
+	if _, err := AcceptInvite(db, "ABC", 4, time.Now()); !errors.Is(err, ErrInviteNotFound) {
+		t.Fatalf("expected ErrInviteNotFound for a revoked invite, got %v", err)
+	}
+}
@@ -92,12 +92,22 @@ func TestParticipantRepoFreshDBFlow(t *testing.T) {
 		t.Fatalf("expected friend_user_id 2, got %d", persisted.FriendUserID)
 	}
 
-	remaining, err := GetParticipantsToDraw(db, "1")
+	afterUpdate, err := GetParticipantsToDraw(db, "1")
 	if err != nil {
 		t.Fatalf("GetParticipantsToDraw after update returned error: %v", err)
 	}
-	if len(remaining) != 1 {
-		t.Fatalf("expected 1 participant remaining to draw, got %d", len(remaining))
+	if len(afterUpdate) != 2 {
+		t.Fatalf("expected drawn participants to still be returned, got %d", len(afterUpdate))
+	}
+
+	assigned := 0
+	for _, participant := range afterUpdate {
+		if participant.FriendUserID != 0 {
+			assigned++
+		}
+	}
+	if assigned != 1 {
+		t.Fatalf("expected 1 participant with an assignment, got %d", assigned)
 	}
 }
 
@@ -146,3 +156,212 @@ func TestCreateTablesMigratesParticipantFriendColumn(t *testing.T) {
 		t.Fatalf("select friend_user_id after migration: %v", err)
 	}
 }
+
+func TestCreateTablesBackfillsParticipantRoles(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	db, err := sql.Open(DbDriver, DbName)
+	if err != nil {
+		t.Fatalf("open sqlite db: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+
+	_, err = db.Exec(`CREATE TABLE Groups (
+		group_id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT,
+		date_created DATETIME,
+		date_draw DATETIME,
+		creator_user_id INTEGER
+	);
+	CREATE TABLE Participants (
+		group_id INTEGER,
+		user_id INTEGER,
+		joined_at TEXT,
+		friend_user_id INTEGER,
+		PRIMARY KEY (group_id, user_id)
+	);
+	INSERT INTO Groups (group_id, name, creator_user_id) VALUES (1, 'Office', 1);
+	INSERT INTO Participants (group_id, user_id, joined_at) VALUES (1, 1, ''), (1, 2, '');`)
+	if err != nil {
+		t.Fatalf("create legacy tables: %v", err)
+	}
+
+	CreateTables()
+
+	for userID, want := range map[int]string{1: models.RoleOwner, 2: models.RoleMember} {
+		role, err := GetParticipantRole(db, userID, "1")
+		if err != nil {
+			t.Fatalf("GetParticipantRole(%d) returned error: %v", userID, err)
+		}
+		if role != want {
+			t.Fatalf("expected user %d to be %s, got %s", userID, want, role)
+		}
+	}
+}
+
+func TestTransferGroupOwnershipSwapsRoles(t *testing.T) {
+	db := openParticipantTestDB(t)
+
+	group := models.Group{Name: "Office", CreatorUserID: 1}
+	if err := InsertGroup(db, &group); err != nil {
+		t.Fatalf("InsertGroup returned error: %v", err)
+	}
+	for _, userID := range []int{1, 2} {
+		if err := InsertParticipant(db, models.ParticipantRequest{GroupID: group.GroupID, UserID: userID}); err != nil {
+			t.Fatalf("InsertParticipant returned error: %v", err)
+		}
+	}
+
+	if role, _ := GetParticipantRole(db, 1, group.GroupID); role != models.RoleOwner {
+		t.Fatalf("expected creator to join as owner, got %q", role)
+	}
+
+	if err := TransferGroupOwnership(db, group.GroupID, 3); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected sql.ErrNoRows for a non-participant, got %v", err)
+	}
+	if role, _ := GetParticipantRole(db, 1, group.GroupID); role != models.RoleOwner {
+		t.Fatalf("expected failed transfer to roll back, got %q", role)
+	}
+
+	if err := TransferGroupOwnership(db, group.GroupID, 2); err != nil {
+		t.Fatalf("TransferGroupOwnership returned error: %v", err)
+	}
+
+	got, err := GetGroupByID(db, group.GroupID)
+	if err != nil {
+		t.Fatalf("GetGroupByID returned error: %v", err)
+	}
+	if got.CreatorUserID != 2 {
+		t.Fatalf("expected creator_user_id 2, got %d", got.CreatorUserID)
+	}
+	for userID, want := range map[int]string{1: models.RoleOrganizer, 2: models.RoleOwner} {
+		if role, _ := GetParticipantRole(db, userID, group.GroupID); role != want {
+			t.Fatalf("expected user %d to be %s, got %q", userID, want, role)
+		}
+	}
+}
+
+func TestTransferGroupOwnershipKeepsCreatorsWhoDoNotTakePart(t *testing.T) {
+	db := openParticipantTestDB(t)
+
+	group := models.Group{Name: "Office", CreatorUserID: 1}
+	if err := InsertGroup(db, &group); err != nil {
+		t.Fatalf("InsertGroup returned error: %v", err)
+	}
+	if err := InsertParticipant(db, models.ParticipantRequest{GroupID: group.GroupID, UserID: 2}); err != nil {
+		t.Fatalf("InsertParticipant returned error: %v", err)
+	}
+
+	if err := TransferGroupOwnership(db, group.GroupID, 2); !errors.Is(err, ErrOwnerNotParticipant) {
+		t.Fatalf("expected ErrOwnerNotParticipant, got %v", err)
+	}
+
+	got, err := GetGroupByID(db, group.GroupID)
+	if err != nil {
+		t.Fatalf("GetGroupByID returned error: %v", err)
+	}
+	if got.CreatorUserID != 1 {
+		t.Fatalf("expected user 1 to keep the group, got creator_user_id %d", got.CreatorUserID)
+	}
+	if role, _ := GetParticipantRole(db, 2, group.GroupID); role != models.RoleMember {
+		t.Fatalf("expected user 2 to stay a member, got %q", role)
+	}
+}
+
+func TestAcceptEmailInvitationsSkipsDrawnGroups(t *testing.T) {
+	db := openParticipantTestDB(t)
+
+	for _, groupID := range []string{"1", "2"} {
+		if err := InsertEmailInvitation(db, &models.EmailInvitation{GroupID: groupID, Email: "carol@example.com", InvitedBy: 1}); err != nil {
+			t.Fatalf("InsertEmailInvitation returned error: %v", err)
+		}
+	}
+	if err := InsertEmailInvitation(db, &models.EmailInvitation{GroupID: "1", Email: "carol@example.com"}); !errors.Is(err, ErrInvitationExists) {
+		t.Fatalf("expected ErrInvitationExists, got %v", err)
+	}
+
+	// Group 2 was drawn before the invitee registered.
+	_, err := db.Exec(`INSERT INTO Participants (group_id, user_id, joined_at, friend_user_id) VALUES (2, 1, '', 1)`)
+	if err != nil {
+		t.Fatalf("seed drawn group: %v", err)
+	}
+
+	accepted, err := AcceptEmailInvitations(db, 5, "carol@example.com")
+	if err != nil {
+		t.Fatalf("AcceptEmailInvitations returned error: %v", err)
+	}
+	if len(accepted) != 1 || accepted[0].GroupID != "1" {
+		t.Fatalf("expected only group 1 to be joined, got %+v", accepted)
+	}
+
+	if _, err := GetUserParticipant(db, 5, 1); err != nil {
+		t.Fatalf("expected user 5 in group 1: %v", err)
+	}
+	pending, err := GetEmailInvitationsByGroupID(db, "2", models.InvitationPending)
+	if err != nil {
+		t.Fatalf("GetEmailInvitationsByGroupID returned error: %v", err)
+	}
+	if len(pending) != 1 {
+		t.Fatalf("expected the drawn group's invitation to stay pending, got %+v", pending)
+	}
+}
+
+func TestRemoveParticipantKeepsExclusionsHonoured(t *testing.T) {
+	db := openParticipantTestDB(t)
+
+	// 1 -> 2 -> 3 -> 4 -> 1, and 1 must never draw 3.
+	for userID, friendUserID := range map[int]int{1: 2, 2: 3, 3: 4, 4: 1} {
+		_, err := db.Exec(`INSERT INTO Participants (group_id, user_id, joined_at, friend_user_id) VALUES (1, ?, '', ?)`,
+			userID, friendUserID)
+		if err != nil {
+			t.Fatalf("seed participant %d: %v", userID, err)
+		}
+	}
+	if _, err := db.Exec(`INSERT INTO Exclusions (group_id, user_id, excluded_user_id) VALUES (1, 3, 1)`); err != nil {
+		t.Fatalf("seed exclusion: %v", err)
+	}
+
+	if _, err := RemoveParticipant(db, "1", 2); !errors.Is(err, ErrDrawCannotRelink) {
+		t.Fatalf("expected ErrDrawCannotRelink, got %v", err)
+	}
+
+	relinked, err := RemoveParticipant(db, "1", 3)
+	if err != nil {
+		t.Fatalf("RemoveParticipant returned error: %v", err)
+	}
+	if relinked.UserID != 2 || relinked.FriendUserID != 4 {
+		t.Fatalf("expected user 2 to draw user 4, got %+v", relinked)
+	}
+
+	var exclusions int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM Exclusions WHERE group_id = 1`).Scan(&exclusions); err != nil {
+		t.Fatalf("count exclusions: %v", err)
+	}
+	if exclusions != 0 {
+		t.Fatalf("expected the removed user's exclusions to go, got %d", exclusions)
+	}
+
+	if _, err := RemoveParticipant(db, "1", 3); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected sql.ErrNoRows once removed, got %v", err)
+	}
+
+	// 1 -> 2 -> 4 -> 1: the last two may draw each other.
+	if relinked, err := RemoveParticipant(db, "1", 4); err != nil || relinked.UserID != 2 || relinked.FriendUserID != 1 {
+		t.Fatalf("expected user 2 to draw user 1, got %+v, %v", relinked, err)
+	}
+
+	// 1 -> 2 -> 3 -> 1 and 4 -> 5 -> 6 -> 4: removing 2 would leave 1 and 3
+	// drawing each other in a group of five.
+	for userID, friendUserID := range map[int]int{1: 2, 2: 3, 3: 1, 4: 5, 5: 6, 6: 4} {
+		_, err := db.Exec(`INSERT INTO Participants (group_id, user_id, joined_at, friend_user_id) VALUES (2, ?, '', ?)`,
+			userID, friendUserID)
+		if err != nil {
+			t.Fatalf("seed participant %d: %v", userID, err)
+		}
+	}
+	if _, err := RemoveParticipant(db, "2", 2); !errors.Is(err, ErrDrawCannotRelink) {
+		t.Fatalf("expected ErrDrawCannotRelink for a reciprocal pair, got %v", err)
+	}
+}
@@ -0,0 +1,49 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/akctba/secret-santa-go-api/models"
+)
+
+func TestRotateRefreshTokenRevokesFamilyOnReuse(t *testing.T) {
+	db := openParticipantTestDB(t)
+	insertParticipantTestUser(t, db, 1, "Alice", "alice@example.com")
+
+	now := time.Now().UTC()
+	refreshToken := func(tokenID string, familyID string) models.RefreshToken {
+		return models.RefreshToken{
+			TokenID:   tokenID,
+			UserID:    1,
+			FamilyID:  familyID,
+			IssuedAt:  now,
+			ExpiresAt: now.Add(time.Hour),
+		}
+	}
+
+	if err := InsertRefreshToken(db, refreshToken("first", "phone")); err != nil {
+		t.Fatalf("InsertRefreshToken returned error: %v", err)
+	}
+	if err := InsertRefreshToken(db, refreshToken("laptop", "laptop")); err != nil {
+		t.Fatalf("InsertRefreshToken returned error: %v", err)
+	}
+
+	if err := RotateRefreshToken(db, "first", refreshToken("second", "phone"), now); err != nil {
+		t.Fatalf("RotateRefreshToken returned error: %v", err)
+	}
+	if err := RotateRefreshToken(db, "first", refreshToken("stolen", "phone"), now); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("expected ErrRefreshTokenReused, got %v", err)
+	}
+	if err := RotateRefreshToken(db, "second", refreshToken("third", "phone"), now); !errors.Is(err, ErrRefreshTokenInvalid) {
+		t.Fatalf("expected the rest of the family to be revoked, got %v", err)
+	}
+
+	if err := RotateRefreshToken(db, "laptop", refreshToken("laptop-2", "laptop"), now); err != nil {
+		t.Fatalf("expected other sessions to keep working, got %v", err)
+	}
+	if err := RotateRefreshToken(db, "laptop-2", refreshToken("laptop-3", "laptop"), now.Add(2*time.Hour)); !errors.Is(err, ErrRefreshTokenInvalid) {
+		t.Fatalf("expected expired tokens to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,84 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/akctba/secret-santa-go-api/models"
+)
+
+func TestEnsureUniqueUserEmailsReportsDuplicates(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	db, err := sql.Open(DbDriver, DbName)
+	if err != nil {
+		t.Fatalf("open sqlite db: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+
+	_, err = db.Exec(`CREATE TABLE Users (
+		user_id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_name TEXT,
+		user_email TEXT,
+		password TEXT,
+		gender TEXT,
+		date_of_birth TEXT,
+		email_verified_at DATETIME
+	);
+	INSERT INTO Users (user_id, user_name, user_email, password) VALUES
+		(1, 'Alice', 'Alice@Example.com', 'secret'),
+		(2, 'Bob', 'bob@example.com', 'secret'),
+		(3, 'Bob again', ' BOB@example.com', 'secret'),
+		(4, 'Carol', 'Carol@Example.com', 'secret'),
+		(5, 'Carol again', 'CAROL@example.com', 'secret');`)
+	if err != nil {
+		t.Fatalf("create legacy users: %v", err)
+	}
+
+	err = ensureUniqueUserEmails(db)
+	if err == nil || !strings.Contains(err.Error(), "bob@example.com (users 2, 3)") {
+		t.Fatalf("expected the duplicate to be reported, got %v", err)
+	}
+
+	if _, err := GetUserByEmail(db, "alice@example.com"); err != nil {
+		t.Fatalf("expected Alice's email to be normalized: %v", err)
+	}
+	bob, err := GetUserByID(db, 3)
+	if err != nil {
+		t.Fatalf("GetUserByID returned error: %v", err)
+	}
+	if bob.UserEmail != " BOB@example.com" {
+		t.Fatalf("expected duplicates to be left for an operator, got %q", bob.UserEmail)
+	}
+
+	// Duplicates that only differ in case can still sign in, as the oldest account.
+	carol, err := GetUserByEmail(db, "carol@example.com")
+	if err != nil || carol.UserID != 4 {
+		t.Fatalf("expected Carol's oldest account, got %d, %v", carol.UserID, err)
+	}
+
+	// Without the index, registrations still refuse addresses in use.
+	for _, email := range []string{"bob@example.com", "ALICE@example.com"} {
+		if err := InsertUser(db, &models.User{UserName: "Impostor", UserEmail: email}); !errors.Is(err, ErrEmailTaken) {
+			t.Fatalf("expected ErrEmailTaken for %s before the index exists, got %v", email, err)
+		}
+	}
+
+	_, err = db.Exec(`UPDATE Users SET user_email = 'bob.again@example.com' WHERE user_id = 3;
+		UPDATE Users SET user_email = 'carol.again@example.com' WHERE user_id = 5;`)
+	if err != nil {
+		t.Fatalf("resolve duplicate: %v", err)
+	}
+	if err := ensureUniqueUserEmails(db); err != nil {
+		t.Fatalf("ensureUniqueUserEmails returned error: %v", err)
+	}
+
+	err = InsertUser(db, &models.User{UserName: "Impostor", UserEmail: "ALICE@example.com"})
+	if !errors.Is(err, ErrEmailTaken) {
+		t.Fatalf("expected ErrEmailTaken, got %v", err)
+	}
+}
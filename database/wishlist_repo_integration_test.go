@@ -0,0 +1,69 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/akctba/secret-santa-go-api/models"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestInsertWishlistAllowsOneGeneralAndOnePerGroup(t *testing.T) {
+	db := openParticipantTestDB(t)
+
+	for _, wishlist := range []models.Wishlist{
+		{UserID: 1, Title: "Anything"},
+		{UserID: 1, GroupID: "1", Title: "Office party"},
+		{UserID: 2, Title: "Anything"},
+	} {
+		if err := InsertWishlist(db, &wishlist); err != nil {
+			t.Fatalf("InsertWishlist(%+v) returned error: %v", wishlist, err)
+		}
+	}
+
+	for _, wishlist := range []models.Wishlist{
+		{UserID: 1, Title: "Second general"},
+		{UserID: 1, GroupID: "1", Title: "Second for the group"},
+	} {
+		if err := InsertWishlist(db, &wishlist); !errors.Is(err, ErrWishlistExists) {
+			t.Fatalf("expected ErrWishlistExists for %+v, got %v", wishlist, err)
+		}
+	}
+
+	wishlist, err := GetWishlistForGroup(db, 1, "2")
+	if err != nil {
+		t.Fatalf("GetWishlistForGroup returned error: %v", err)
+	}
+	if wishlist.Title != "Anything" || wishlist.GroupID != "" {
+		t.Fatalf("expected the general wishlist for a group without its own, got %+v", wishlist)
+	}
+}
+
+func TestDeleteWishlistRemovesItems(t *testing.T) {
+	db := openParticipantTestDB(t)
+
+	wishlist := models.Wishlist{UserID: 1, Title: "Anything"}
+	if err := InsertWishlist(db, &wishlist); err != nil {
+		t.Fatalf("InsertWishlist returned error: %v", err)
+	}
+	item := models.WishlistItem{WishlistID: wishlist.WishlistID, Title: "Socks", Priority: models.PriorityDefault}
+	if err := InsertWishlistItem(db, &item); err != nil {
+		t.Fatalf("InsertWishlistItem returned error: %v", err)
+	}
+
+	if err := DeleteWishlist(db, 2, wishlist.WishlistID); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected sql.ErrNoRows deleting another user's wishlist, got %v", err)
+	}
+	if err := DeleteWishlist(db, 1, wishlist.WishlistID); err != nil {
+		t.Fatalf("DeleteWishlist returned error: %v", err)
+	}
+
+	var items int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM WishlistItems`).Scan(&items); err != nil {
+		t.Fatalf("count wishlist items: %v", err)
+	}
+	if items != 0 {
+		t.Fatalf("expected the wishlist's items to be deleted, got %d", items)
+	}
+}
@@ -0,0 +1,272 @@
+package draw
+
+import (
+	"fmt"
+	randv2 "math/rand/v2"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxSearchSteps bounds the backtracking search so a heavily constrained
+// group fails fast instead of tying up the caller.
+const maxSearchSteps = 200000
+
+const unassigned = -1
+
+// BacktrackingSolver proves feasibility with a bipartite matching, then
+// searches randomly for an assignment and backtracks out of dead ends.
+type BacktrackingSolver struct {
+	source randv2.Source
+}
+
+// Name implements Solver.
+func (s *BacktrackingSolver) Name() string {
+	return "backtracking"
+}
+
+// Solve implements Solver.
+func (s *BacktrackingSolver) Solve(participants []int, constraints Constraints) (Assignment, error) {
+	p := newProblem(participants, constraints)
+
+	if reasons := p.unreachableReasons(); len(reasons) > 0 {
+		return nil, &UnsatisfiableError{Reasons: reasons}
+	}
+	if reasons := p.matchingReasons(); len(reasons) > 0 {
+		return nil, &UnsatisfiableError{Reasons: reasons}
+	}
+
+	search := &search{
+		problem:  p,
+		rng:      randv2.New(s.source),
+		assigned: make([]int, len(participants)),
+		givenBy:  make([]int, len(participants)),
+	}
+	for i := range participants {
+		search.assigned[i] = unassigned
+		search.givenBy[i] = unassigned
+	}
+
+	if !search.run() {
+		if search.steps > maxSearchSteps {
+			return nil, &UnsatisfiableError{Reasons: []string{"no valid assignment was found within the search limit; try removing some exclusions"}}
+		}
+		return nil, &UnsatisfiableError{Reasons: []string{"every possible assignment contains a pair drawing each other"}}
+	}
+
+	assignment := make(Assignment, len(participants))
+	for giver, receiver := range search.assigned {
+		assignment[participants[giver]] = participants[receiver]
+	}
+	return assignment, nil
+}
+
+// problem is the constraint graph over participant indexes.
+type problem struct {
+	participants []int
+	noReciprocal bool
+	// options[i] lists the receivers giver i may draw, in participant order.
+	options [][]int
+	allowed [][]bool
+}
+
+func newProblem(participants []int, constraints Constraints) *problem {
+	index := make(map[int]int, len(participants))
+	for i, userID := range participants {
+		index[userID] = i
+	}
+
+	p := &problem{
+		participants: participants,
+		noReciprocal: constraints.NoReciprocal,
+		options:      make([][]int, len(participants)),
+		allowed:      make([][]bool, len(participants)),
+	}
+	for i := range participants {
+		p.allowed[i] = make([]bool, len(participants))
+		for j := range participants {
+			p.allowed[i][j] = i != j
+		}
+	}
+
+	for _, pair := range constraints.Forbidden {
+		giver, okGiver := index[pair.Giver]
+		receiver, okReceiver := index[pair.Receiver]
+		if okGiver && okReceiver {
+			p.allowed[giver][receiver] = false
+		}
+	}
+
+	for i := range participants {
+		for j := range participants {
+			if p.allowed[i][j] {
+				p.options[i] = append(p.options[i], j)
+			}
+		}
+	}
+
+	return p
+}
+
+// unreachableReasons reports participants that cannot give or cannot receive at all.
+func (p *problem) unreachableReasons() []string {
+	var reasons []string
+	for i, userID := range p.participants {
+		if len(p.options[i]) == 0 {
+			reasons = append(reasons, fmt.Sprintf("user %d has no eligible recipient", userID))
+		}
+
+		hasGiver := false
+		for j := range p.participants {
+			hasGiver = hasGiver || p.allowed[j][i]
+		}
+		if !hasGiver {
+			reasons = append(reasons, fmt.Sprintf("user %d cannot be drawn by anyone", userID))
+		}
+	}
+
+	if p.noReciprocal && len(p.participants) == 2 {
+		reasons = append(reasons, fmt.Sprintf("users %d and %d can only draw each other", p.participants[0], p.participants[1]))
+	}
+
+	return reasons
+}
+
+// matchingReasons finds a perfect giver/receiver matching or, failing that,
+// a set of givers whose combined options are too few to go around (Hall's condition).
+func (p *problem) matchingReasons() []string {
+	matchedGiver := make([]int, len(p.participants))
+	for j := range matchedGiver {
+		matchedGiver[j] = unassigned
+	}
+
+	var augment func(giver int, visited []bool) bool
+	augment = func(giver int, visited []bool) bool {
+		for _, receiver := range p.options[giver] {
+			if visited[receiver] {
+				continue
+			}
+			visited[receiver] = true
+			if matchedGiver[receiver] == unassigned || augment(matchedGiver[receiver], visited) {
+				matchedGiver[receiver] = giver
+				return true
+			}
+		}
+		return false
+	}
+
+	for giver := range p.participants {
+		if augment(giver, make([]bool, len(p.participants))) {
+			continue
+		}
+
+		// Every giver reachable from the unmatched one by alternating paths
+		// competes for the same receivers, of which there is one too few.
+		givers := map[int]bool{giver: true}
+		receivers := map[int]bool{}
+		queue := []int{giver}
+		for len(queue) > 0 {
+			current := queue[0]
+			queue = queue[1:]
+			for _, receiver := range p.options[current] {
+				if receivers[receiver] {
+					continue
+				}
+				receivers[receiver] = true
+				if next := matchedGiver[receiver]; next != unassigned && !givers[next] {
+					givers[next] = true
+					queue = append(queue, next)
+				}
+			}
+		}
+
+		return []string{fmt.Sprintf("users %s can only draw users %s",
+			p.userList(givers), p.userList(receivers))}
+	}
+
+	return nil
+}
+
+func (p *problem) userList(indexes map[int]bool) string {
+	userIDs := make([]int, 0, len(indexes))
+	for index := range indexes {
+		userIDs = append(userIDs, p.participants[index])
+	}
+	sort.Ints(userIDs)
+
+	parts := make([]string, len(userIDs))
+	for i, userID := range userIDs {
+		parts[i] = strconv.Itoa(userID)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// search holds the state of one randomized backtracking run.
+type search struct {
+	problem  *problem
+	rng      *randv2.Rand
+	assigned []int
+	givenBy  []int
+	steps    int
+}
+
+func (s *search) candidates(giver int) []int {
+	var candidates []int
+	for _, receiver := range s.problem.options[giver] {
+		if s.givenBy[receiver] != unassigned {
+			continue
+		}
+		if s.problem.noReciprocal && s.assigned[receiver] == giver {
+			continue
+		}
+		candidates = append(candidates, receiver)
+	}
+	return candidates
+}
+
+// nextGiver picks the unassigned giver with the fewest remaining candidates.
+func (s *search) nextGiver() (int, []int) {
+	best := unassigned
+	var bestCandidates []int
+	for _, giver := range s.rng.Perm(len(s.assigned)) {
+		if s.assigned[giver] != unassigned {
+			continue
+		}
+		candidates := s.candidates(giver)
+		if best == unassigned || len(candidates) < len(bestCandidates) {
+			best = giver
+			bestCandidates = candidates
+		}
+	}
+	return best, bestCandidates
+}
+
+func (s *search) run() bool {
+	s.steps++
+	if s.steps > maxSearchSteps {
+		return false
+	}
+
+	giver, candidates := s.nextGiver()
+	if giver == unassigned {
+		return true
+	}
+
+	s.rng.Shuffle(len(candidates), func(i, j int) {
+		candidates[i], candidates[j] = candidates[j], candidates[i]
+	})
+	for _, receiver := range candidates {
+		s.assigned[giver] = receiver
+		s.givenBy[receiver] = giver
+		if s.run() {
+			return true
+		}
+		s.assigned[giver] = unassigned
+		s.givenBy[receiver] = unassigned
+
+		if s.steps > maxSearchSteps {
+			return false
+		}
+	}
+	return false
+}
@@ -0,0 +1,155 @@
+package draw
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/akctba/secret-santa-go-api/models"
+)
+
+func assertValidAssignment(t *testing.T, participants []int, constraints Constraints, assignment Assignment) {
+	t.Helper()
+
+	if len(assignment) != len(participants) {
+		t.Fatalf("expected %d assignments, got %d: %v", len(participants), len(assignment), assignment)
+	}
+
+	forbidden := make(map[Pair]bool, len(constraints.Forbidden))
+	for _, pair := range constraints.Forbidden {
+		forbidden[pair] = true
+	}
+
+	received := make(map[int]bool, len(participants))
+	for _, giver := range participants {
+		receiver, ok := assignment[giver]
+		if !ok {
+			t.Fatalf("user %d was not assigned a friend: %v", giver, assignment)
+		}
+		if receiver == giver {
+			t.Fatalf("user %d drew themselves", giver)
+		}
+		if received[receiver] {
+			t.Fatalf("user %d was drawn more than once: %v", receiver, assignment)
+		}
+		received[receiver] = true
+
+		if forbidden[Pair{Giver: giver, Receiver: receiver}] {
+			t.Fatalf("user %d drew forbidden user %d", giver, receiver)
+		}
+		if constraints.NoReciprocal && assignment[receiver] == giver {
+			t.Fatalf("users %d and %d drew each other", giver, receiver)
+		}
+	}
+}
+
+func TestSeededSolverIsDeterministic(t *testing.T) {
+	participants := []int{1, 2, 3, 4, 5, 6}
+	constraints := Constraints{NoReciprocal: true}
+
+	first, err := NewSeededSolver(7).Solve(participants, constraints)
+	if err != nil {
+		t.Fatalf("Solve returned error: %v", err)
+	}
+	second, err := NewSeededSolver(7).Solve(participants, constraints)
+	if err != nil {
+		t.Fatalf("Solve returned error: %v", err)
+	}
+
+	if !reflect.DeepEqual(first, second) {
+		t.Fatalf("expected identical assignments for the same seed, got %v and %v", first, second)
+	}
+}
+
+func TestSolverHonoursConstraints(t *testing.T) {
+	participants := []int{1, 2, 3, 4, 5}
+	constraints := Constraints{
+		Forbidden: ExclusionPairs([]models.Exclusion{
+			{UserID: 1, ExcludedUserID: 2},
+			{UserID: 3, ExcludedUserID: 4},
+			{UserID: 5, ExcludedUserID: 1, OneWay: true},
+		}),
+		NoReciprocal: true,
+	}
+
+	for seed := uint64(0); seed < 50; seed++ {
+		assignment, err := NewSeededSolver(seed).Solve(participants, constraints)
+		if err != nil {
+			t.Fatalf("seed %d: Solve returned error: %v", seed, err)
+		}
+		assertValidAssignment(t, participants, constraints, assignment)
+	}
+}
+
+func TestSolverAllowsMultipleCycles(t *testing.T) {
+	// Users 1 and 2 may only draw each other, as may 3 and 4, so the only
+	// valid draw is two separate pairs rather than one circle.
+	participants := []int{1, 2, 3, 4}
+	constraints := Constraints{Forbidden: []Pair{
+		{Giver: 1, Receiver: 3}, {Giver: 1, Receiver: 4},
+		{Giver: 2, Receiver: 3}, {Giver: 2, Receiver: 4},
+		{Giver: 3, Receiver: 1}, {Giver: 3, Receiver: 2},
+		{Giver: 4, Receiver: 1}, {Giver: 4, Receiver: 2},
+	}}
+
+	assignment, err := NewSolver().Solve(participants, constraints)
+	if err != nil {
+		t.Fatalf("Solve returned error: %v", err)
+	}
+	assertValidAssignment(t, participants, constraints, assignment)
+}
+
+func TestSolverExplainsUnsatisfiableConstraints(t *testing.T) {
+	tests := []struct {
+		name         string
+		participants []int
+		constraints  Constraints
+		wantReason   string
+	}{
+		{
+			name:         "single participant",
+			participants: []int{1},
+			wantReason:   "user 1 has no eligible recipient",
+		},
+		{
+			name:         "nobody can draw a participant",
+			participants: []int{1, 2, 3},
+			constraints: Constraints{Forbidden: []Pair{
+				{Giver: 1, Receiver: 3},
+				{Giver: 2, Receiver: 3},
+			}},
+			wantReason: "user 3 cannot be drawn by anyone",
+		},
+		{
+			name:         "givers compete for too few receivers",
+			participants: []int{1, 2, 3, 4, 5},
+			constraints: Constraints{Forbidden: []Pair{
+				{Giver: 1, Receiver: 3}, {Giver: 1, Receiver: 4}, {Giver: 1, Receiver: 5},
+				{Giver: 2, Receiver: 3}, {Giver: 2, Receiver: 4}, {Giver: 2, Receiver: 5},
+				{Giver: 3, Receiver: 4}, {Giver: 3, Receiver: 5},
+			}},
+			wantReason: "users 1, 2, 3 can only draw users 1, 2",
+		},
+		{
+			name:         "pair without reciprocal draws",
+			participants: []int{1, 2},
+			constraints:  Constraints{NoReciprocal: true},
+			wantReason:   "users 1 and 2 can only draw each other",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NewSeededSolver(1).Solve(tc.participants, tc.constraints)
+
+			var unsatisfiable *UnsatisfiableError
+			if !errors.As(err, &unsatisfiable) {
+				t.Fatalf("expected *UnsatisfiableError, got %v", err)
+			}
+			if !strings.Contains(unsatisfiable.Error(), tc.wantReason) {
+				t.Fatalf("expected reason %q, got %q", tc.wantReason, unsatisfiable.Error())
+			}
+		})
+	}
+}
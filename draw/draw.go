@@ -0,0 +1,81 @@
+// Package draw assigns every participant of a Secret Santa group a secret friend.
+package draw
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	randv2 "math/rand/v2"
+	"strings"
+
+	"github.com/akctba/secret-santa-go-api/models"
+)
+
+// Pair is a directed giver -> receiver relation between two user IDs.
+type Pair struct {
+	Giver    int
+	Receiver int
+}
+
+// Constraints restrict which assignments a Solver may return.
+type Constraints struct {
+	// Forbidden lists giver -> receiver pairs that must not be assigned.
+	Forbidden []Pair
+	// NoReciprocal forbids two participants from drawing each other.
+	NoReciprocal bool
+}
+
+// Assignment maps each giver's user ID to the user ID of their secret friend.
+type Assignment map[int]int
+
+// Solver produces a derangement of participants that honours the constraints.
+type Solver interface {
+	// Name identifies the algorithm, e.g. for audit records.
+	Name() string
+	// Solve returns an assignment covering every participant or an
+	// *UnsatisfiableError when the constraints admit none.
+	Solve(participants []int, constraints Constraints) (Assignment, error)
+}
+
+// UnsatisfiableError explains why no assignment satisfies the constraints.
+type UnsatisfiableError struct {
+	Reasons []string
+}
+
+func (e *UnsatisfiableError) Error() string {
+	return "draw constraints are unsatisfiable: " + strings.Join(e.Reasons, "; ")
+}
+
+// cryptoSource implements randv2.Source using crypto/rand for cryptographically secure randomness.
+type cryptoSource struct{}
+
+func (cryptoSource) Uint64() uint64 {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(fmt.Errorf("crypto/rand: failed to generate random number: %w", err))
+	}
+	return binary.LittleEndian.Uint64(b[:])
+}
+
+// NewSolver returns the production solver backed by crypto/rand.
+func NewSolver() Solver {
+	return &BacktrackingSolver{source: cryptoSource{}}
+}
+
+// NewSeededSolver returns a solver whose output is fully determined by seed.
+// It is meant for tests and must not be used for real draws.
+func NewSeededSolver(seed uint64) Solver {
+	return &BacktrackingSolver{source: randv2.NewPCG(seed, seed)}
+}
+
+// ExclusionPairs expands group exclusions into the pairs a draw must avoid.
+func ExclusionPairs(exclusions []models.Exclusion) []Pair {
+	pairs := make([]Pair, 0, len(exclusions)*2)
+	for _, exclusion := range exclusions {
+		pairs = append(pairs, Pair{Giver: exclusion.UserID, Receiver: exclusion.ExcludedUserID})
+		if !exclusion.OneWay {
+			pairs = append(pairs, Pair{Giver: exclusion.ExcludedUserID, Receiver: exclusion.UserID})
+		}
+	}
+	return pairs
+}
@@ -1,15 +1,22 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/akctba/secret-santa-go-api/auth"
+	"github.com/akctba/secret-santa-go-api/controllers"
 	"github.com/akctba/secret-santa-go-api/database"
+	"github.com/akctba/secret-santa-go-api/notify"
+	"github.com/akctba/secret-santa-go-api/oidc"
 	"github.com/akctba/secret-santa-go-api/routes"
+	"github.com/akctba/secret-santa-go-api/scheduler"
 	_ "github.com/mattn/go-sqlite3"
 
 	"github.com/gorilla/mux"
@@ -24,6 +31,37 @@ func main() {
 
 	database.CreateTables()
 
+	mailer, err := notify.NewMailerFromEnv(auth.ResolvedEnvironment())
+	if err != nil {
+		log.Fatalf("invalid mail configuration: %v", err)
+	}
+	controllers.SetMailer(mailer)
+	if err := controllers.SetPasswordResetURL(os.Getenv("PASSWORD_RESET_URL")); err != nil {
+		log.Fatalf("invalid PASSWORD_RESET_URL: %v", err)
+	}
+	if err := controllers.SetEmailVerificationURL(os.Getenv("EMAIL_VERIFICATION_URL")); err != nil {
+		log.Fatalf("invalid EMAIL_VERIFICATION_URL: %v", err)
+	}
+	if err := controllers.SetMagicLinkURL(os.Getenv("MAGIC_LINK_URL")); err != nil {
+		log.Fatalf("invalid MAGIC_LINK_URL: %v", err)
+	}
+	if value := os.Getenv("REQUIRE_EMAIL_VERIFICATION"); value != "" {
+		required, err := strconv.ParseBool(value)
+		if err != nil {
+			log.Fatalf("invalid REQUIRE_EMAIL_VERIFICATION: %v", err)
+		}
+		controllers.SetRequireEmailVerification(required)
+	}
+	providers, err := oidc.ProvidersFromEnv()
+	if err != nil {
+		log.Fatalf("invalid OpenID Connect configuration: %v", err)
+	}
+	controllers.SetOIDCProviders(providers)
+
+	if err := startDrawScheduler(os.Getenv("DRAW_SCHEDULER_INTERVAL")); err != nil {
+		log.Fatalf("invalid draw scheduler configuration: %v", err)
+	}
+
 	r := mux.NewRouter()
 	r.Handle("/docs", http.RedirectHandler("/docs/", http.StatusMovedPermanently))
 	r.PathPrefix("/docs/").Handler(http.StripPrefix("/docs", http.FileServer(http.Dir("docs"))))
@@ -43,6 +81,28 @@ func main() {
 	}
 }
 
+// startDrawScheduler draws groups in the background once their date_draw has
+// passed. An interval of 0 disables the scheduler, e.g. when another
+// deployment takes care of it.
+func startDrawScheduler(interval string) error {
+	drawScheduler := scheduler.New(controllers.RunScheduledDraw)
+
+	if interval != "" {
+		parsed, err := time.ParseDuration(interval)
+		if err != nil {
+			return fmt.Errorf("DRAW_SCHEDULER_INTERVAL: %w", err)
+		}
+		if parsed <= 0 {
+			log.Print("DRAW_SCHEDULER_INTERVAL is 0; scheduled draws are disabled")
+			return nil
+		}
+		drawScheduler.Interval = parsed
+	}
+
+	go drawScheduler.Start(context.Background())
+	return nil
+}
+
 func corsHandler(next http.Handler) http.Handler {
 	allowedOrigins := parseAllowedOrigins(os.Getenv("CORS_ALLOWED_ORIGINS"))
 	if len(allowedOrigins) == 0 {
@@ -52,7 +112,7 @@ func corsHandler(next http.Handler) http.Handler {
 	return cors.New(cors.Options{
 		AllowedOrigins:   allowedOrigins,
 		AllowedMethods:   []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions},
-		AllowedHeaders:   []string{"Authorization", "Content-Type"},
+		AllowedHeaders:   []string{"Authorization", "Content-Type", "Idempotency-Key"},
 		AllowCredentials: true,
 		MaxAge:           300,
 	}).Handler(next)
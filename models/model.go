@@ -3,11 +3,18 @@ package models
 import "time"
 
 type Group struct {
-	GroupID       string    `json:"group_id"`
-	Name          string    `json:"name"`
-	DateCreated   time.Time `json:"date_created"`
-	DateDraw      time.Time `json:"date_draw"`
-	CreatorUserID int       `json:"creator_user_id"`
+	GroupID       string     `json:"group_id"`
+	Name          string     `json:"name"`
+	DateCreated   time.Time  `json:"date_created"`
+	DateDraw      time.Time  `json:"date_draw"`
+	CreatorUserID int        `json:"creator_user_id"`
+	BudgetMin     float64    `json:"budget_min,omitempty"`
+	BudgetMax     float64    `json:"budget_max,omitempty"`
+	Currency      string     `json:"currency,omitempty"`
+	EventDate     *time.Time `json:"event_date,omitempty"`
+	Location      string     `json:"location,omitempty"`
+	MeetingURL    string     `json:"meeting_url,omitempty"`
+	Description   string     `json:"description,omitempty"`
 }
 
 type UserSignin struct {
@@ -16,12 +23,13 @@ type UserSignin struct {
 }
 
 type User struct {
-	UserID      int       `json:"user_id"`
-	UserName    string    `json:"user_name"`
-	UserEmail   string    `json:"user_email"`
-	Password    string    `json:"password"`
-	Gender      string    `json:"gender"`
-	DateOfBirth time.Time `json:"date_of_birth"`
+	UserID          int        `json:"user_id"`
+	UserName        string     `json:"user_name"`
+	UserEmail       string     `json:"user_email"`
+	Password        string     `json:"password"`
+	Gender          string     `json:"gender"`
+	DateOfBirth     time.Time  `json:"date_of_birth"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
 }
 
 type Participant struct {
@@ -31,17 +39,160 @@ type Participant struct {
 	FriendUserID int       `json:"friend_user_id"`
 }
 
+// Participant roles, from least to most privileged. The group's creator is its owner.
+const (
+	RoleMember    = "member"
+	RoleOrganizer = "organizer"
+	RoleOwner     = "owner"
+)
+
+type ParticipantRole struct {
+	GroupID string `json:"group_id"`
+	UserID  int    `json:"user_id"`
+	Role    string `json:"role"`
+}
+
 type ParticipantRequest struct {
 	GroupID string `json:"group_id"`
 	UserID  int    `json:"user_id"`
 }
 
 type UserParticipant struct {
+	UserID        int       `json:"user_id"`
+	GroupID       string    `json:"group_id"`
+	UserName      string    `json:"user_name"`
+	UserEmail     string    `json:"user_email"`
+	Gender        string    `json:"gender"`
+	DateOfBirth   time.Time `json:"date_of_birth"`
+	JoinedAt      time.Time `json:"joined_at"`
+	Role          string    `json:"role"`
+	EmailVerified bool      `json:"email_verified"`
+}
+
+type Exclusion struct {
+	ExclusionID    int       `json:"exclusion_id"`
+	GroupID        string    `json:"group_id"`
+	UserID         int       `json:"user_id"`
+	ExcludedUserID int       `json:"excluded_user_id"`
+	OneWay         bool      `json:"one_way"`
+	DateCreated    time.Time `json:"date_created"`
+}
+
+// RefreshToken is a stored refresh token. Tokens rotated from the same
+// sign-in share a family, so a stolen token can end the whole session.
+type RefreshToken struct {
+	TokenID   string     `json:"token_id"`
+	UserID    int        `json:"user_id"`
+	FamilyID  string     `json:"family_id"`
+	IssuedAt  time.Time  `json:"issued_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RotatedAt *time.Time `json:"rotated_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// UserIdentity links a user to their account at an OpenID Connect provider,
+// which identifies them by its own subject ID.
+type UserIdentity struct {
+	Provider    string    `json:"provider"`
+	Subject     string    `json:"subject"`
 	UserID      int       `json:"user_id"`
-	GroupID     string    `json:"group_id"`
-	UserName    string    `json:"user_name"`
-	UserEmail   string    `json:"user_email"`
-	Gender      string    `json:"gender"`
-	DateOfBirth time.Time `json:"date_of_birth"`
-	JoinedAt    time.Time `json:"joined_at"`
+	DateCreated time.Time `json:"date_created"`
+}
+
+// OIDCSignin is a sign-in started with an OpenID Connect provider that waits
+// for the provider to send the user back. Only the state's hash is stored.
+type OIDCSignin struct {
+	StateHash    string    `json:"state_hash"`
+	Provider     string    `json:"provider"`
+	Nonce        string    `json:"nonce"`
+	CodeVerifier string    `json:"code_verifier"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+type Draw struct {
+	DrawID         int        `json:"draw_id"`
+	GroupID        string     `json:"group_id"`
+	RunAt          time.Time  `json:"run_at"`
+	RunBy          int        `json:"run_by"`
+	Algorithm      string     `json:"algorithm"`
+	IdempotencyKey string     `json:"-"`
+	ResetAt        *time.Time `json:"reset_at,omitempty"`
+	ResetBy        int        `json:"reset_by,omitempty"`
+}
+
+type Invite struct {
+	InviteID    int        `json:"invite_id"`
+	GroupID     string     `json:"group_id"`
+	Code        string     `json:"code"`
+	CreatedBy   int        `json:"created_by"`
+	DateCreated time.Time  `json:"date_created"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	MaxUses     int        `json:"max_uses"`
+	Uses        int        `json:"uses"`
+	RevokedAt   *time.Time `json:"revoked_at,omitempty"`
+}
+
+// Email invitation statuses.
+const (
+	InvitationPending  = "pending"
+	InvitationAccepted = "accepted"
+)
+
+type EmailInvitation struct {
+	InvitationID int        `json:"invitation_id"`
+	GroupID      string     `json:"group_id"`
+	Email        string     `json:"email"`
+	InvitedBy    int        `json:"invited_by"`
+	DateCreated  time.Time  `json:"date_created"`
+	Status       string     `json:"status"`
+	UserID       int        `json:"user_id,omitempty"`
+	AcceptedAt   *time.Time `json:"accepted_at,omitempty"`
+}
+
+// Wishlist item priorities, from a must-have to nice to have.
+const (
+	PriorityHighest = 1
+	PriorityDefault = 3
+	PriorityLowest  = 5
+)
+
+// Wishlist belongs to a user. Without a group_id it applies to every group the
+// user takes part in; a group's own wishlist takes precedence over it.
+type Wishlist struct {
+	WishlistID  int            `json:"wishlist_id"`
+	UserID      int            `json:"user_id"`
+	GroupID     string         `json:"group_id,omitempty"`
+	Title       string         `json:"title"`
+	DateCreated time.Time      `json:"date_created"`
+	Items       []WishlistItem `json:"items"`
+}
+
+type WishlistItem struct {
+	ItemID      int       `json:"item_id"`
+	WishlistID  int       `json:"wishlist_id"`
+	Title       string    `json:"title"`
+	URL         string    `json:"url,omitempty"`
+	PriceMin    float64   `json:"price_min,omitempty"`
+	PriceMax    float64   `json:"price_max,omitempty"`
+	Priority    int       `json:"priority"`
+	Notes       string    `json:"notes,omitempty"`
+	DateCreated time.Time `json:"date_created"`
+}
+
+// Sides of an anonymous message thread between a giver and the friend they drew.
+const (
+	SenderGiver    = "giver"
+	SenderReceiver = "receiver"
+)
+
+// Message is never returned as is: the receiver must not learn who the giver is.
+type Message struct {
+	MessageID      int       `json:"message_id"`
+	GroupID        string    `json:"group_id"`
+	DrawID         int       `json:"draw_id"`
+	GiverUserID    int       `json:"-"`
+	ReceiverUserID int       `json:"receiver_user_id"`
+	Sender         string    `json:"sender"`
+	Body           string    `json:"body"`
+	DateCreated    time.Time `json:"date_created"`
 }
@@ -0,0 +1,22 @@
+package notify
+
+import "log"
+
+// LogMailer writes messages to a logger instead of delivering them.
+type LogMailer struct {
+	Logger *log.Logger
+}
+
+// Send logs the recipient, subject and plain text body of msg.
+func (m *LogMailer) Send(msg Message) error {
+	m.Logger.Printf("email to %s\nSubject: %s\n\n%s", msg.To, msg.Subject, msg.Text)
+	return nil
+}
+
+// DiscardMailer drops every message.
+type DiscardMailer struct{}
+
+// Send does nothing.
+func (DiscardMailer) Send(Message) error {
+	return nil
+}
@@ -0,0 +1,123 @@
+// Package notify sends the service's outbound email notifications.
+package notify
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"log"
+	"os"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+// Kind identifies one of the notifications the service sends.
+type Kind string
+
+const (
+	// KindAdded tells a user they were added to a group.
+	KindAdded Kind = "added"
+	// KindDrawn tells a participant the draw happened and who they drew.
+	KindDrawn Kind = "drawn"
+	// KindReminder reminds a participant about an upcoming exchange.
+	KindReminder Kind = "reminder"
+	// KindReassigned tells a participant their secret friend changed because
+	// the friend they drew left the group.
+	KindReassigned Kind = "reassigned"
+	// KindPasswordReset sends a user the link to choose a new password.
+	KindPasswordReset Kind = "password_reset"
+	// KindVerifyEmail sends a user the link to confirm their email address.
+	KindVerifyEmail Kind = "verify_email"
+	// KindMagicLink sends a user a link that signs them in without a password.
+	KindMagicLink Kind = "magic_link"
+)
+
+// Message is a rendered email with plain text and HTML bodies.
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Mailer delivers messages.
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// Data is what the notification templates can refer to. FriendName is only
+// set once the group has been drawn. Token, TokenURL and ExpiresAt are only
+// set for password resets, email verification and login links; TokenURL is
+// empty when no page is configured for the token.
+type Data struct {
+	UserName   string
+	GroupID    string
+	GroupName  string
+	DateDraw   time.Time
+	FriendName string
+	Token      string
+	TokenURL   string
+	ExpiresAt  time.Time
+}
+
+//go:embed templates
+var templateFS embed.FS
+
+var (
+	textTemplates = texttemplate.Must(texttemplate.ParseFS(templateFS, "templates/*.txt"))
+	htmlTemplates = htmltemplate.Must(htmltemplate.ParseFS(templateFS, "templates/*.html"))
+)
+
+// Render builds the message of the given kind for one recipient. Each text
+// template defines the subject and the plain text body; the HTML template of
+// the same name holds the HTML body.
+func Render(kind Kind, to string, data Data) (Message, error) {
+	msg := Message{To: to}
+
+	var subject bytes.Buffer
+	if err := textTemplates.ExecuteTemplate(&subject, string(kind)+"_subject", data); err != nil {
+		return msg, fmt.Errorf("render %s subject: %w", kind, err)
+	}
+	msg.Subject = strings.TrimSpace(subject.String())
+
+	var text bytes.Buffer
+	if err := textTemplates.ExecuteTemplate(&text, string(kind)+".txt", data); err != nil {
+		return msg, fmt.Errorf("render %s text: %w", kind, err)
+	}
+	msg.Text = text.String()
+
+	var html bytes.Buffer
+	if err := htmlTemplates.ExecuteTemplate(&html, string(kind)+".html", data); err != nil {
+		return msg, fmt.Errorf("render %s html: %w", kind, err)
+	}
+	msg.HTML = html.String()
+
+	return msg, nil
+}
+
+// NewMailerFromEnv picks the mailer for the given APP_ENV. SMTP is used when
+// SMTP_HOST is set. Without it, LOCAL logs every message (or appends it to
+// MAIL_LOG_FILE) so flows can be followed during development, while other
+// environments drop messages rather than log assignments in plain text.
+func NewMailerFromEnv(env string) (Mailer, error) {
+	if os.Getenv("SMTP_HOST") != "" {
+		return NewSMTPMailerFromEnv()
+	}
+
+	if env != "LOCAL" {
+		log.Print("SMTP_HOST not set; email notifications are disabled")
+		return DiscardMailer{}, nil
+	}
+
+	if path := os.Getenv("MAIL_LOG_FILE"); path != "" {
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+		if err != nil {
+			return nil, fmt.Errorf("open MAIL_LOG_FILE: %w", err)
+		}
+		return &LogMailer{Logger: log.New(file, "", log.LstdFlags)}, nil
+	}
+
+	return &LogMailer{Logger: log.Default()}, nil
+}
@@ -0,0 +1,72 @@
+package notify
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderBuildsEveryNotification(t *testing.T) {
+	data := Data{
+		UserName:   "Alice",
+		GroupName:  "Office <Party>",
+		DateDraw:   time.Date(2026, time.December, 10, 0, 0, 0, 0, time.UTC),
+		FriendName: "Bob",
+	}
+
+	for _, kind := range []Kind{KindAdded, KindDrawn, KindReminder, KindReassigned} {
+		msg, err := Render(kind, "alice@example.com", data)
+		if err != nil {
+			t.Fatalf("Render(%s) returned error: %v", kind, err)
+		}
+
+		if msg.To != "alice@example.com" || !strings.Contains(msg.Subject, "Office <Party>") {
+			t.Fatalf("%s: unexpected message header %+v", kind, msg)
+		}
+		if strings.Contains(msg.Subject, "\n") {
+			t.Fatalf("%s: expected a single-line subject, got %q", kind, msg.Subject)
+		}
+		if !strings.Contains(msg.Text, "Hi Alice") || !strings.Contains(msg.HTML, "Office &lt;Party&gt;") {
+			t.Fatalf("%s: unexpected bodies:\n%s\n%s", kind, msg.Text, msg.HTML)
+		}
+	}
+}
+
+func TestRenderDrawnNamesFriend(t *testing.T) {
+	msg, err := Render(KindDrawn, "alice@example.com", Data{UserName: "Alice", GroupName: "Office", FriendName: "Bob"})
+	if err != nil {
+		t.Fatalf("Render returned error: %v", err)
+	}
+
+	if !strings.Contains(msg.Text, "secret friend is Bob") || !strings.Contains(msg.HTML, "<strong>Bob</strong>") {
+		t.Fatalf("expected the drawn friend in both bodies, got:\n%s\n%s", msg.Text, msg.HTML)
+	}
+}
+
+func TestRenderPasswordResetLinksToResetPage(t *testing.T) {
+	data := Data{
+		UserName:  "Alice",
+		Token:     "abc123",
+		ExpiresAt: time.Date(2026, time.December, 10, 18, 0, 0, 0, time.UTC),
+	}
+
+	msg, err := Render(KindPasswordReset, "alice@example.com", data)
+	if err != nil {
+		t.Fatalf("Render returned error: %v", err)
+	}
+	if !strings.Contains(msg.Text, "Use this code to choose a new password: abc123") {
+		t.Fatalf("expected the code without a reset page, got:\n%s", msg.Text)
+	}
+
+	data.TokenURL = "https://app.example.com/reset?token=abc123"
+	msg, err = Render(KindPasswordReset, "alice@example.com", data)
+	if err != nil {
+		t.Fatalf("Render returned error: %v", err)
+	}
+	if !strings.Contains(msg.Text, data.TokenURL) || !strings.Contains(msg.HTML, `href="https://app.example.com/reset?token=abc123"`) {
+		t.Fatalf("expected the reset link, got:\n%s\n%s", msg.Text, msg.HTML)
+	}
+	if !strings.Contains(msg.Text, "December 10, 2026 18:00 UTC") {
+		t.Fatalf("expected the expiry, got:\n%s", msg.Text)
+	}
+}
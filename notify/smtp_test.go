@@ -0,0 +1,160 @@
+package notify
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+)
+
+// fakeSMTPServer accepts a single SMTP session and records the envelope and
+// message data it receives.
+type fakeSMTPServer struct {
+	listener net.Listener
+	done     chan struct{}
+
+	from string
+	to   []string
+	data string
+}
+
+func startFakeSMTPServer(t *testing.T) *fakeSMTPServer {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() {
+		listener.Close()
+	})
+
+	server := &fakeSMTPServer{listener: listener, done: make(chan struct{})}
+	go server.serve()
+	return server
+}
+
+func (s *fakeSMTPServer) serve() {
+	defer close(s.done)
+
+	conn, err := s.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	reply := func(line string) {
+		conn.Write([]byte(line + "\r\n"))
+	}
+
+	reply("220 localhost ESMTP")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command := strings.TrimRight(line, "\r\n")
+
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(command, "MAIL FROM:"):
+			s.from = strings.TrimPrefix(command, "MAIL FROM:")
+			reply("250 OK")
+		case strings.HasPrefix(command, "RCPT TO:"):
+			s.to = append(s.to, strings.TrimPrefix(command, "RCPT TO:"))
+			reply("250 OK")
+		case command == "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				dataLine, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if dataLine == ".\r\n" {
+					break
+				}
+				data.WriteString(dataLine)
+			}
+			s.data = data.String()
+			reply("250 OK")
+		case command == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Command not implemented")
+		}
+	}
+}
+
+func TestSMTPMailerSendsMultipartMessage(t *testing.T) {
+	server := startFakeSMTPServer(t)
+
+	host, port, err := net.SplitHostPort(server.listener.Addr().String())
+	if err != nil {
+		t.Fatalf("split fake server address: %v", err)
+	}
+	t.Setenv("SMTP_HOST", host)
+	t.Setenv("SMTP_PORT", port)
+	t.Setenv("SMTP_FROM", "Secret Santa <santa@example.com>")
+	t.Setenv("SMTP_USERNAME", "")
+
+	mailer, err := NewMailerFromEnv("PROD")
+	if err != nil {
+		t.Fatalf("NewMailerFromEnv returned error: %v", err)
+	}
+	if _, ok := mailer.(*SMTPMailer); !ok {
+		t.Fatalf("expected an SMTP mailer when SMTP_HOST is set, got %T", mailer)
+	}
+
+	msg, err := Render(KindDrawn, "alice@example.com", Data{UserName: "Alice", GroupName: "Office", FriendName: "Bob"})
+	if err != nil {
+		t.Fatalf("Render returned error: %v", err)
+	}
+
+	if err := mailer.Send(msg); err != nil {
+		t.Fatalf("Send returned error: %v", err)
+	}
+	<-server.done
+
+	if server.from != "<santa@example.com>" {
+		t.Fatalf("unexpected envelope sender %q", server.from)
+	}
+	if len(server.to) != 1 || server.to[0] != "<alice@example.com>" {
+		t.Fatalf("unexpected envelope recipients %v", server.to)
+	}
+	for _, want := range []string{
+		"Subject: Names have been drawn in Office",
+		"Content-Type: multipart/alternative",
+		"Content-Type: text/plain; charset=utf-8",
+		"Content-Type: text/html; charset=utf-8",
+		"secret friend is Bob",
+	} {
+		if !strings.Contains(server.data, want) {
+			t.Fatalf("expected message data to contain %q, got:\n%s", want, server.data)
+		}
+	}
+}
+
+func TestNewMailerFromEnvWithoutSMTP(t *testing.T) {
+	t.Setenv("SMTP_HOST", "")
+	t.Setenv("MAIL_LOG_FILE", "")
+
+	mailer, err := NewMailerFromEnv("LOCAL")
+	if err != nil {
+		t.Fatalf("NewMailerFromEnv returned error: %v", err)
+	}
+	if _, ok := mailer.(*LogMailer); !ok {
+		t.Fatalf("expected LOCAL to log emails, got %T", mailer)
+	}
+
+	mailer, err = NewMailerFromEnv("PROD")
+	if err != nil {
+		t.Fatalf("NewMailerFromEnv returned error: %v", err)
+	}
+	if _, ok := mailer.(DiscardMailer); !ok {
+		t.Fatalf("expected PROD without SMTP to discard emails, got %T", mailer)
+	}
+}
@@ -0,0 +1,96 @@
+package oidc
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+const providersEnvVar = "OIDC_PROVIDERS"
+
+var providerNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// ProvidersFromEnv configures the providers listed in OIDC_PROVIDERS
+// (comma-separated names such as google,microsoft). Each provider NAME reads
+// OIDC_NAME_ISSUER, OIDC_NAME_CLIENT_ID, OIDC_NAME_CLIENT_SECRET,
+// OIDC_NAME_REDIRECT_URL and OIDC_NAME_SCOPES, with dashes in the name
+// written as underscores. It returns no providers when OIDC_PROVIDERS is not
+// set.
+func ProvidersFromEnv() ([]*Provider, error) {
+	var providers []*Provider
+	seen := map[string]bool{}
+
+	for _, name := range strings.Split(os.Getenv(providersEnvVar), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if !providerNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("%s: invalid provider name %q", providersEnvVar, name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("%s: provider %q is listed twice", providersEnvVar, name)
+		}
+		seen[name] = true
+
+		provider, err := providerFromEnv(name)
+		if err != nil {
+			return nil, err
+		}
+		providers = append(providers, provider)
+	}
+
+	return providers, nil
+}
+
+func providerFromEnv(name string) (*Provider, error) {
+	prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+	env := func(key string) string {
+		return strings.TrimSpace(os.Getenv(prefix + key))
+	}
+
+	provider := &Provider{
+		Name:         name,
+		Issuer:       env("ISSUER"),
+		ClientID:     env("CLIENT_ID"),
+		ClientSecret: env("CLIENT_SECRET"),
+		RedirectURL:  env("REDIRECT_URL"),
+		Scopes:       strings.Fields(env("SCOPES")),
+	}
+
+	if !isSecureURL(provider.Issuer) {
+		return nil, fmt.Errorf("%sISSUER must be an https URL", prefix)
+	}
+	if provider.ClientID == "" {
+		return nil, fmt.Errorf("%sCLIENT_ID must be set", prefix)
+	}
+	if !isSecureURL(provider.RedirectURL) {
+		return nil, fmt.Errorf("%sREDIRECT_URL must be an https URL", prefix)
+	}
+	if len(provider.Scopes) > 0 && !slices.Contains(provider.Scopes, "openid") {
+		return nil, fmt.Errorf("%sSCOPES must include openid", prefix)
+	}
+
+	return provider, nil
+}
+
+// isSecureURL reports whether raw is an https URL, or an http one on the
+// local machine for development.
+func isSecureURL(raw string) bool {
+	link, err := url.Parse(raw)
+	if err != nil || link.Host == "" {
+		return false
+	}
+	switch link.Scheme {
+	case "https":
+		return true
+	case "http":
+		host := link.Hostname()
+		return host == "localhost" || host == "127.0.0.1" || host == "::1"
+	default:
+		return false
+	}
+}
@@ -0,0 +1,319 @@
+// Package oidc signs users in with OpenID Connect providers such as Google or
+// Microsoft, using the authorization code flow with PKCE.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	defaultHTTPTimeout = 10 * time.Second
+	// idTokenLeeway is the clock skew tolerated between us and the provider.
+	idTokenLeeway = time.Minute
+	// keysRefreshInterval limits how often an unknown kid makes us fetch the
+	// provider's keys again.
+	keysRefreshInterval = time.Minute
+	// maxResponseSize caps what is read from the provider.
+	maxResponseSize = 1 << 20
+)
+
+var defaultScopes = []string{"openid", "email", "profile"}
+
+// Provider is an OpenID Connect provider users can sign in with. Its
+// endpoints and keys are discovered from the issuer on first use.
+type Provider struct {
+	// Name identifies the provider in API paths, e.g. "google".
+	Name string
+	// Issuer is the provider's issuer URL, which its ID tokens must carry.
+	Issuer string
+	// ClientID and ClientSecret are the credentials of this API's client
+	// registration. Public clients have no secret.
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is the page of the web app the provider sends users back
+	// to with the authorization code. It must be registered with the provider.
+	RedirectURL string
+	// Scopes requested, defaulting to openid, email and profile.
+	Scopes []string
+	// HTTPClient talks to the provider. http.Client with a timeout is used
+	// when it is nil.
+	HTTPClient *http.Client
+
+	mu            sync.Mutex
+	metadata      *providerMetadata
+	keys          map[string]any
+	keysFetchedAt time.Time
+}
+
+// AuthRequest holds the values that tie an authorization request to its
+// callback. They must be kept by the caller between the two steps.
+type AuthRequest struct {
+	State        string
+	Nonce        string
+	CodeVerifier string
+}
+
+// Claims is what the provider tells us about the user who signed in.
+type Claims struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+type providerMetadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type tokenResponse struct {
+	IDToken string `json:"id_token"`
+}
+
+type idTokenClaims struct {
+	jwt.RegisteredClaims
+	Nonce           string `json:"nonce"`
+	AuthorizedParty string `json:"azp"`
+	Email           string `json:"email"`
+	EmailVerified   any    `json:"email_verified"`
+	Name            string `json:"name"`
+}
+
+// NewAuthRequest generates a random state, nonce and PKCE code verifier.
+func NewAuthRequest() (AuthRequest, error) {
+	var values [3]string
+	for i := range values {
+		value := make([]byte, 32)
+		if _, err := rand.Read(value); err != nil {
+			return AuthRequest{}, err
+		}
+		values[i] = base64.RawURLEncoding.EncodeToString(value)
+	}
+	return AuthRequest{State: values[0], Nonce: values[1], CodeVerifier: values[2]}, nil
+}
+
+// CodeChallenge returns the S256 PKCE challenge for the verifier.
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// AuthorizationURL returns the provider page the user signs in on.
+func (p *Provider) AuthorizationURL(ctx context.Context, request AuthRequest) (string, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	link, err := url.Parse(metadata.AuthorizationEndpoint)
+	if err != nil {
+		return "", fmt.Errorf("authorization endpoint: %w", err)
+	}
+
+	scopes := p.Scopes
+	if len(scopes) == 0 {
+		scopes = defaultScopes
+	}
+
+	query := link.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", p.ClientID)
+	query.Set("redirect_uri", p.RedirectURL)
+	query.Set("scope", strings.Join(scopes, " "))
+	query.Set("state", request.State)
+	query.Set("nonce", request.Nonce)
+	query.Set("code_challenge", CodeChallenge(request.CodeVerifier))
+	query.Set("code_challenge_method", "S256")
+	link.RawQuery = query.Encode()
+	return link.String(), nil
+}
+
+// Exchange swaps the authorization code for an ID token and returns its
+// verified claims. The request must be the one the code was issued for.
+func (p *Provider) Exchange(ctx context.Context, code string, request AuthRequest) (Claims, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return Claims{}, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.RedirectURL},
+		"client_id":     {p.ClientID},
+		"code_verifier": {request.CodeVerifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Claims{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.ClientID), url.QueryEscape(p.ClientSecret))
+	}
+
+	var response tokenResponse
+	if err := p.doJSON(req, &response); err != nil {
+		return Claims{}, fmt.Errorf("token request: %w", err)
+	}
+	if response.IDToken == "" {
+		return Claims{}, errors.New("token response has no id_token")
+	}
+
+	return p.verifyIDToken(ctx, response.IDToken, request.Nonce)
+}
+
+func (p *Provider) verifyIDToken(ctx context.Context, idToken string, nonce string) (Claims, error) {
+	parser := jwt.NewParser(
+		jwt.WithValidMethods([]string{"RS256", "ES256"}),
+		jwt.WithIssuer(p.Issuer),
+		jwt.WithAudience(p.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(idTokenLeeway),
+	)
+
+	claims := &idTokenClaims{}
+	_, err := parser.ParseWithClaims(idToken, claims, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		return p.key(ctx, kid)
+	})
+	if err != nil {
+		return Claims{}, fmt.Errorf("id token: %w", err)
+	}
+
+	if claims.Nonce != nonce {
+		return Claims{}, errors.New("id token: nonce does not match")
+	}
+	if len(claims.Audience) > 1 && claims.AuthorizedParty != p.ClientID {
+		return Claims{}, errors.New("id token: issued to another client")
+	}
+	if claims.Subject == "" {
+		return Claims{}, errors.New("id token: no subject")
+	}
+
+	return Claims{
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified == true || claims.EmailVerified == "true",
+		Name:          claims.Name,
+	}, nil
+}
+
+// discover fetches the provider's metadata once. Failures are not cached, so
+// a provider that was down is tried again on the next sign-in.
+func (p *Provider) discover(ctx context.Context) (*providerMetadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.metadata != nil {
+		return p.metadata, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		strings.TrimSuffix(p.Issuer, "/")+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var metadata providerMetadata
+	if err := p.doJSON(req, &metadata); err != nil {
+		return nil, fmt.Errorf("discovery: %w", err)
+	}
+	if metadata.Issuer != p.Issuer {
+		return nil, fmt.Errorf("discovery: issuer %q does not match %q", metadata.Issuer, p.Issuer)
+	}
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JWKSURI == "" {
+		return nil, errors.New("discovery: missing endpoints")
+	}
+
+	p.metadata = &metadata
+	return p.metadata, nil
+}
+
+// key returns the provider's signing key with the given ID, fetching the
+// provider's keys again when it is unknown, since providers rotate them.
+func (p *Provider) key(ctx context.Context, kid string) (any, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.lookupKey(kid); ok {
+		return key, nil
+	}
+	if time.Since(p.keysFetchedAt) < keysRefreshInterval {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, metadata.JWKSURI, nil)
+	if err != nil {
+		return nil, err
+	}
+	var set jsonWebKeySet
+	if err := p.doJSON(req, &set); err != nil {
+		return nil, fmt.Errorf("signing keys: %w", err)
+	}
+
+	p.keys = set.signingKeys()
+	p.keysFetchedAt = time.Now()
+
+	if key, ok := p.lookupKey(kid); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// lookupKey finds a cached key. Tokens without a kid are accepted when the
+// provider has a single key.
+func (p *Provider) lookupKey(kid string) (any, bool) {
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, true
+		}
+	}
+	key, ok := p.keys[kid]
+	return key, ok
+}
+
+func (p *Provider) doJSON(req *http.Request, target any) error {
+	client := p.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: defaultHTTPTimeout}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s: %s", req.URL.Redacted(), resp.Status, strings.TrimSpace(string(body)))
+	}
+	return json.Unmarshal(body, target)
+}
@@ -0,0 +1,141 @@
+package oidc
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/akctba/secret-santa-go-api/oidc/oidctest"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func newTestProvider(issuer *oidctest.Issuer) *Provider {
+	return &Provider{
+		Name:         "mock",
+		Issuer:       issuer.URL,
+		ClientID:     issuer.ClientID,
+		ClientSecret: issuer.ClientSecret,
+		RedirectURL:  "https://app.example.com/signin/callback",
+	}
+}
+
+func signInAtTestIssuer(t *testing.T, issuer *oidctest.Issuer, provider *Provider, user oidctest.User) (string, AuthRequest) {
+	t.Helper()
+
+	request, err := NewAuthRequest()
+	if err != nil {
+		t.Fatalf("NewAuthRequest returned error: %v", err)
+	}
+	authorizationURL, err := provider.AuthorizationURL(context.Background(), request)
+	if err != nil {
+		t.Fatalf("AuthorizationURL returned error: %v", err)
+	}
+
+	code, state := issuer.SignIn(t, authorizationURL, user)
+	if state != request.State {
+		t.Fatalf("expected state %q to round-trip, got %q", request.State, state)
+	}
+	return code, request
+}
+
+func TestExchangeReturnsTheVerifiedClaims(t *testing.T) {
+	issuer := oidctest.NewIssuer(t)
+	provider := newTestProvider(issuer)
+
+	code, request := signInAtTestIssuer(t, issuer, provider, oidctest.User{
+		Subject: "sub-1", Email: "alice@example.com", EmailVerified: true, Name: "Alice",
+	})
+
+	claims, err := provider.Exchange(context.Background(), code, request)
+	if err != nil {
+		t.Fatalf("Exchange returned error: %v", err)
+	}
+	want := Claims{Subject: "sub-1", Email: "alice@example.com", EmailVerified: true, Name: "Alice"}
+	if claims != want {
+		t.Fatalf("Exchange returned %+v, want %+v", claims, want)
+	}
+
+	if _, err := provider.Exchange(context.Background(), code, request); err == nil {
+		t.Fatal("expected a used code to be rejected")
+	}
+}
+
+func TestExchangeRejectsAMismatchedRequest(t *testing.T) {
+	issuer := oidctest.NewIssuer(t)
+	provider := newTestProvider(issuer)
+	user := oidctest.User{Subject: "sub-1", Email: "alice@example.com", EmailVerified: true}
+
+	code, request := signInAtTestIssuer(t, issuer, provider, user)
+	request.CodeVerifier = "another-verifier-that-is-long-enough-for-pkce-checks"
+	if _, err := provider.Exchange(context.Background(), code, request); err == nil {
+		t.Fatal("expected the wrong PKCE verifier to be rejected")
+	}
+
+	code, request = signInAtTestIssuer(t, issuer, provider, user)
+	request.Nonce = "another-nonce"
+	if _, err := provider.Exchange(context.Background(), code, request); err == nil || !strings.Contains(err.Error(), "nonce") {
+		t.Fatalf("expected the wrong nonce to be rejected, got %v", err)
+	}
+}
+
+func TestExchangeRejectsIDTokensForOthers(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(claims jwt.MapClaims)
+	}{
+		{name: "other audience", modify: func(claims jwt.MapClaims) { claims["aud"] = "another-client" }},
+		{name: "other issuer", modify: func(claims jwt.MapClaims) { claims["iss"] = "https://evil.example.com" }},
+		{name: "expired", modify: func(claims jwt.MapClaims) { claims["exp"] = int64(1) }},
+		{name: "other authorized party", modify: func(claims jwt.MapClaims) {
+			claims["aud"] = []string{claims["aud"].(string), "another-client"}
+			claims["azp"] = "another-client"
+		}},
+		{name: "no subject", modify: func(claims jwt.MapClaims) { delete(claims, "sub") }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issuer := oidctest.NewIssuer(t)
+			issuer.ModifyIDToken = tt.modify
+			provider := newTestProvider(issuer)
+
+			code, request := signInAtTestIssuer(t, issuer, provider, oidctest.User{Subject: "sub-1"})
+			if _, err := provider.Exchange(context.Background(), code, request); err == nil {
+				t.Fatal("Exchange expected error")
+			}
+		})
+	}
+}
+
+func TestProvidersFromEnv(t *testing.T) {
+	t.Setenv("OIDC_PROVIDERS", "google, azure-ad")
+	t.Setenv("OIDC_GOOGLE_ISSUER", "https://accounts.google.com")
+	t.Setenv("OIDC_GOOGLE_CLIENT_ID", "google-client")
+	t.Setenv("OIDC_GOOGLE_CLIENT_SECRET", "google-secret")
+	t.Setenv("OIDC_GOOGLE_REDIRECT_URL", "https://app.example.com/signin/google")
+	t.Setenv("OIDC_AZURE_AD_ISSUER", "https://login.microsoftonline.com/tenant/v2.0")
+	t.Setenv("OIDC_AZURE_AD_CLIENT_ID", "azure-client")
+	t.Setenv("OIDC_AZURE_AD_REDIRECT_URL", "https://app.example.com/signin/azure-ad")
+	t.Setenv("OIDC_AZURE_AD_SCOPES", "openid email")
+
+	providers, err := ProvidersFromEnv()
+	if err != nil {
+		t.Fatalf("ProvidersFromEnv returned error: %v", err)
+	}
+	if len(providers) != 2 || providers[0].Name != "google" || providers[1].Name != "azure-ad" {
+		t.Fatalf("unexpected providers %+v", providers)
+	}
+	if providers[0].ClientSecret != "google-secret" || len(providers[1].Scopes) != 2 {
+		t.Fatalf("unexpected provider settings %+v, %+v", providers[0], providers[1])
+	}
+
+	t.Setenv("OIDC_GOOGLE_ISSUER", "http://accounts.google.com")
+	if _, err := ProvidersFromEnv(); err == nil {
+		t.Fatal("expected an http issuer to be rejected")
+	}
+
+	t.Setenv("OIDC_PROVIDERS", "")
+	if providers, err := ProvidersFromEnv(); err != nil || len(providers) != 0 {
+		t.Fatalf("expected no providers, got %+v, %v", providers, err)
+	}
+}
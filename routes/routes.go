@@ -7,18 +7,73 @@ import (
 
 // Register attaches all application routes to the provided router.
 func Register(r *mux.Router) {
+	r.HandleFunc("/.well-known/jwks.json", controllers.GetJWKS).Methods("GET")
+
 	v1 := r.PathPrefix("/v1").Subrouter()
 
 	// User endpoints
 	v1.HandleFunc("/user", controllers.CreateUser).Methods("POST")
 	v1.HandleFunc("/user/signin", controllers.Signin).Methods("POST")
 	v1.HandleFunc("/user/refresh", controllers.RefreshToken).Methods("POST")
+	v1.HandleFunc("/user/logout", controllers.Logout).Methods("POST")
+	v1.HandleFunc("/user/logout-all", controllers.BearerAuth(controllers.LogoutAll)).Methods("POST")
+	v1.HandleFunc("/user/magic-link", controllers.RequestMagicLink).Methods("POST")
+	v1.HandleFunc("/user/magic-link/consume", controllers.ConsumeMagicLink).Methods("POST")
+	v1.HandleFunc("/user/password/forgot", controllers.ForgotPassword).Methods("POST")
+	v1.HandleFunc("/user/password/reset", controllers.ResetPassword).Methods("POST")
+	v1.HandleFunc("/user/verification/confirm", controllers.ConfirmEmail).Methods("POST")
+	v1.HandleFunc("/user/oidc", controllers.ListOIDCProviders).Methods("GET")
+	v1.HandleFunc("/user/oidc/{provider}/authorize", controllers.StartOIDCSignin).Methods("POST")
+	v1.HandleFunc("/user/oidc/{provider}/callback", controllers.CompleteOIDCSignin).Methods("POST")
+	v1.HandleFunc("/user/me", controllers.BearerAuth(controllers.GetMe)).Methods("GET")
+	v1.HandleFunc("/user/me", controllers.BearerAuth(controllers.UpdateMe)).Methods("PATCH")
+	v1.HandleFunc("/user/me", controllers.BearerAuth(controllers.DeleteMe)).Methods("DELETE")
+	v1.HandleFunc("/user/me/password", controllers.BearerAuth(controllers.ChangePassword)).Methods("POST")
+	v1.HandleFunc("/user/me/verification", controllers.BearerAuth(controllers.SendEmailVerification)).Methods("POST")
 	v1.HandleFunc("/user/{id}", controllers.BearerAuth(controllers.GetUser)).Methods("GET")
 
 	// Group endpoints
 	v1.HandleFunc("/group", controllers.BearerAuth(controllers.CreateGroup)).Methods("POST")
+	v1.HandleFunc("/group", controllers.BearerAuth(controllers.ListGroups)).Methods("GET")
 	v1.HandleFunc("/group/{id}", controllers.BearerAuth(controllers.GetGroup)).Methods("GET")
+	v1.HandleFunc("/group/{id}", controllers.BearerAuth(controllers.UpdateGroup)).Methods("PATCH")
+	v1.HandleFunc("/group/{id}", controllers.BearerAuth(controllers.DeleteGroup)).Methods("DELETE")
+	v1.HandleFunc("/group/{id}/event.ics", controllers.BearerAuth(controllers.GetGroupEvent)).Methods("GET")
 	v1.HandleFunc("/group/{id}/participant", controllers.BearerAuth(controllers.AddParticipant)).Methods("POST")
+	v1.HandleFunc("/group/{id}/participant", controllers.BearerAuth(controllers.GetParticipants)).Methods("GET")
+	v1.HandleFunc("/group/{id}/participant/{userId}", controllers.BearerAuth(controllers.RemoveParticipant)).Methods("DELETE")
+	v1.HandleFunc("/group/{id}/participant/{userId}/role", controllers.BearerAuth(controllers.UpdateParticipantRole)).Methods("PUT")
+	v1.HandleFunc("/group/{id}/leave", controllers.BearerAuth(controllers.LeaveGroup)).Methods("POST")
+	v1.HandleFunc("/group/{id}/owner", controllers.BearerAuth(controllers.TransferOwnership)).Methods("POST")
 	v1.HandleFunc("/group/{id}/draw", controllers.BearerAuth(controllers.RunDraw)).Methods("POST")
+	v1.HandleFunc("/group/{id}/draw", controllers.BearerAuth(controllers.GetDraws)).Methods("GET")
+	v1.HandleFunc("/group/{id}/draw/reset", controllers.BearerAuth(controllers.ResetDraw)).Methods("POST")
+	v1.HandleFunc("/group/{id}/redraw", controllers.BearerAuth(controllers.Redraw)).Methods("POST")
+	v1.HandleFunc("/group/{id}/reminder", controllers.BearerAuth(controllers.SendReminder)).Methods("POST")
 	v1.HandleFunc("/group/{id}/friend", controllers.BearerAuth(controllers.GetSecretFriend)).Methods("GET")
+	v1.HandleFunc("/group/{id}/friend/message", controllers.BearerAuth(controllers.GetFriendMessages)).Methods("GET")
+	v1.HandleFunc("/group/{id}/friend/message", controllers.BearerAuth(controllers.PostFriendMessage)).Methods("POST")
+	v1.HandleFunc("/group/{id}/santa/message", controllers.BearerAuth(controllers.GetSantaMessages)).Methods("GET")
+	v1.HandleFunc("/group/{id}/santa/message", controllers.BearerAuth(controllers.PostSantaMessage)).Methods("POST")
+	v1.HandleFunc("/group/{id}/exclusion", controllers.BearerAuth(controllers.CreateExclusion)).Methods("POST")
+	v1.HandleFunc("/group/{id}/exclusion", controllers.BearerAuth(controllers.GetExclusions)).Methods("GET")
+	v1.HandleFunc("/group/{id}/exclusion/{exclusionId}", controllers.BearerAuth(controllers.DeleteExclusion)).Methods("DELETE")
+	v1.HandleFunc("/group/{id}/invite", controllers.BearerAuth(controllers.CreateInvite)).Methods("POST")
+	v1.HandleFunc("/group/{id}/invite", controllers.BearerAuth(controllers.GetInvites)).Methods("GET")
+	v1.HandleFunc("/group/{id}/invite/{inviteId}", controllers.BearerAuth(controllers.RevokeInvite)).Methods("DELETE")
+	v1.HandleFunc("/group/{id}/invitation", controllers.BearerAuth(controllers.CreateEmailInvitation)).Methods("POST")
+	v1.HandleFunc("/group/{id}/invitation", controllers.BearerAuth(controllers.GetEmailInvitations)).Methods("GET")
+
+	// Wishlist endpoints
+	v1.HandleFunc("/wishlist", controllers.BearerAuth(controllers.CreateWishlist)).Methods("POST")
+	v1.HandleFunc("/wishlist", controllers.BearerAuth(controllers.GetWishlists)).Methods("GET")
+	v1.HandleFunc("/wishlist/{wishlistId}", controllers.BearerAuth(controllers.GetWishlist)).Methods("GET")
+	v1.HandleFunc("/wishlist/{wishlistId}", controllers.BearerAuth(controllers.UpdateWishlist)).Methods("PUT")
+	v1.HandleFunc("/wishlist/{wishlistId}", controllers.BearerAuth(controllers.DeleteWishlist)).Methods("DELETE")
+	v1.HandleFunc("/wishlist/{wishlistId}/item", controllers.BearerAuth(controllers.CreateWishlistItem)).Methods("POST")
+	v1.HandleFunc("/wishlist/{wishlistId}/item/{itemId}", controllers.BearerAuth(controllers.UpdateWishlistItem)).Methods("PUT")
+	v1.HandleFunc("/wishlist/{wishlistId}/item/{itemId}", controllers.BearerAuth(controllers.DeleteWishlistItem)).Methods("DELETE")
+
+	// Invite endpoints
+	v1.HandleFunc("/invite/{code}/accept", controllers.BearerAuth(controllers.AcceptInvite)).Methods("POST")
 }
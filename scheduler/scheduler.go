@@ -0,0 +1,130 @@
+// Package scheduler draws groups automatically once their date_draw has passed.
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/akctba/secret-santa-go-api/database"
+	"github.com/akctba/secret-santa-go-api/models"
+)
+
+const (
+	defaultInterval    = time.Minute
+	defaultLease       = 5 * time.Minute
+	defaultMaxAttempts = 3
+)
+
+// DrawFunc draws a single group.
+type DrawFunc func(db *sql.DB, group models.Group) error
+
+// Scheduler periodically draws every group that is due. Each group is leased
+// to one instance in the database before it is drawn, so several instances can
+// run side by side without drawing a group twice.
+type Scheduler struct {
+	// Draw runs the draw for a due group.
+	Draw DrawFunc
+	// InstanceID identifies this instance in group leases.
+	InstanceID string
+	// Interval is the time between two runs.
+	Interval time.Duration
+	// Lease is how long a group stays claimed, and how long a failed group
+	// waits before it is retried.
+	Lease time.Duration
+	// MaxAttempts is how many times a failing group is tried before giving up.
+	MaxAttempts int
+
+	openDB func() (*sql.DB, error)
+	now    func() time.Time
+}
+
+// New returns a Scheduler with the default interval, lease and attempts.
+func New(draw DrawFunc) *Scheduler {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+
+	return &Scheduler{
+		Draw:        draw,
+		InstanceID:  fmt.Sprintf("%s-%d", hostname, os.Getpid()),
+		Interval:    defaultInterval,
+		Lease:       defaultLease,
+		MaxAttempts: defaultMaxAttempts,
+		openDB:      database.GetDb,
+		now:         time.Now,
+	}
+}
+
+// Start runs the scheduler every Interval until ctx is cancelled.
+func (s *Scheduler) Start(ctx context.Context) {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.RunOnce(); err != nil {
+			log.Printf("draw scheduler run failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce draws every group that is currently due and not leased to another
+// instance, recording the outcome on the group.
+func (s *Scheduler) RunOnce() error {
+	db, err := s.openDB()
+	if err != nil {
+		return err
+	}
+	defer database.CloseDb(db)
+
+	now := s.now().UTC()
+	groups, err := database.GetGroupsDueForDraw(db, now, s.MaxAttempts)
+	if err != nil {
+		return err
+	}
+
+	for _, group := range groups {
+		claimed, err := database.ClaimGroupForDraw(db, group.GroupID, s.InstanceID, now, s.Lease)
+		if err != nil {
+			return err
+		}
+		if !claimed {
+			continue
+		}
+
+		s.drawGroup(db, group, now)
+	}
+
+	return nil
+}
+
+func (s *Scheduler) drawGroup(db *sql.DB, group models.Group, now time.Time) {
+	err := s.Draw(db, group)
+
+	status, message, retryAt := database.AutoDrawDrawn, "", time.Time{}
+	switch {
+	case err == nil:
+		log.Printf("draw scheduler drew group %s", group.GroupID)
+	case errors.Is(err, database.ErrDrawExists):
+		// An organizer drew the group between listing and claiming it.
+		status = database.AutoDrawSkipped
+	default:
+		log.Printf("draw scheduler failed to draw group %s: %v", group.GroupID, err)
+		status, message, retryAt = database.AutoDrawFailed, err.Error(), now.Add(s.Lease)
+	}
+
+	if err := database.RecordAutoDrawOutcome(db, group.GroupID, s.InstanceID, status, message, retryAt); err != nil {
+		log.Printf("draw scheduler failed to record outcome for group %s: %v", group.GroupID, err)
+	}
+}
@@ -0,0 +1,219 @@
+package scheduler
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/akctba/secret-santa-go-api/controllers"
+	"github.com/akctba/secret-santa-go-api/database"
+	"github.com/akctba/secret-santa-go-api/models"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func openSchedulerTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	t.Chdir(t.TempDir())
+	database.CreateTables()
+
+	db, err := database.GetDb()
+	if err != nil {
+		t.Fatalf("open scheduler test db: %v", err)
+	}
+
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+
+	return db
+}
+
+func seedScheduledGroup(t *testing.T, db *sql.DB, groupID int, dateDraw time.Time, userIDs ...int) {
+	t.Helper()
+
+	_, err := db.Exec(`INSERT INTO Groups (group_id, name, date_created, date_draw, creator_user_id) VALUES (?, ?, ?, ?, ?)`,
+		groupID, "Holiday Crew", time.Now().UTC(), dateDraw, 1)
+	if err != nil {
+		t.Fatalf("insert group %d: %v", groupID, err)
+	}
+
+	for _, userID := range userIDs {
+		_, err := db.Exec(`INSERT OR IGNORE INTO Users (user_id, user_name, user_email, password) VALUES (?, ?, ?, ?)`,
+			userID, "user", fmt.Sprintf("user%d@example.com", userID), "secret")
+		if err != nil {
+			t.Fatalf("insert user %d: %v", userID, err)
+		}
+
+		if err := database.InsertParticipant(db, models.ParticipantRequest{GroupID: "1", UserID: userID}); err != nil {
+			t.Fatalf("insert participant %d: %v", userID, err)
+		}
+	}
+}
+
+func newTestScheduler(instanceID string, now time.Time, draw DrawFunc) *Scheduler {
+	s := New(draw)
+	s.InstanceID = instanceID
+	s.now = func() time.Time {
+		return now
+	}
+	return s
+}
+
+func autoDrawStatus(t *testing.T, db *sql.DB, groupID int) (string, int) {
+	t.Helper()
+
+	var status sql.NullString
+	var attempts int
+	err := db.QueryRow(`SELECT auto_draw_status, auto_draw_attempts FROM Groups WHERE group_id = ?`, groupID).Scan(&status, &attempts)
+	if err != nil {
+		t.Fatalf("load auto draw status: %v", err)
+	}
+	return status.String, attempts
+}
+
+func TestRunOnceDrawsDueGroupsOnce(t *testing.T) {
+	db := openSchedulerTestDB(t)
+	now := time.Now().UTC()
+
+	seedScheduledGroup(t, db, 1, now.Add(-time.Hour), 1, 2, 3)
+	seedScheduledGroup(t, db, 2, now.Add(time.Hour))
+	seedScheduledGroup(t, db, 3, time.Time{})
+
+	first := newTestScheduler("instance-a", now, controllers.RunScheduledDraw)
+	if err := first.RunOnce(); err != nil {
+		t.Fatalf("RunOnce returned error: %v", err)
+	}
+
+	drawn, err := database.GetDrawByGroupID(db, "1")
+	if err != nil {
+		t.Fatalf("expected group 1 to be drawn: %v", err)
+	}
+	if drawn.RunBy != 0 || drawn.Algorithm == "" {
+		t.Fatalf("expected a scheduled draw record, got %+v", drawn)
+	}
+	if status, attempts := autoDrawStatus(t, db, 1); status != database.AutoDrawDrawn || attempts != 1 {
+		t.Fatalf("expected group 1 to be recorded as drawn, got %q after %d attempts", status, attempts)
+	}
+	for _, groupID := range []int{2, 3} {
+		if status, _ := autoDrawStatus(t, db, groupID); status != "" {
+			t.Fatalf("expected group %d not to be due, got status %q", groupID, status)
+		}
+	}
+
+	calls := 0
+	second := newTestScheduler("instance-b", now.Add(time.Minute), func(*sql.DB, models.Group) error {
+		calls++
+		return nil
+	})
+	if err := second.RunOnce(); err != nil {
+		t.Fatalf("RunOnce returned error: %v", err)
+	}
+	if calls != 0 {
+		t.Fatalf("expected a drawn group not to be drawn again, got %d draws", calls)
+	}
+}
+
+func TestRunOnceWaitsForANewDrawDateAfterAReset(t *testing.T) {
+	db := openSchedulerTestDB(t)
+	now := time.Now().UTC()
+	seedScheduledGroup(t, db, 1, now.Add(-time.Hour), 1, 2, 3)
+
+	if err := newTestScheduler("instance-a", now, controllers.RunScheduledDraw).RunOnce(); err != nil {
+		t.Fatalf("RunOnce returned error: %v", err)
+	}
+	if err := database.ResetDraw(db, "1", 1); err != nil {
+		t.Fatalf("ResetDraw returned error: %v", err)
+	}
+
+	if err := newTestScheduler("instance-a", now.Add(time.Minute), controllers.RunScheduledDraw).RunOnce(); err != nil {
+		t.Fatalf("RunOnce returned error: %v", err)
+	}
+	if _, err := database.GetDrawByGroupID(db, "1"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected a reset group not to be redrawn, got %v", err)
+	}
+
+	// Moving date_draw resets the scheduled draw, as PATCH /group/{id} does.
+	if err := database.ResetAutoDraw(db, "1"); err != nil {
+		t.Fatalf("ResetAutoDraw returned error: %v", err)
+	}
+	if err := newTestScheduler("instance-a", now.Add(2*time.Minute), controllers.RunScheduledDraw).RunOnce(); err != nil {
+		t.Fatalf("RunOnce returned error: %v", err)
+	}
+	if _, err := database.GetDrawByGroupID(db, "1"); err != nil {
+		t.Fatalf("expected the group to be drawn again once its draw date moved: %v", err)
+	}
+}
+
+func TestRunOnceSkipsGroupsLeasedToAnotherInstance(t *testing.T) {
+	db := openSchedulerTestDB(t)
+	now := time.Now().UTC()
+	seedScheduledGroup(t, db, 1, now.Add(-time.Hour))
+
+	claimed, err := database.ClaimGroupForDraw(db, "1", "instance-a", now, defaultLease)
+	if err != nil || !claimed {
+		t.Fatalf("expected instance-a to claim the group, got %v, %v", claimed, err)
+	}
+
+	calls := 0
+	countDraws := func(*sql.DB, models.Group) error {
+		calls++
+		return nil
+	}
+
+	if err := newTestScheduler("instance-b", now.Add(time.Minute), countDraws).RunOnce(); err != nil {
+		t.Fatalf("RunOnce returned error: %v", err)
+	}
+	if calls != 0 {
+		t.Fatalf("expected a leased group to be skipped, got %d draws", calls)
+	}
+
+	// instance-a died without recording an outcome; its lease expires.
+	if err := newTestScheduler("instance-b", now.Add(defaultLease), countDraws).RunOnce(); err != nil {
+		t.Fatalf("RunOnce returned error: %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected the group to be drawn after the lease expired, got %d draws", calls)
+	}
+}
+
+func TestRunOnceRetriesFailedGroupsUpToMaxAttempts(t *testing.T) {
+	db := openSchedulerTestDB(t)
+	now := time.Now().UTC()
+	seedScheduledGroup(t, db, 1, now.Add(-time.Hour))
+
+	calls := 0
+	failing := func(*sql.DB, models.Group) error {
+		calls++
+		return errors.New("no participants to draw")
+	}
+
+	for attempt := 0; attempt < defaultMaxAttempts+1; attempt++ {
+		// Runs within the retry delay must not try again.
+		at := now.Add(time.Duration(attempt) * defaultLease)
+		for _, runAt := range []time.Time{at, at.Add(time.Second)} {
+			if err := newTestScheduler("instance-a", runAt, failing).RunOnce(); err != nil {
+				t.Fatalf("RunOnce returned error: %v", err)
+			}
+		}
+	}
+
+	if calls != defaultMaxAttempts {
+		t.Fatalf("expected %d attempts, got %d", defaultMaxAttempts, calls)
+	}
+
+	status, attempts := autoDrawStatus(t, db, 1)
+	if status != database.AutoDrawFailed || attempts != defaultMaxAttempts {
+		t.Fatalf("expected the group to be recorded as failed, got %q after %d attempts", status, attempts)
+	}
+
+	var message string
+	if err := db.QueryRow(`SELECT auto_draw_error FROM Groups WHERE group_id = 1`).Scan(&message); err != nil {
+		t.Fatalf("load auto draw error: %v", err)
+	}
+	if message != "no participants to draw" {
+		t.Fatalf("expected the failure to be recorded, got %q", message)
+	}
+}